@@ -0,0 +1,109 @@
+package zcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of lookups after which the TTL is adjusted.
+const autoTTLWindow = 1000
+
+type autoTTL struct {
+	target   float64
+	min, max time.Duration
+	step     time.Duration
+	hits     int64
+	misses   int64
+	mu       sync.Mutex
+	ttl      int64
+}
+
+func (a *autoTTL) current() time.Duration { return time.Duration(atomic.LoadInt64(&a.ttl)) }
+
+// record a lookup, and adjust the TTL once enough lookups were done.
+func (a *autoTTL) record(hit bool) {
+	var n int64
+	if hit {
+		n = atomic.AddInt64(&a.hits, 1) + atomic.LoadInt64(&a.misses)
+	} else {
+		n = atomic.AddInt64(&a.misses, 1) + atomic.LoadInt64(&a.hits)
+	}
+	if n < autoTTLWindow {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	hits, misses := atomic.LoadInt64(&a.hits), atomic.LoadInt64(&a.misses)
+	if hits+misses < autoTTLWindow { // Another goroutine already adjusted it.
+		return
+	}
+	atomic.StoreInt64(&a.hits, 0)
+	atomic.StoreInt64(&a.misses, 0)
+
+	// Additive increase if we're below the target, multiplicative decrease if
+	// we're above it.
+	ttl := a.current()
+	if float64(hits)/float64(hits+misses) < a.target {
+		ttl += a.step
+	} else {
+		ttl -= ttl / 8
+	}
+	if ttl < a.min {
+		ttl = a.min
+	}
+	if ttl > a.max {
+		ttl = a.max
+	}
+	atomic.StoreInt64(&a.ttl, int64(ttl))
+}
+
+// AutoTTL enables adjusting the default expiration based on the hit ratio.
+//
+// This is experimental. The default expiration is increased as long as the
+// ratio of Get() calls that find an item is below target (e.g. 0.9 for 90%),
+// and decreased when it's above it, staying within min and max. The TTL is
+// adjusted every 1,000 lookups and only applies to items set with the default
+// expiration after that.
+//
+// Use a target of 0 to disable it again, which resets the default expiration
+// to what the cache was created with.
+func (c *cache[K, V]) AutoTTL(target float64, min, max time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.storeFilter()
+	if target <= 0 {
+		c.autoTTL = nil
+		return
+	}
+
+	ttl := c.defaultExpiration
+	if ttl < min {
+		ttl = min
+	}
+	if ttl > max {
+		ttl = max
+	}
+	step := (max - min) / 20
+	if step < 1 {
+		step = 1
+	}
+	c.autoTTL = &autoTTL{target: target, min: min, max: max, step: step, ttl: int64(ttl)}
+}
+
+// DefaultExpiration gets the current default expiration.
+//
+// This is the value the cache was created with, unless AutoTTL() is used.
+func (c *cache[K, V]) DefaultExpiration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultTTL()
+}
+
+func (c *cache[K, V]) defaultTTL() time.Duration {
+	if c.autoTTL != nil {
+		return c.autoTTL.current()
+	}
+	return c.defaultExpiration
+}
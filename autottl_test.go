@@ -0,0 +1,48 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoTTL(t *testing.T) {
+	tc := New[int, int](time.Minute, 0)
+	tc.AutoTTL(0.5, time.Second, 21*time.Second)
+	if d := tc.DefaultExpiration(); d != 21*time.Second {
+		t.Fatalf("wrong TTL: %s", d)
+	}
+
+	// All misses: increase.
+	tc.AutoTTL(0.5, time.Second, time.Minute)
+	for i := 0; i < autoTTLWindow; i++ {
+		tc.Get(i)
+	}
+	if d := tc.DefaultExpiration(); d != time.Minute {
+		t.Fatalf("wrong TTL: %s", d)
+	}
+
+	tc.AutoTTL(0.5, time.Second, 2*time.Minute)
+	for i := 0; i < autoTTLWindow; i++ {
+		tc.Get(i)
+	}
+	if d := tc.DefaultExpiration(); d != time.Minute+6*time.Second-time.Second/20 {
+		t.Fatalf("wrong TTL: %s", d)
+	}
+
+	// All hits: decrease.
+	tc.Set(1, 1)
+	if _, exp, _ := tc.GetWithExpire(1); time.Until(exp) < time.Minute {
+		t.Fatalf("wrong expiry: %s", exp)
+	}
+	for i := 0; i < autoTTLWindow; i++ {
+		tc.Get(1)
+	}
+	if d := tc.DefaultExpiration(); d >= time.Minute+6*time.Second-time.Second/20 {
+		t.Fatalf("wrong TTL: %s", d)
+	}
+
+	tc.AutoTTL(0, 0, 0)
+	if d := tc.DefaultExpiration(); d != time.Minute {
+		t.Fatalf("wrong TTL: %s", d)
+	}
+}
@@ -1,6 +1,7 @@
 package zcache
 
 import (
+	"regexp"
 	"runtime"
 	"strconv"
 	"sync"
@@ -196,3 +197,41 @@ func BenchmarkDeleteExpiredLoop(b *testing.B) {
 		tc.DeleteExpired()
 	}
 }
+
+func BenchmarkMemoizeCompile(b *testing.B) {
+	b.Run("regexp.Compile", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = regexp.Compile(`^[a-z]+\[(\d+)\]$`)
+		}
+	})
+	b.Run("MemoizeCompile", func(b *testing.B) {
+		compile := MemoizeCompile(100, regexp.Compile)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = compile(`^[a-z]+\[(\d+)\]$`)
+		}
+	})
+}
+
+func BenchmarkResetRefill(b *testing.B) {
+	tc := New[int, int](NoExpiration, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1000; j++ {
+			tc.Set(j, j)
+		}
+		tc.Reset()
+	}
+}
+
+func BenchmarkShardedSetConcurrent(b *testing.B) {
+	tc := NewSharded[string, any](32, NoExpiration, 0, nil)
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			tc.Set(strconv.Itoa(i%1000), "bar")
+			i++
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package zcache
+
+import (
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState uint8
+
+// Circuit breaker states.
+const (
+	BreakerClosed   BreakerState = iota // Requests are allowed.
+	BreakerOpen                         // Requests are not allowed.
+	BreakerHalfOpen                     // A single request is allowed, to see if the target recovered.
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type breaker struct {
+	state    BreakerState
+	failures int
+	until    time.Time
+}
+
+// Breakers stores the state of circuit breakers per key, for example per
+// upstream host.
+//
+// A breaker opens after a number of consecutive failures, after which requests
+// are not allowed. Once the reset timeout has passed the breaker becomes
+// half-open, and a single request is allowed: if it succeeds the breaker is
+// closed again, and if it fails it's opened again.
+//
+// All state transitions are atomic.
+type Breakers[K comparable] struct {
+	cache     *Cache[K, breaker]
+	threshold int
+	reset     time.Duration
+}
+
+// NewBreakers creates a new set of circuit breakers, which open after threshold
+// consecutive failures and allow a new request after resetTimeout.
+func NewBreakers[K comparable](threshold int, resetTimeout time.Duration) *Breakers[K] {
+	return &Breakers[K]{
+		cache:     New[K, breaker](NoExpiration, 0),
+		threshold: threshold,
+		reset:     resetTimeout,
+	}
+}
+
+// Allow reports if a request for the key is allowed.
+//
+// This will transition an open breaker to half-open if the reset timeout has
+// passed, in which case only this call will return true.
+func (b *Breakers[K]) Allow(k K) bool {
+	var allow bool
+	b.modify(k, func(br breaker) breaker {
+		switch br.state {
+		case BreakerClosed:
+			allow = true
+		case BreakerOpen:
+			if !time.Now().Before(br.until) {
+				br.state, allow = BreakerHalfOpen, true
+			}
+		}
+		return br
+	})
+	return allow
+}
+
+// Success records a successful request, closing the breaker.
+func (b *Breakers[K]) Success(k K) {
+	b.modify(k, func(br breaker) breaker { return breaker{} })
+}
+
+// Failure records a failed request, opening the breaker if the threshold is
+// reached or if the breaker is half-open.
+func (b *Breakers[K]) Failure(k K) {
+	b.modify(k, func(br breaker) breaker {
+		br.failures++
+		if br.state == BreakerHalfOpen || br.failures >= b.threshold {
+			br.state, br.until = BreakerOpen, time.Now().Add(b.reset)
+		}
+		return br
+	})
+}
+
+// State gets the current state of the breaker for the key.
+func (b *Breakers[K]) State(k K) BreakerState {
+	br, _ := b.cache.Get(k)
+	return br.state
+}
+
+// Reset all breakers to closed.
+func (b *Breakers[K]) Reset() {
+	b.cache.Reset()
+}
+
+func (b *Breakers[K]) modify(k K, f func(breaker) breaker) {
+	for {
+		if _, ok := b.cache.Modify(k, f); ok {
+			return
+		}
+		// Not set yet; try again with Modify if another goroutine added it
+		// in the meanwhile.
+		if b.cache.Add(k, f(breaker{})) == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package zcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakers(t *testing.T) {
+	b := NewBreakers[string](2, 10*time.Millisecond)
+
+	want := func(s BreakerState) {
+		t.Helper()
+		if h := b.State("a"); h != s {
+			t.Fatalf("state: %s; want: %s", h, s)
+		}
+	}
+
+	want(BreakerClosed)
+	if !b.Allow("a") {
+		t.Fatal("not allowed")
+	}
+	b.Failure("a")
+	want(BreakerClosed)
+	b.Failure("a")
+	want(BreakerOpen)
+	if b.Allow("a") {
+		t.Fatal("allowed")
+	}
+
+	// Half-open: allow only one request.
+	time.Sleep(10 * time.Millisecond)
+	var (
+		wg      sync.WaitGroup
+		allowed int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow("a") {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Fatalf("allowed %d times", allowed)
+	}
+	want(BreakerHalfOpen)
+
+	// Failure in half-open opens it again.
+	b.Failure("a")
+	want(BreakerOpen)
+
+	time.Sleep(10 * time.Millisecond)
+	if !b.Allow("a") {
+		t.Fatal("not allowed")
+	}
+	b.Success("a")
+	want(BreakerClosed)
+	if !b.Allow("a") {
+		t.Fatal("not allowed")
+	}
+
+	b.Failure("a")
+	b.Failure("a")
+	b.Reset()
+	want(BreakerClosed)
+}
@@ -0,0 +1,118 @@
+package zcache
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Cacher is the full set of methods of Cache.
+//
+// This can be used to swap out the cache with a different implementation, for
+// example a mock in tests (see the zcachemock package).
+type Cacher[K comparable, V any] interface {
+	Set(k K, v V)
+	SetWithExpire(k K, v V, d time.Duration)
+	SetKeepTTL(k K, v V) bool
+	TrySet(k K, v V, d time.Duration) bool
+	SetWithEvict(k K, v V, d time.Duration, onEvict func(K, V))
+	SetWithMaxUses(k K, v V, d time.Duration, n int)
+	SetErr(k K, err error, d time.Duration)
+	SetLazy(k K, f func() V, d time.Duration)
+	SetUntil(k K, v V, done <-chan struct{}, d time.Duration)
+	Entry(k K) Entry[K, V]
+	Touch(k K) (V, bool)
+	TouchWithExpire(k K, d time.Duration) (V, bool)
+	ExpireMany(keys []K, d time.Duration) int
+	Revalidate(k K, check func(V) (stillValid bool, newTTL time.Duration)) bool
+	Add(k K, v V) error
+	AddWithExpire(k K, v V, d time.Duration) error
+	AddGetExisting(k K, v V, d time.Duration) (Item[V], bool, error)
+	GetOrAdd(k K, v V) V
+	GetOrAddWithExpire(k K, v V, d time.Duration) V
+	Replace(k K, v V) error
+	ReplaceWithExpire(k K, v V, d time.Duration) error
+
+	Get(k K) (V, bool)
+	GetWithin(k K, maxWait time.Duration) (v V, ok bool, busy bool)
+	GetResult(k K) (V, error, bool)
+	GetOrSet(k K, f func() (V, time.Duration)) V
+	GetOrSetErr(k K, f func() (V, time.Duration, error)) (V, error)
+	GetOrSetContext(ctx context.Context, k K, f func(context.Context) (V, time.Duration, error)) (V, error)
+	Promise(k K) *Promise[V]
+	GetFresh(k K, f func() (V, error)) (V, error)
+	GetStale(k K) (v V, expired bool, ok bool)
+	GetWithExpire(k K) (V, time.Time, bool)
+	Modify(k K, f func(V) V) (V, bool)
+	ModifyErr(k K, f func(V) (V, error)) (V, error)
+	ModifyMany(keys []K, f func(K, V) V) []ModifyResult[V]
+	ModifyOrSet(k K, f func(V) V, def V) V
+	Rename(src, dst K) bool
+	RenameFunc(f func(K) (K, bool)) int
+	Pop(k K) (V, bool)
+	Refresh(k K, f func() (V, error)) error
+	Items() map[K]Item[V]
+	ItemsFrozen() *ItemsView[K, V]
+	ItemsWhere(filter func(K, Item[V]) bool) map[K]Item[V]
+	ItemsAll() map[K]Item[V]
+	Keys() []K
+	KeysAll() []K
+	ExpiringSoon(n int) []K
+	ItemCount() int
+
+	Delete(k K)
+	TryDelete(k K) bool
+	BindContext(ctx context.Context, keys ...K)
+	DeleteExpired()
+	WriteMetrics(w io.Writer) error
+	JanitorStatus() (running bool, lastRun time.Time, lastDeleted int)
+	JanitorLockTime() (took, interval time.Duration)
+	JanitorBackoff(threshold, maxInterval time.Duration, notify func(interval, took time.Duration))
+	JanitorSchedule(delay, align time.Duration)
+	Validate(maxTTL time.Duration) []Problem[K]
+	DeleteAll() map[K]Item[V]
+	DeleteFunc(filter func(key K, item Item[V]) (del, stop bool)) map[K]Item[V]
+	DeleteFuncWithin(budget time.Duration, cur *Cursor[K], filter func(key K, item Item[V]) bool) (map[K]Item[V], *Cursor[K])
+	Reset()
+	ReleaseMemory(fraction float64) int
+	TrackFrequency(enable bool, decay time.Duration)
+	Frequency(k K) uint32
+	EvictLFU(n int) int
+	MaxItems(n int, policy EvictionPolicy)
+	MaxCost(max int64, policy EvictionPolicy, weigher func(K, V) int64)
+	Cost() (cost, max int64)
+
+	TryLockKey(k K, ttl time.Duration) (string, bool)
+	UnlockKey(k K, token string) bool
+
+	OnEvicted(f func(K, V))
+	OnEvictedBatch(f func(map[K]V))
+	AddEvictionHandler(f func(K, V)) (remove func())
+	EvictionOrder(less func(a, b K) bool)
+	OnExpiring(lead time.Duration, f func(K, V))
+	ExpiryNotifications(buffer int) <-chan K
+	DroppedExpiryNotifications() uint64
+	KeepHistory(n int, interval time.Duration)
+	History() []Snapshot[K]
+	ExpireOnAccess(enable bool)
+	CoarseTime(resolution time.Duration)
+	Strict(enable bool)
+	Freeze()
+	Unfreeze()
+	Frozen() (frozen bool, rejected uint64)
+	SetName(name string)
+	Name() string
+	DefaultValue(v V)
+	KeyNormalizer(f func(K) K)
+	KeyFormatter(f func(K) string)
+	KeyValidator(f func(K) error)
+	ReadPipeline(fs ...func(V) V)
+	AutoTTL(target float64, min, max time.Duration)
+	DefaultExpiration() time.Duration
+	TrackTTL(enable bool, group func(K) K)
+	TTLStats() map[K]TTLStats
+	TrackKeys(keys ...K)
+	KeyStats() map[K]KeyStats
+}
+
+var _ Cacher[string, any] = &Cache[string, any]{}
@@ -0,0 +1,21 @@
+package zcache
+
+import "time"
+
+// epoch is the wall clock time when the process started; it includes a
+// monotonic clock reading.
+var epoch = time.Now()
+
+// nanotime gets the current time in nanoseconds since the Unix epoch, for use
+// in Item.Expiration.
+//
+// This is based on the monotonic clock rather than the wall clock, so changes
+// to the system time (e.g. from NTP or the administrator) don't cause items to
+// expire early or to live much longer than they should. The values are offset
+// to the wall clock time at process start, so they're still compatible with
+// UnixNano() timestamps (e.g. in items serialized with an older version, or
+// passed to NewFrom()), but may drift from the current wall clock if the
+// system time was changed after the process started.
+func nanotime() int64 {
+	return epoch.UnixNano() + int64(time.Since(epoch))
+}
@@ -0,0 +1,21 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNanotime(t *testing.T) {
+	a := nanotime()
+	time.Sleep(10 * time.Millisecond)
+	b := nanotime()
+	if d := time.Duration(b - a); d < 10*time.Millisecond || d > time.Second {
+		t.Errorf("wrong difference: %s", d)
+	}
+
+	// Should be close to the wall clock, as long as the system time wasn't
+	// changed during the test.
+	if d := time.Duration(time.Now().UnixNano() - b); d < -time.Second || d > time.Second {
+		t.Errorf("too far from wall clock: %s", d)
+	}
+}
@@ -0,0 +1,56 @@
+package zcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type coarseClock struct {
+	now  int64 // Atomic
+	stop chan struct{}
+}
+
+// CoarseTime makes the cache read the time once every resolution in a
+// background goroutine, instead of reading it for every expiration check.
+//
+// Reading the clock is a significant part of the cost of Get() and Set() for
+// items with an expiration; with this it's just an atomic load. The downside
+// is that items may expire up to resolution too early or too late.
+//
+// Use 0 to read the clock on every operation again and stop the goroutine; it
+// also stops if the cache is garbage collected.
+func (c *cache[K, V]) CoarseTime(resolution time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cc, _ := c.clock.Load().(*coarseClock); cc != nil {
+		close(cc.stop)
+		c.clock.Store((*coarseClock)(nil))
+	}
+	if resolution <= 0 {
+		return
+	}
+
+	cc := &coarseClock{now: nanotime(), stop: make(chan struct{})}
+	c.clock.Store(cc)
+	go func() {
+		t := time.NewTicker(resolution)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				atomic.StoreInt64(&cc.now, nanotime())
+			case <-cc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// nanotime gets the current time for expiration checks, which is the coarse
+// time if CoarseTime() is used.
+func (c *cache[K, V]) nanotime() int64 {
+	if cc, _ := c.clock.Load().(*coarseClock); cc != nil {
+		return atomic.LoadInt64(&cc.now)
+	}
+	return nanotime()
+}
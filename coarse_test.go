@@ -0,0 +1,31 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoarseTime(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.CoarseTime(time.Hour)
+	defer tc.CoarseTime(0)
+
+	tc.SetWithExpire("a", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := tc.Get("a"); !ok {
+		t.Error("expired before the clock was updated")
+	}
+
+	tc.CoarseTime(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := tc.Get("a"); ok {
+		t.Error("not expired")
+	}
+
+	tc.CoarseTime(0)
+	tc.SetWithExpire("b", 1, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := tc.Get("b"); ok {
+		t.Error("not expired")
+	}
+}
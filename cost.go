@@ -0,0 +1,58 @@
+package zcache
+
+// MaxCost sets the maximum total cost of all items in the cache, as calculated
+// by the weigher function; for example the size of a []byte value.
+//
+// If a new item is set and the total cost is higher than max, items are
+// evicted according to the policy until it's not. An item with a cost higher
+// than max is still stored, after evicting all other items. The policy is
+// shared with MaxItems(): calling either one with a different policy changes it
+// for both.
+//
+// The weigher is called with the lock held, so it must be fast and must not
+// call any methods on the cache. Use a max of 0 to disable it again.
+func (c *cache[K, V]) MaxCost(max int64, policy EvictionPolicy, weigher func(K, V) int64) {
+	c.mu.Lock()
+	defer c.unlock()
+	if max <= 0 || weigher == nil {
+		c.maxCost, c.weigher, c.costs, c.cost = 0, nil, nil, 0
+		c.setPolicy(policy)
+		return
+	}
+
+	c.maxCost, c.weigher = max, weigher
+	c.costs, c.cost = make(map[K]int64, len(c.items)), 0
+	for k, v := range c.items {
+		w := weigher(k, v.Object)
+		c.costs[k] = w
+		c.cost += w
+	}
+	c.setPolicy(policy)
+	c.evictOverflow()
+}
+
+// Cost gets the total cost of all items and the maximum set with MaxCost().
+//
+// This is always 0 if MaxCost() wasn't called.
+func (c *cache[K, V]) Cost() (cost, max int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cost, c.maxCost
+}
+
+// weigh updates the cost of an item after its value changed, and evicts items
+// if the cache is over the limit. The lock must be held, and released with
+// unlock().
+func (c *cache[K, V]) weigh(k K, v V) {
+	if c.weigher == nil {
+		return
+	}
+	c.rearmWatermark()
+	w := c.weigher(k, v)
+	c.cost += w - c.costs[k]
+	c.costs[k] = w
+	if c.overLimit() {
+		c.evictOverflow()
+	}
+	c.checkWatermark()
+}
@@ -0,0 +1,108 @@
+package zcache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMaxCost(t *testing.T) {
+	tc := New[string, []byte](NoExpiration, 0)
+	var evicted []string
+	tc.OnEvicted(func(k string, _ []byte) { evicted = append(evicted, k) })
+
+	tc.Set("a", make([]byte, 4))
+	tc.Set("b", make([]byte, 4))
+	tc.MaxCost(10, PolicyLRU, func(_ string, v []byte) int64 { return int64(len(v)) })
+	if cost, max := tc.Cost(); cost != 8 || max != 10 {
+		t.Fatal(cost, max)
+	}
+
+	tc.Get("a")
+	tc.Set("c", make([]byte, 4)) // Evicts b
+	tc.Set("a", make([]byte, 2)) // Replace: 6
+	tc.Set("d", make([]byte, 3)) // 9
+	if have, want := fmt.Sprint(evicted), "[b]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	if cost, _ := tc.Cost(); cost != 9 {
+		t.Error(cost)
+	}
+
+	tc.Rename("c", "x")
+	tc.Delete("d")
+	if cost, _ := tc.Cost(); cost != 6 {
+		t.Error(cost)
+	}
+
+	tc.Set("big", make([]byte, 20))
+	if have, want := keys(tc), "[big]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	if cost, _ := tc.Cost(); cost != 20 {
+		t.Error(cost)
+	}
+
+	tc.Reset()
+	if cost, _ := tc.Cost(); cost != 0 {
+		t.Error(cost)
+	}
+
+	tc.MaxCost(0, PolicyLRU, nil)
+	tc.Set("big", make([]byte, 20))
+	tc.Set("big2", make([]byte, 20))
+	if cost, max := tc.Cost(); cost != 0 || max != 0 || tc.ItemCount() != 2 {
+		t.Error(cost, max, tc.ItemCount())
+	}
+}
+
+func TestMaxCostItems(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.MaxItems(3, PolicyLRU)
+	tc.MaxCost(10, PolicyLRU, func(_ string, v int) int64 { return int64(v) })
+
+	tc.Set("a", 1)
+	tc.Set("b", 1)
+	tc.Set("c", 1)
+	tc.Set("d", 1) // Evicts a because of MaxItems.
+	tc.Set("e", 9) // Evicts b and c because of MaxCost.
+	if have, want := keys(tc), "[d e]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+
+	tc.MaxItems(0, PolicyLRU)
+	tc.Set("f", 0)
+	tc.Set("g", 0)
+	if have, want := keys(tc), "[d e f g]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestMaxCostModify(t *testing.T) {
+	tc := New[string, []byte](NoExpiration, 0)
+	var evicted []string
+	tc.OnEvicted(func(k string, _ []byte) { evicted = append(evicted, k) })
+	tc.MaxCost(10, PolicyLRU, func(_ string, v []byte) int64 { return int64(len(v)) })
+
+	tc.Set("a", make([]byte, 2))
+	tc.Set("b", make([]byte, 2))
+	tc.Modify("b", func(v []byte) []byte { return append(v, make([]byte, 4)...) })
+	if cost, _ := tc.Cost(); cost != 8 {
+		t.Error(cost)
+	}
+	tc.ModifyOrSet("b", func(v []byte) []byte { return append(v, make([]byte, 4)...) }, nil)
+	if have, want := fmt.Sprint(evicted), "[a]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	if cost, _ := tc.Cost(); cost != 10 {
+		t.Error(cost)
+	}
+
+	tc.SetLazy("lazy", func() []byte { return make([]byte, 5) }, DefaultExpiration)
+	tc.Get("lazy")
+	if have, want := fmt.Sprint(evicted), "[a b]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	if cost, _ := tc.Cost(); cost != 5 {
+		t.Error(cost)
+	}
+}
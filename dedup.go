@@ -0,0 +1,32 @@
+package zcache
+
+import (
+	"time"
+)
+
+// Dedup records keys to detect duplicates within a time window, for example
+// to drop duplicate webhooks or messages.
+type Dedup[K comparable] struct {
+	cache *Cache[K, struct{}]
+}
+
+// NewDedup creates a new deduplicator; keys outside their window are removed
+// every cleanupInterval.
+func NewDedup[K comparable](cleanupInterval time.Duration) *Dedup[K] {
+	return &Dedup[K]{cache: New[K, struct{}](NoExpiration, cleanupInterval)}
+}
+
+// Seen reports if the key was already seen within the window, and records it
+// if it wasn't.
+//
+// This is atomic: if called concurrently for the same key only one call will
+// return false. The window starts when the key is first seen, and isn't
+// extended by subsequent calls.
+func (d *Dedup[K]) Seen(k K, window time.Duration) bool {
+	return d.cache.AddWithExpire(k, struct{}{}, window) != nil
+}
+
+// Forget a key, so the next call to Seen() will return false.
+func (d *Dedup[K]) Forget(k K) {
+	d.cache.Delete(k)
+}
@@ -0,0 +1,50 @@
+package zcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	d := NewDedup[string](0)
+
+	if d.Seen("a", 10*time.Millisecond) {
+		t.Error("a seen")
+	}
+	if !d.Seen("a", 10*time.Millisecond) {
+		t.Error("a not seen")
+	}
+	if d.Seen("b", 10*time.Millisecond) {
+		t.Error("b seen")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if d.Seen("a", 10*time.Millisecond) {
+		t.Error("a seen after window")
+	}
+
+	d.Forget("a")
+	if d.Seen("a", 10*time.Millisecond) {
+		t.Error("a seen after Forget")
+	}
+
+	var (
+		wg  sync.WaitGroup
+		new int32
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !d.Seen("c", time.Minute) {
+				atomic.AddInt32(&new, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if new != 1 {
+		t.Errorf("not seen %d times", new)
+	}
+}
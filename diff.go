@@ -0,0 +1,45 @@
+package zcache
+
+import "reflect"
+
+// Changes between two sets of items, as returned by Diff().
+type Changes[K comparable, V any] struct {
+	Added   map[K]Item[V]
+	Removed map[K]Item[V]
+	Changed map[K]Change[V]
+}
+
+// Change to an item.
+type Change[V any] struct {
+	Old, New Item[V]
+}
+
+// Diff gets the changes between two sets of items, for example from two
+// successive Items() calls.
+//
+// An item is changed if either the value or the expiration is different. The
+// values are compared with reflect.DeepEqual(). The maps in Changes are never
+// nil.
+func Diff[K comparable, V any](a, b map[K]Item[V]) Changes[K, V] {
+	c := Changes[K, V]{
+		Added:   make(map[K]Item[V]),
+		Removed: make(map[K]Item[V]),
+		Changed: make(map[K]Change[V]),
+	}
+	for k, old := range a {
+		n, ok := b[k]
+		if !ok {
+			c.Removed[k] = old
+			continue
+		}
+		if old.Expiration != n.Expiration || !reflect.DeepEqual(old.Object, n.Object) {
+			c.Changed[k] = Change[V]{Old: old, New: n}
+		}
+	}
+	for k, n := range b {
+		if _, ok := a[k]; !ok {
+			c.Added[k] = n
+		}
+	}
+	return c
+}
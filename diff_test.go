@@ -0,0 +1,39 @@
+package zcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tc := New[string, []int](NoExpiration, 0)
+	tc.Set("same", []int{1})
+	tc.Set("changed", []int{1})
+	tc.Set("touched", []int{1})
+	tc.Set("removed", []int{1})
+	a := tc.Items()
+
+	tc.Set("changed", []int{2})
+	tc.TouchWithExpire("touched", 60e9)
+	tc.Delete("removed")
+	tc.Set("added", []int{1})
+	b := tc.Items()
+
+	have := Diff(a, b)
+	want := Changes[string, []int]{
+		Added:   map[string]Item[[]int]{"added": b["added"]},
+		Removed: map[string]Item[[]int]{"removed": a["removed"]},
+		Changed: map[string]Change[[]int]{
+			"changed": {Old: a["changed"], New: b["changed"]},
+			"touched": {Old: a["touched"], New: b["touched"]},
+		},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+
+	have = Diff(b, b)
+	if len(have.Added) > 0 || len(have.Removed) > 0 || len(have.Changed) > 0 {
+		t.Errorf("%v", have)
+	}
+}
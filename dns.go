@@ -0,0 +1,93 @@
+package zcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Resolver looks up the addresses for a host.
+//
+// This is like net.Resolver.LookupHost(), except that it also returns the TTL
+// of the records, which is used as the expiry in DNSCache.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, ttl time.Duration, err error)
+}
+
+// NetResolver uses net.Resolver as a Resolver.
+//
+// The net package doesn't expose the TTL of the records, so a fixed TTL is used
+// for all lookups. It will use net.DefaultResolver if r is nil.
+func NetResolver(r *net.Resolver, ttl time.Duration) Resolver {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return netResolver{r: r, ttl: ttl}
+}
+
+type netResolver struct {
+	r   *net.Resolver
+	ttl time.Duration
+}
+
+func (r netResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := r.r.LookupHost(ctx, host)
+	return addrs, r.ttl, err
+}
+
+// DNSCache caches host lookups, using the TTL of the records as the expiry.
+//
+// Lookups for hosts that don't exist (NXDOMAIN) are cached as well, for the
+// negative TTL given to NewDNSCache(). Other errors are never cached.
+type DNSCache struct {
+	cache    *Cache[string, dnsEntry]
+	resolver Resolver
+	negative time.Duration
+}
+
+type dnsEntry struct {
+	addrs []string
+	err   error
+}
+
+// NewDNSCache creates a new DNS cache which uses the given resolver.
+//
+// Hosts that don't exist are cached for negativeTTL; if this is less than 1
+// they're not cached.
+func NewDNSCache(r Resolver, negativeTTL time.Duration) *DNSCache {
+	return &DNSCache{
+		cache:    New[string, dnsEntry](NoExpiration, time.Minute),
+		resolver: r,
+		negative: negativeTTL,
+	}
+}
+
+// LookupHost looks up the given host, returning a cached result if there is
+// one.
+func (d *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if e, ok := d.cache.Get(host); ok {
+		return e.addrs, e.err
+	}
+
+	addrs, ttl, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if d.negative > 0 && errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			d.cache.SetWithExpire(host, dnsEntry{err: err}, d.negative)
+		}
+		return nil, err
+	}
+
+	// A TTL of 0 means the record shouldn't be cached; don't store it as that
+	// would mean DefaultExpiration.
+	if ttl > 0 {
+		d.cache.SetWithExpire(host, dnsEntry{addrs: addrs}, ttl)
+	}
+	return addrs, nil
+}
+
+// Forget removes the cached result for host, if any.
+func (d *DNSCache) Forget(host string) {
+	d.cache.Delete(host)
+}
@@ -0,0 +1,93 @@
+package zcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+type testResolver struct {
+	calls int
+	ttl   time.Duration
+	err   error
+}
+
+func (r *testResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	return []string{"127.0.0.1"}, r.ttl, nil
+}
+
+func TestDNSCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ttl", func(t *testing.T) {
+		r := &testResolver{ttl: 10 * time.Millisecond}
+		d := NewDNSCache(r, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			addrs, err := d.LookupHost(ctx, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%v", addrs) != "[127.0.0.1]" {
+				t.Fatalf("wrong addrs: %v", addrs)
+			}
+		}
+		if r.calls != 1 {
+			t.Errorf("calls: %d", r.calls)
+		}
+
+		time.Sleep(15 * time.Millisecond)
+		d.LookupHost(ctx, "example.com")
+		if r.calls != 2 {
+			t.Errorf("calls: %d", r.calls)
+		}
+
+		d.Forget("example.com")
+		d.LookupHost(ctx, "example.com")
+		if r.calls != 3 {
+			t.Errorf("calls: %d", r.calls)
+		}
+	})
+
+	t.Run("zero ttl", func(t *testing.T) {
+		r := &testResolver{}
+		d := NewDNSCache(r, time.Minute)
+		d.LookupHost(ctx, "example.com")
+		d.LookupHost(ctx, "example.com")
+		if r.calls != 2 {
+			t.Errorf("calls: %d", r.calls)
+		}
+	})
+
+	t.Run("nxdomain", func(t *testing.T) {
+		r := &testResolver{err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}
+		d := NewDNSCache(r, time.Minute)
+		for i := 0; i < 3; i++ {
+			_, err := d.LookupHost(ctx, "example.com")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) {
+				t.Fatalf("wrong error: %v", err)
+			}
+		}
+		if r.calls != 1 {
+			t.Errorf("calls: %d", r.calls)
+		}
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		r := &testResolver{err: errors.New("oh noes")}
+		d := NewDNSCache(r, time.Minute)
+		d.LookupHost(ctx, "example.com")
+		d.LookupHost(ctx, "example.com")
+		if r.calls != 2 {
+			t.Errorf("calls: %d", r.calls)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package zcache
+
+import (
+	"time"
+)
+
+// DualCache is a cache where every item is stored under two keys.
+//
+// This is useful if you want to look up the same items by two different keys,
+// for example a session by both the session ID and the user ID. Unlike Proxy
+// both keys are primary keys: setting, deleting, and expiring an item always
+// affects both keys, and every key maps to at most one item.
+type DualCache[K1, K2 comparable, V any] struct {
+	cache *Cache[K1, dualItem[K2, V]]
+	index map[K2]K1 // Protected by cache.mu
+}
+
+type dualItem[K2 comparable, V any] struct {
+	k2 K2
+	v  V
+}
+
+// NewDual creates a new cache with two keys, with the given expiration duration
+// and cleanup interval.
+//
+// See New() for the meaning of the parameters.
+func NewDual[K1, K2 comparable, V any](defaultExpiration, cleanupInterval time.Duration) *DualCache[K1, K2, V] {
+	d := &DualCache[K1, K2, V]{
+		cache: New[K1, dualItem[K2, V]](defaultExpiration, cleanupInterval),
+		index: make(map[K2]K1),
+	}
+	d.cache.OnEvicted(d.evicted)
+	return d
+}
+
+// Set an item under both keys, replacing any existing items for either key.
+func (d *DualCache[K1, K2, V]) Set(k1 K1, k2 K2, v V) {
+	d.SetWithExpire(k1, k2, v, DefaultExpiration)
+}
+
+// SetWithExpire sets an item under both keys, replacing any existing items for
+// either key.
+//
+// If the duration is 0 (DefaultExpiration), the cache's default expiration time
+// is used. If it is -1 (NoExpiration), the item never expires.
+func (d *DualCache[K1, K2, V]) SetWithExpire(k1 K1, k2 K2, v V, dur time.Duration) {
+	c := d.cache
+	c.mu.Lock()
+	defer c.unlock()
+
+	if old, ok := c.items[k1]; ok && old.Object.k2 != k2 {
+		delete(d.index, old.Object.k2)
+	}
+	if old, ok := d.index[k2]; ok && old != k1 {
+		c.delete(old) // The index is updated here, so don't need the callback.
+	}
+	c.set(k1, dualItem[K2, V]{k2: k2, v: v}, dur)
+	d.index[k2] = k1
+}
+
+// Get1 gets an item by the first key.
+//
+// Returns the item or the zero value, the second key, and a bool indicating
+// whether the key is set.
+func (d *DualCache[K1, K2, V]) Get1(k1 K1) (V, K2, bool) {
+	c := d.cache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return d.get(k1)
+}
+
+// Get2 gets an item by the second key.
+//
+// Returns the item or the zero value, the first key, and a bool indicating
+// whether the key is set.
+func (d *DualCache[K1, K2, V]) Get2(k2 K2) (V, K1, bool) {
+	c := d.cache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var zero K1
+	k1, ok := d.index[k2]
+	if !ok {
+		return c.zero().v, zero, false
+	}
+	// The index may be stale if k1 was set again before evicted() ran.
+	v, cur, ok := d.get(k1)
+	if !ok || cur != k2 {
+		return c.zero().v, zero, false
+	}
+	return v, k1, true
+}
+
+// Delete1 deletes an item by the first key.
+//
+// Does nothing if the key is not in the cache.
+func (d *DualCache[K1, K2, V]) Delete1(k1 K1) {
+	c := d.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d.delete(k1)
+}
+
+// Delete2 deletes an item by the second key.
+//
+// Does nothing if the key is not in the cache.
+func (d *DualCache[K1, K2, V]) Delete2(k2 K2) {
+	c := d.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k1, ok := d.index[k2]; ok {
+		d.delete(k1)
+	}
+}
+
+// ItemCount returns the number of items in the cache.
+//
+// This may include items that have expired but have not yet been cleaned up.
+func (d *DualCache[K1, K2, V]) ItemCount() int {
+	return d.cache.ItemCount()
+}
+
+// DeleteExpired deletes all expired items from the cache.
+func (d *DualCache[K1, K2, V]) DeleteExpired() {
+	d.cache.DeleteExpired()
+}
+
+// Reset deletes all items from the cache.
+func (d *DualCache[K1, K2, V]) Reset() {
+	c := d.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.items {
+		delete(c.items, k)
+	}
+	c.expiring = 0
+	c.writtenAll()
+	d.index = make(map[K2]K1)
+}
+
+func (d *DualCache[K1, K2, V]) get(k1 K1) (V, K2, bool) {
+	c := d.cache
+	item, ok := c.get(k1)
+	if !ok {
+		var zero K2
+		return item.v, zero, false
+	}
+	return item.v, item.k2, true
+}
+
+func (d *DualCache[K1, K2, V]) delete(k1 K1) {
+	c := d.cache
+	if item, ok := c.items[k1]; ok {
+		delete(d.index, item.Object.k2)
+		c.delete(k1)
+	}
+}
+
+// evicted removes the second key from the index when the janitor deletes
+// expired items.
+func (d *DualCache[K1, K2, V]) evicted(k1 K1, item dualItem[K2, V]) {
+	c := d.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur, ok := d.index[item.k2]; ok && cur == k1 {
+		// k1 may have been set again with a different second key.
+		if it, ok := c.items[k1]; !ok || it.Object.k2 != item.k2 {
+			delete(d.index, item.k2)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDualCache(t *testing.T) {
+	d := NewDual[string, int, string](NoExpiration, 0)
+
+	has := func(k1 string, k2 int, want string) {
+		t.Helper()
+		if v, k, ok := d.Get1(k1); !ok || v != want || k != k2 {
+			t.Errorf("Get1(%q): %q, %d, %t", k1, v, k, ok)
+		}
+		if v, k, ok := d.Get2(k2); !ok || v != want || k != k1 {
+			t.Errorf("Get2(%d): %q, %q, %t", k2, v, k, ok)
+		}
+	}
+	not1 := func(k1 string) {
+		t.Helper()
+		if v, k, ok := d.Get1(k1); ok || v != "" || k != 0 {
+			t.Errorf("Get1(%q): %q, %d, %t", k1, v, k, ok)
+		}
+	}
+	not2 := func(k2 int) {
+		t.Helper()
+		if v, k, ok := d.Get2(k2); ok || v != "" || k != "" {
+			t.Errorf("Get2(%d): %q, %q, %t", k2, v, k, ok)
+		}
+	}
+
+	d.Set("a", 1, "one")
+	d.Set("b", 2, "two")
+	has("a", 1, "one")
+	has("b", 2, "two")
+
+	// Replace second key.
+	d.Set("a", 3, "three")
+	has("a", 3, "three")
+	not2(1)
+
+	// Replace first key; this should remove "b".
+	d.Set("c", 2, "two")
+	has("c", 2, "two")
+	not1("b")
+	if n := d.ItemCount(); n != 2 {
+		t.Errorf("ItemCount: %d", n)
+	}
+
+	d.Delete1("a")
+	not1("a")
+	not2(3)
+	d.Delete2(2)
+	not1("c")
+	not2(2)
+
+	d.SetWithExpire("x", 10, "ten", 1)
+	time.Sleep(time.Millisecond)
+	not1("x")
+	not2(10)
+	d.DeleteExpired()
+	if len(d.index) != 0 || d.ItemCount() != 0 {
+		t.Errorf("not deleted: %v; %d", d.index, d.ItemCount())
+	}
+
+	d.Set("a", 1, "one")
+	d.Reset()
+	not1("a")
+	not2(1)
+
+	// The janitor deleted the item, and "r" was set again with a different
+	// second key before the OnEvicted callback ran.
+	d.Set("r", 20, "old")
+	d.cache.mu.Lock()
+	old := d.cache.items["r"].Object
+	d.cache.delete("r")
+	d.cache.mu.Unlock()
+	d.Set("r", 21, "new")
+	not2(20)
+	d.evicted("r", old)
+	not2(20)
+	has("r", 21, "new")
+	if _, ok := d.index[20]; ok {
+		t.Errorf("stale index: %v", d.index)
+	}
+}
@@ -0,0 +1,72 @@
+package zcache
+
+import "time"
+
+// Entry sets an item with optional attributes; it's created with
+// Cache.Entry().
+//
+// For example:
+//
+//	c.Entry("key").TTL(5 * time.Minute).MaxUses(1).Set(value)
+//
+// This is equivalent to the various SetWith* methods, but allows combining the
+// attributes. Nothing is stored until Set() is called.
+type Entry[K comparable, V any] struct {
+	c       *cache[K, V]
+	k       K
+	d       time.Duration
+	onEvict func(K, V)
+	uses    int
+}
+
+// Entry creates a new Entry to set the key k.
+//
+// The item will be stored with the default expiration unless TTL() is used.
+func (c *cache[K, V]) Entry(k K) Entry[K, V] {
+	return Entry[K, V]{c: c, k: k}
+}
+
+// TTL sets the expiration duration; this is used as with SetWithExpire().
+func (e Entry[K, V]) TTL(d time.Duration) Entry[K, V] {
+	e.d = d
+	return e
+}
+
+// OnEvict sets the callback to run when the item is evicted; see
+// SetWithEvict().
+func (e Entry[K, V]) OnEvict(f func(K, V)) Entry[K, V] {
+	e.onEvict = f
+	return e
+}
+
+// MaxUses sets the number of times the item can be retrieved; see
+// SetWithMaxUses().
+func (e Entry[K, V]) MaxUses(n int) Entry[K, V] {
+	e.uses = n
+	return e
+}
+
+// Set stores the value, replacing any existing item.
+func (e Entry[K, V]) Set(v V) {
+	c := e.c
+	c.mu.Lock()
+	defer c.unlock()
+	k := c.key(e.k)
+	if c.rejectWrite() || c.checkKey("zcache.Set", k) != nil {
+		return
+	}
+
+	c.set(k, v, e.d)
+	if e.onEvict != nil {
+		if c.evictFuncs == nil {
+			c.evictFuncs = make(map[K]func(K, V))
+		}
+		c.evictFuncs[k] = e.onEvict
+	}
+	if e.uses > 0 {
+		if c.uses == nil {
+			c.uses = make(map[K]int)
+		}
+		c.uses[k] = e.uses
+	}
+}
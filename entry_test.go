@@ -0,0 +1,31 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEntry(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var evicted int
+	tc.Entry("a").TTL(time.Hour).MaxUses(2).OnEvict(func(string, int) { evicted++ }).Set(1)
+	tc.Entry("b").Set(2)
+
+	if _, exp, ok := tc.GetWithExpire("a"); !ok || time.Until(exp) < 59*time.Minute {
+		t.Fatalf("%s %t", exp, ok)
+	}
+	if _, ok := tc.Get("a"); !ok {
+		t.Fatal("not found")
+	}
+	if _, ok := tc.Get("a"); ok {
+		t.Fatal("found after max uses")
+	}
+	if evicted != 1 {
+		t.Errorf("evicted: %d", evicted)
+	}
+
+	if _, exp, ok := tc.GetWithExpire("b"); !ok || !exp.IsZero() {
+		t.Fatalf("%s %t", exp, ok)
+	}
+}
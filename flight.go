@@ -0,0 +1,107 @@
+package zcache
+
+import (
+	"context"
+	"sync"
+)
+
+// call is an in-flight or completed call in a group.
+type call[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+	dups int // Number of callers that joined this call; protected by group.mu.
+}
+
+// wait for the call to complete, or until the context is cancelled.
+func (c *call[V]) wait(ctx context.Context) (V, error) {
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// group makes sure there is only one call in-flight for a key.
+type group[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]*call[V]
+}
+
+// start running f for the key in a new goroutine, unless there's already a call
+// in-flight for this key, in which case that call is returned.
+//
+// The call is removed from the group once f returns.
+func (g *group[K, V]) start(k K, f func() (V, error)) *call[V] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.m[k]; ok {
+		return c
+	}
+	if g.m == nil {
+		g.m = make(map[K]*call[V])
+	}
+
+	c := &call[V]{done: make(chan struct{})}
+	g.m[k] = c
+	go func() {
+		c.val, c.err = f()
+		g.mu.Lock()
+		delete(g.m, k)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	return c
+}
+
+// claim the key, returning a new call and true if there's no call in-flight
+// for this key, or the in-flight call and false if there is.
+//
+// The caller must call finish() if it claimed the key.
+func (g *group[K, V]) claim(k K) (*call[V], bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.m[k]; ok {
+		c.dups++
+		return c, false
+	}
+	if g.m == nil {
+		g.m = make(map[K]*call[V])
+	}
+	c := &call[V]{done: make(chan struct{})}
+	g.m[k] = c
+	return c, true
+}
+
+// finish a call claimed with claim(), setting the result and removing it from
+// the group.
+func (g *group[K, V]) finish(k K, c *call[V], val V, err error) {
+	c.val, c.err = val, err
+	g.mu.Lock()
+	if g.m[k] == c {
+		delete(g.m, k)
+	}
+	g.mu.Unlock()
+	close(c.done)
+}
+
+// do runs f for the key and returns the result, unless there's already a call
+// in-flight for this key, in which case it waits for that call and returns its
+// result.
+func (g *group[K, V]) do(k K, f func() (V, error)) (V, error) {
+	c, owner := g.claim(k)
+	if !owner {
+		<-c.done
+		return c.val, c.err
+	}
+
+	var (
+		val V
+		err error
+	)
+	defer func() { g.finish(k, c, val, err) }()
+	val, err = f()
+	return val, err
+}
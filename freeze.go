@@ -0,0 +1,51 @@
+package zcache
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrFrozen is returned by methods that modify the cache while it's frozen.
+var ErrFrozen = errors.New("zcache: cache is frozen")
+
+// Freeze the cache, making it read-only until Unfreeze() is called.
+//
+// All methods that modify the cache do nothing while it's frozen; methods that
+// return an error return ErrFrozen, and methods that return a bool to indicate
+// if something was changed return false. Loaders such as GetOrSet() still run
+// and return their value, but it's not stored. Items are still deleted when
+// they expire and by SetWithMaxUses().
+//
+// This is useful to stop a misbehaving upstream from overwriting good data, or
+// to inspect the cache while debugging. Frozen() reports how many writes were
+// rejected.
+func (c *cache[K, V]) Freeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = true
+}
+
+// Unfreeze the cache after Freeze().
+func (c *cache[K, V]) Unfreeze() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frozen = false
+}
+
+// Frozen reports if the cache is frozen, and the number of writes that were
+// rejected because the cache was frozen since it was created.
+func (c *cache[K, V]) Frozen() (frozen bool, rejected uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.frozen, atomic.LoadUint64(&c.frozenWrites)
+}
+
+// rejectWrite reports if the cache is frozen, counting the rejected write if it
+// is; the (read) lock must be held.
+func (c *cache[K, V]) rejectWrite() bool {
+	if !c.frozen {
+		return false
+	}
+	atomic.AddUint64(&c.frozenWrites, 1)
+	return true
+}
@@ -0,0 +1,62 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreeze(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.Freeze()
+
+	tc.Set("a", 2)
+	tc.Set("b", 2)
+	tc.Delete("a")
+	tc.SetWithEvict("c", 3, DefaultExpiration, nil)
+	if err := tc.Add("d", 4); err != ErrFrozen {
+		t.Error(err)
+	}
+	if err := tc.Replace("a", 4); err != ErrFrozen {
+		t.Error(err)
+	}
+	if _, ok := tc.Modify("a", func(v int) int { return v + 1 }); ok {
+		t.Error("Modify returned true")
+	}
+	if _, ok := tc.Pop("a"); ok {
+		t.Error("Pop returned true")
+	}
+	if tc.Rename("a", "x") {
+		t.Error("Rename returned true")
+	}
+	if v := tc.GetOrAdd("e", 5); v != 5 {
+		t.Error(v)
+	}
+	if v := tc.ModifyOrSet("a", func(v int) int { return v + 1 }, 0); v != 1 {
+		t.Error(v)
+	}
+	if v := tc.GetOrSet("f", func() (int, time.Duration) { return 6, DefaultExpiration }); v != 6 {
+		t.Error(v)
+	}
+	tc.Entry("g").Set(7)
+	if m := tc.DeleteAll(); len(m) != 0 {
+		t.Error(m)
+	}
+	tc.Reset()
+
+	if have := tc.Items(); len(have) != 1 || have["a"].Object != 1 {
+		t.Errorf("modified while frozen: %v", have)
+	}
+	if frozen, rejected := tc.Frozen(); !frozen || rejected != 15 {
+		t.Errorf("%t %d", frozen, rejected)
+	}
+
+	tc.Unfreeze()
+	tc.Set("a", 2)
+	if v, _ := tc.Get("a"); v != 2 {
+		t.Error(v)
+	}
+	if frozen, rejected := tc.Frozen(); frozen || rejected != 15 {
+		t.Errorf("%t %d", frozen, rejected)
+	}
+}
@@ -0,0 +1,104 @@
+package zcache
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// Snapshot of the cache, as returned by History().
+//
+// The Object of every item is a hash of the value, rather than the value
+// itself; two snapshots can be compared with Diff().
+type Snapshot[K comparable] struct {
+	Time  time.Time
+	Items map[K]Item[uint64]
+}
+
+type history[K comparable] struct {
+	ring []Snapshot[K] // Protected by cache.mu
+	next int
+	stop chan struct{}
+}
+
+// KeepHistory keeps the last n snapshots of the cache, taken every interval,
+// which can be retrieved with History().
+//
+// The snapshots contain only the keys, expiration, and a hash of the values,
+// but taking a snapshot does lock the cache for reading while all values are
+// hashed. The hash is of the value formatted with %#v, so changes to values
+// that are pointers aren't detected unless the pointer changes.
+//
+// This is useful to find out when a key disappeared or changed while debugging.
+// Calling this again discards all snapshots. Use 0 to stop taking snapshots;
+// it also stops if the cache is garbage collected.
+func (c *cache[K, V]) KeepHistory(n int, interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.history != nil {
+		close(c.history.stop)
+		c.history = nil
+	}
+	if n < 1 || interval <= 0 {
+		return
+	}
+
+	h := &history[K]{ring: make([]Snapshot[K], 0, n), stop: make(chan struct{})}
+	c.history = h
+	h.add(c.snapshot())
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				c.mu.RLock()
+				s := c.snapshot()
+				c.mu.RUnlock()
+				c.mu.Lock()
+				if c.history == h {
+					h.add(s)
+				}
+				c.mu.Unlock()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+// History gets the snapshots taken with KeepHistory(), oldest first.
+func (c *cache[K, V]) History() []Snapshot[K] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.history == nil {
+		return nil
+	}
+	h := c.history
+	s := make([]Snapshot[K], 0, len(h.ring))
+	s = append(s, h.ring[h.next:]...)
+	return append(s, h.ring[:h.next]...)
+}
+
+// add a snapshot, replacing the oldest one if the ring is full; the lock must
+// be held.
+func (h *history[K]) add(s Snapshot[K]) {
+	if len(h.ring) < cap(h.ring) {
+		h.ring = append(h.ring, s)
+		return
+	}
+	h.ring[h.next] = s
+	h.next = (h.next + 1) % len(h.ring)
+}
+
+// snapshot the cache; the (read) lock must be held.
+func (c *cache[K, V]) snapshot() Snapshot[K] {
+	s := Snapshot[K]{Time: time.Now(), Items: make(map[K]Item[uint64], len(c.items))}
+	h := fnv.New64a()
+	for k, v := range c.items {
+		h.Reset()
+		fmt.Fprintf(h, "%#v", v.Object)
+		s.Items[k] = Item[uint64]{Object: h.Sum64(), Expiration: v.Expiration}
+	}
+	return s
+}
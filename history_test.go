@@ -0,0 +1,51 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeepHistory(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	defer tc.KeepHistory(0, 0)
+	if h := tc.History(); h != nil {
+		t.Fatal(h)
+	}
+
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.KeepHistory(3, 20*time.Millisecond)
+	tc.Set("a", 3)
+	tc.Delete("b")
+	tc.Set("c", 4)
+	time.Sleep(30 * time.Millisecond)
+
+	h := tc.History()
+	if len(h) != 2 {
+		t.Fatalf("len: %d", len(h))
+	}
+	if !h[0].Time.Before(h[1].Time) {
+		t.Error("wrong order")
+	}
+	d := Diff(h[0].Items, h[1].Items)
+	if len(d.Added) != 1 || len(d.Removed) != 1 || len(d.Changed) != 1 {
+		t.Errorf("%+v", d)
+	}
+	if _, ok := d.Changed["a"]; !ok {
+		t.Errorf("%+v", d)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	h = tc.History()
+	if len(h) != 3 {
+		t.Fatalf("len: %d", len(h))
+	}
+	if !h[0].Time.Before(h[1].Time) || !h[1].Time.Before(h[2].Time) {
+		t.Error("wrong order")
+	}
+
+	tc.KeepHistory(0, 0)
+	if h := tc.History(); h != nil {
+		t.Fatal(h)
+	}
+}
@@ -0,0 +1,96 @@
+package zcache
+
+import (
+	"sync"
+	"time"
+)
+
+// KeyStats are statistics for a single key, as tracked with TrackKeys().
+type KeyStats struct {
+	Hits       int           // Number of Get() calls that returned the item.
+	Misses     int           // Number of Get() calls for a key that wasn't set or expired.
+	AvgLatency time.Duration // Average time Get() took, excluding waiting for the lock.
+	MaxLatency time.Duration // Longest time Get() took.
+}
+
+type keyStats[K comparable] struct {
+	mu    sync.Mutex
+	stats map[K]KeyStats
+}
+
+func newKeyStats[K comparable](keys []K) *keyStats[K] {
+	s := &keyStats[K]{stats: make(map[K]KeyStats, len(keys))}
+	for _, k := range keys {
+		s.stats[k] = KeyStats{}
+	}
+	return s
+}
+
+func (s *keyStats[K]) tracked(k K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.stats[k]
+	return ok
+}
+
+// record a Get() for k.
+func (s *keyStats[K]) record(k K, hit bool, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats[k]
+	st.AvgLatency = avg(st.AvgLatency, st.Hits+st.Misses, took)
+	if took > st.MaxLatency {
+		st.MaxLatency = took
+	}
+	if hit {
+		st.Hits++
+	} else {
+		st.Misses++
+	}
+	s.stats[k] = st
+}
+
+func (s *keyStats[K]) copy() map[K]KeyStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[K]KeyStats, len(s.stats))
+	for k, v := range s.stats {
+		m[k] = v
+	}
+	return m
+}
+
+// TrackKeys enables tracking hits, misses, and latency of Get() for the given
+// keys, which can be retrieved with KeyStats().
+//
+// This is intended for a small number of important keys; use TrackTTL() for
+// statistics on all keys. This replaces the keys from any previous call and
+// discards all statistics collected so far. Call without keys to disable it.
+func (c *cache[K, V]) TrackKeys(keys ...K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.storeFilter()
+	if len(keys) == 0 {
+		c.keyStats = nil
+		return
+	}
+	norm := make([]K, 0, len(keys))
+	for _, k := range keys {
+		norm = append(norm, c.key(k))
+	}
+	c.keyStats = newKeyStats(norm)
+}
+
+// KeyStats gets the statistics for all keys tracked with TrackKeys().
+//
+// Keys that are tracked but haven't been retrieved yet are included with zero
+// values. Returns nil if no keys are tracked.
+func (c *cache[K, V]) KeyStats() map[K]KeyStats {
+	c.mu.RLock()
+	s := c.keyStats
+	c.mu.RUnlock()
+	if s == nil {
+		return nil
+	}
+	return s.copy()
+}
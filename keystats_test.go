@@ -0,0 +1,38 @@
+package zcache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrackKeys(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	if s := tc.KeyStats(); s != nil {
+		t.Fatal(s)
+	}
+
+	tc.KeyNormalizer(strings.ToLower)
+	tc.TrackKeys("A", "b")
+	tc.Set("a", 1)
+	tc.Set("c", 3)
+	tc.Get("a")
+	tc.Get("A")
+	tc.Get("b")
+	tc.Get("c")
+
+	s := tc.KeyStats()
+	if len(s) != 2 {
+		t.Fatal(s)
+	}
+	if a := s["a"]; a.Hits != 2 || a.Misses != 0 || a.MaxLatency <= 0 || a.AvgLatency > a.MaxLatency {
+		t.Errorf("a: %+v", a)
+	}
+	if b := s["b"]; b.Hits != 0 || b.Misses != 1 {
+		t.Errorf("b: %+v", b)
+	}
+
+	tc.TrackKeys()
+	if s := tc.KeyStats(); s != nil {
+		t.Error(s)
+	}
+}
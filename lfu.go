@@ -0,0 +1,200 @@
+package zcache
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type lfu[K comparable] struct {
+	mu     sync.Mutex
+	counts map[K]uint32
+	decay  time.Duration
+	last   time.Time
+}
+
+func newLFU[K comparable](decay time.Duration) *lfu[K] {
+	return &lfu[K]{counts: make(map[K]uint32), decay: decay, last: time.Now()}
+}
+
+// hit records that k was retrieved.
+func (l *lfu[K]) hit(k K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maybeDecay()
+	if n := l.counts[k]; n < math.MaxUint32 {
+		l.counts[k] = n + 1
+	}
+}
+
+// take removes the count for k and returns it.
+func (l *lfu[K]) take(k K) uint32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.counts[k]
+	delete(l.counts, k)
+	return n
+}
+
+// put sets the count for k.
+func (l *lfu[K]) put(k K, n uint32) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n == 0 {
+		delete(l.counts, k)
+	} else {
+		l.counts[k] = n
+	}
+}
+
+// removeAll removes the counts for all keys.
+func (l *lfu[K]) removeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.counts {
+		delete(l.counts, k)
+	}
+}
+
+func (l *lfu[K]) get(k K) uint32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maybeDecay()
+	return l.counts[k]
+}
+
+// maybeDecay halves all counts once for every decay period that has passed
+// since the last decay; the lock must be held.
+func (l *lfu[K]) maybeDecay() {
+	if l.decay <= 0 {
+		return
+	}
+	n := time.Since(l.last) / l.decay
+	if n <= 0 {
+		return
+	}
+	l.last = l.last.Add(n * l.decay)
+	shift := n
+	if shift > 32 {
+		shift = 32
+	}
+	for k, c := range l.counts {
+		c >>= shift
+		if c == 0 {
+			delete(l.counts, k)
+		} else {
+			l.counts[k] = c
+		}
+	}
+}
+
+// TrackFrequency enables or disables counting how often every key is
+// retrieved, which is used by EvictLFU() and can be retrieved with Frequency().
+//
+// All counts are halved once every decay period, so that keys that were used a
+// lot in the past but not recently don't stay around forever. The counts never
+// decay if the decay is 0 or lower.
+//
+// This adds some overhead to every operation, so it's disabled by default.
+// Disabling it discards all counts collected so far.
+func (c *cache[K, V]) TrackFrequency(enable bool, decay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case enable && c.lfu == nil:
+		c.lfu = newLFU[K](decay)
+	case enable:
+		c.lfu.mu.Lock()
+		c.lfu.decay = decay
+		c.lfu.mu.Unlock()
+	case !enable:
+		c.lfu = nil
+	}
+}
+
+// Frequency gets how often the key was retrieved since it was added to the
+// cache, after decay.
+//
+// Replacing the value with Set(), Modify(), etc. doesn't reset the count; it's
+// only reset once the key is deleted from the cache.
+//
+// This is always 0 unless TrackFrequency(true, ...) was called.
+func (c *cache[K, V]) Frequency(k K) uint32 {
+	c.mu.RLock()
+	k = c.key(k)
+	l := c.lfu
+	c.mu.RUnlock()
+	if l == nil {
+		return 0
+	}
+	return l.get(k)
+}
+
+// EvictLFU deletes the n least frequently used items, returning the number of
+// deleted items.
+//
+// Items with the same frequency are deleted in the order they expire, with
+// items that never expire last. All items have a frequency of 0 if
+// TrackFrequency() isn't enabled.
+//
+// This calls the OnEvicted callbacks for the deleted items.
+func (c *cache[K, V]) EvictLFU(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	var evictedItems []keyAndValue[K, V]
+	c.mu.Lock()
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return 0
+	}
+	type freq struct {
+		k K
+		f uint32
+		e int64
+	}
+	all := make([]freq, 0, len(c.items))
+	if c.lfu != nil {
+		c.lfu.mu.Lock()
+		c.lfu.maybeDecay()
+	}
+	for k, v := range c.items {
+		e := v.Expiration
+		if e <= 0 {
+			e = math.MaxInt64
+		}
+		var f uint32
+		if c.lfu != nil {
+			f = c.lfu.counts[k]
+		}
+		all = append(all, freq{k, f, e})
+	}
+	if c.lfu != nil {
+		c.lfu.mu.Unlock()
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].f == all[j].f {
+			return all[i].e < all[j].e
+		}
+		return all[i].f < all[j].f
+	})
+
+	if n > len(all) {
+		n = len(all)
+	}
+	for _, f := range all[:n] {
+		ov, onEvict := c.delete(f.k)
+		if onEvict != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{f.k, ov, onEvict})
+		}
+	}
+	c.sortEvicted(evictedItems)
+	c.mu.Unlock()
+
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
+	}
+	return n
+}
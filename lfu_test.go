@@ -0,0 +1,75 @@
+package zcache
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestEvictLFU(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.TrackFrequency(true, 0)
+
+	for i, k := range []string{"a", "b", "c", "d"} {
+		tc.Set(k, i)
+		for j := 0; j < i; j++ {
+			tc.Get(k)
+		}
+	}
+	tc.SetWithExpire("e", 5, time.Hour) // Expires, so evicted before "a".
+
+	if f := tc.Frequency("c"); f != 2 {
+		t.Errorf("frequency: %d", f)
+	}
+	tc.Set("c", 2)
+	if f := tc.Frequency("c"); f != 2 {
+		t.Errorf("frequency reset by Set(): %d", f)
+	}
+
+	var evicted []string
+	tc.OnEvicted(func(k string, _ int) { evicted = append(evicted, k) })
+	if n := tc.EvictLFU(3); n != 3 {
+		t.Errorf("evicted %d", n)
+	}
+	sort.Strings(evicted)
+	if have, want := fmt.Sprint(evicted), "[a b e]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	if tc.Frequency("a") != 0 {
+		t.Error("count not removed")
+	}
+
+	if !tc.Rename("d", "x") || tc.Frequency("x") != 3 {
+		t.Errorf("count not moved: %d", tc.Frequency("x"))
+	}
+
+	if n := tc.EvictLFU(10); n != 2 {
+		t.Errorf("evicted %d", n)
+	}
+	if tc.ItemCount() != 0 {
+		t.Error(tc.Items())
+	}
+}
+
+func TestTrackFrequencyDecay(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.TrackFrequency(true, 50*time.Millisecond)
+
+	tc.Set("a", 1)
+	for i := 0; i < 8; i++ {
+		tc.Get("a")
+	}
+	if f := tc.Frequency("a"); f != 8 {
+		t.Fatal(f)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if f := tc.Frequency("a"); f != 4 {
+		t.Error(f)
+	}
+
+	tc.TrackFrequency(false, 0)
+	if f := tc.Frequency("a"); f != 0 {
+		t.Error(f)
+	}
+}
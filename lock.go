@@ -0,0 +1,68 @@
+package zcache
+
+import (
+	"time"
+)
+
+type keyLock struct {
+	token   string
+	expires int64 // 0 if it never expires.
+}
+
+// expired reports if the lock has expired.
+func (l keyLock) expired(now int64) bool {
+	return l.expires > 0 && now > l.expires
+}
+
+// TryLockKey tries to acquire an advisory lock for the key, which is
+// automatically released after ttl.
+//
+// Use NoExpiration to keep the lock until UnlockKey() is called. It always
+// returns false for other ttl values lower than 1, or panics for negative
+// values in strict mode.
+//
+// It returns a token to use with UnlockKey() and true if the lock was acquired,
+// or false if the key is already locked. This can be used to make sure that
+// only one goroutine does something, for example run a scheduled job.
+//
+// The locks are independent of the items in the cache: locking a key doesn't
+// affect the item for that key, and the key doesn't need to be set.
+func (c *cache[K, V]) TryLockKey(k K, ttl time.Duration) (string, bool) {
+	now := c.nanotime()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k = c.key(k)
+	if ttl == 0 || c.checkDuration("zcache.TryLockKey", ttl) != nil {
+		return "", false
+	}
+
+	if l, ok := c.locks[k]; ok && !l.expired(now) {
+		return "", false
+	}
+	if c.locks == nil {
+		c.locks = make(map[K]keyLock)
+	}
+	l := keyLock{token: randomToken("zcache.TryLockKey")}
+	if ttl > 0 {
+		l.expires = now + int64(ttl)
+	}
+	c.locks[k] = l
+	return l.token, true
+}
+
+// UnlockKey releases the lock for the key acquired with TryLockKey().
+//
+// It returns false if the token doesn't match, which is the case if the lock
+// already expired and was acquired by someone else.
+func (c *cache[K, V]) UnlockKey(k K, token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k = c.key(k)
+
+	l, ok := c.locks[k]
+	if !ok || l.token != token {
+		return false
+	}
+	delete(c.locks, k)
+	return true
+}
@@ -0,0 +1,95 @@
+package zcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTryLockKey(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	tok, ok := tc.TryLockKey("job", time.Minute)
+	if !ok || tok == "" {
+		t.Fatalf("not locked: %q", tok)
+	}
+	if _, ok := tc.TryLockKey("job", time.Minute); ok {
+		t.Fatal("locked twice")
+	}
+	if _, ok := tc.TryLockKey("other", time.Minute); !ok {
+		t.Fatal("other not locked")
+	}
+
+	if tc.UnlockKey("job", "wrong") {
+		t.Fatal("unlocked with wrong token")
+	}
+	if !tc.UnlockKey("job", tok) {
+		t.Fatal("not unlocked")
+	}
+	if tc.UnlockKey("job", tok) {
+		t.Fatal("unlocked twice")
+	}
+
+	// Expired lock can be acquired again, and the old token is no longer valid.
+	tok, _ = tc.TryLockKey("job", 1)
+	time.Sleep(time.Millisecond)
+	tok2, ok := tc.TryLockKey("job", time.Minute)
+	if !ok {
+		t.Fatal("expired lock not acquired")
+	}
+	if tc.UnlockKey("job", tok) {
+		t.Fatal("unlocked with expired token")
+	}
+	tc.UnlockKey("job", tok2)
+
+	// NoExpiration keeps the lock until it's unlocked.
+	tok, ok = tc.TryLockKey("forever", NoExpiration)
+	if !ok {
+		t.Fatal("not locked with NoExpiration")
+	}
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	if _, ok := tc.TryLockKey("forever", time.Minute); ok {
+		t.Fatal("NoExpiration lock acquired twice")
+	}
+	if !tc.UnlockKey("forever", tok) {
+		t.Fatal("NoExpiration lock not unlocked")
+	}
+
+	// Other durations lower than 1 are rejected.
+	for _, ttl := range []time.Duration{0, -5 * time.Second} {
+		if tok, ok := tc.TryLockKey("invalid", ttl); ok || tok != "" {
+			t.Errorf("%s: locked: %q", ttl, tok)
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		locked int32
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := tc.TryLockKey("job", time.Minute); ok {
+				atomic.AddInt32(&locked, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if locked != 1 {
+		t.Errorf("locked %d times", locked)
+	}
+}
+
+func TestTryLockKeyDeleteExpired(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.TryLockKey("a", 1)
+	tc.TryLockKey("b", time.Minute)
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	if len(tc.locks) != 1 {
+		t.Errorf("%v", tc.locks)
+	}
+}
@@ -0,0 +1,159 @@
+package zcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// EvictionPolicy is the policy to evict items with if there are more items than
+// the maximum set with MaxItems(), or if the total cost is higher than the
+// maximum set with MaxCost().
+type EvictionPolicy uint8
+
+// Eviction policies.
+const (
+	PolicyLRU  EvictionPolicy = iota // Least recently set or retrieved item first.
+	PolicyFIFO                       // Least recently set item first.
+)
+
+// lru keeps the keys in order of use.
+type lru[K comparable] struct {
+	mu    sync.Mutex
+	order *list.List // Most recent first.
+	elems map[K]*list.Element
+	onGet bool
+}
+
+func newLRU[K comparable](onGet bool) *lru[K] {
+	return &lru[K]{order: list.New(), elems: make(map[K]*list.Element), onGet: onGet}
+}
+
+// set records that k was set.
+func (l *lru[K]) set(k K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.elems[k]; ok {
+		l.order.MoveToFront(e)
+		return
+	}
+	l.elems[k] = l.order.PushFront(k)
+}
+
+// get records that k was retrieved.
+func (l *lru[K]) get(k K) {
+	if !l.onGet {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.elems[k]; ok {
+		l.order.MoveToFront(e)
+	}
+}
+
+// remove records that k was removed from the cache.
+func (l *lru[K]) remove(k K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.elems[k]; ok {
+		l.order.Remove(e)
+		delete(l.elems, k)
+	}
+}
+
+// removeAll records that all keys were removed from the cache.
+func (l *lru[K]) removeAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.order.Init()
+	for k := range l.elems {
+		delete(l.elems, k)
+	}
+}
+
+// oldest gets the least recently used key.
+func (l *lru[K]) oldest() (K, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.order.Back()
+	if e == nil {
+		var zero K
+		return zero, false
+	}
+	return e.Value.(K), true
+}
+
+// MaxItems sets the maximum number of items in the cache.
+//
+// If a new item is set when the cache is full, items are evicted according to
+// the policy. OnEvicted is called for evicted items, after the method that set
+// the item releases the lock. Items are evicted even if they never expire.
+// Existing items are evicted right away if there are more than n items; as
+// there is no usage information for them the order in which they're evicted is
+// undefined.
+//
+// This adds some overhead to every operation, so it's disabled by default. Use
+// 0 to disable it again.
+func (c *cache[K, V]) MaxItems(n int, policy EvictionPolicy) {
+	c.mu.Lock()
+	defer c.unlock()
+	if n < 0 {
+		n = 0
+	}
+	c.maxItems = n
+	c.setPolicy(policy)
+	c.evictOverflow()
+}
+
+// setPolicy sets the eviction policy, creating or removing the lru as needed;
+// the lock must be held.
+func (c *cache[K, V]) setPolicy(policy EvictionPolicy) {
+	if c.maxItems == 0 && c.maxCost == 0 {
+		c.lru = nil
+		return
+	}
+	onGet := policy == PolicyLRU
+	if c.lru != nil && c.lru.onGet == onGet {
+		return
+	}
+	c.lru = newLRU[K](onGet)
+	for k := range c.items {
+		c.lru.set(k)
+	}
+}
+
+// overLimit reports if there are more items than maxItems or if the total cost
+// is higher than maxCost; the lock must be held.
+func (c *cache[K, V]) overLimit() bool {
+	return (c.maxItems > 0 && len(c.items) > c.maxItems) || (c.maxCost > 0 && c.cost > c.maxCost)
+}
+
+// evictOverflow evicts items until the cache is no longer over the limits set
+// with MaxItems() and MaxCost(), keeping at least one item; the OnEvicted
+// callbacks are run by unlock(). The lock must be held.
+func (c *cache[K, V]) evictOverflow() {
+	for len(c.items) > 1 && c.overLimit() {
+		k, ok := c.lru.oldest()
+		if !ok {
+			return
+		}
+		v, onEvict := c.delete(k)
+		if onEvict != nil {
+			c.overflow = append(c.overflow, keyAndValue[K, V]{k, v, onEvict})
+		}
+	}
+}
+
+// unlock the write lock, and run the OnEvicted callbacks for items evicted
+// by evictOverflow() and the function set with WithHighWatermark().
+func (c *cache[K, V]) unlock() {
+	evicted, fire := c.overflow, c.watermarkFire
+	c.overflow, c.watermarkFire = nil, nil
+	c.mu.Unlock()
+	for _, v := range evicted {
+		v.onEvict(v.key, v.value)
+	}
+	if fire != nil {
+		fire()
+	}
+}
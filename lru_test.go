@@ -0,0 +1,95 @@
+package zcache
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestMaxItems(t *testing.T) {
+	t.Run("lru", func(t *testing.T) {
+		tc := New[string, int](NoExpiration, 0)
+		var evicted []string
+		tc.OnEvicted(func(k string, _ int) { evicted = append(evicted, k) })
+		tc.MaxItems(3, PolicyLRU)
+
+		tc.Set("a", 1)
+		tc.Set("b", 2)
+		tc.Set("c", 3)
+		tc.Get("a")
+		tc.Set("d", 4) // Evicts b
+		tc.Set("a", 5) // Replace, doesn't evict.
+		tc.Set("e", 6) // Evicts c
+
+		if have, want := fmt.Sprint(evicted), "[b c]"; have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+		if have, want := keys(tc), "[a d e]"; have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+
+		tc.Rename("a", "x")
+		tc.Set("f", 7) // Evicts d
+		if have, want := keys(tc), "[e f x]"; have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+	})
+
+	t.Run("fifo", func(t *testing.T) {
+		tc := New[string, int](NoExpiration, 0)
+		tc.MaxItems(2, PolicyFIFO)
+
+		tc.Set("a", 1)
+		tc.Set("b", 2)
+		tc.Get("a")
+		tc.Set("c", 3)
+		if have, want := keys(tc), "[b c]"; have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+	})
+
+	t.Run("shrink", func(t *testing.T) {
+		tc := New[int, int](NoExpiration, 0)
+		for i := 0; i < 10; i++ {
+			tc.Set(i, i)
+		}
+		var n int
+		tc.OnEvicted(func(int, int) { n++ })
+		tc.MaxItems(4, PolicyLRU)
+		if tc.ItemCount() != 4 || n != 6 {
+			t.Errorf("%d %d", tc.ItemCount(), n)
+		}
+
+		tc.MaxItems(0, PolicyLRU)
+		for i := 0; i < 10; i++ {
+			tc.Set(i, i)
+		}
+		if tc.ItemCount() != 10 {
+			t.Error(tc.ItemCount())
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		tc := New[string, int](NoExpiration, 0)
+		tc.MaxItems(2, PolicyLRU)
+		tc.Set("a", 1)
+		tc.Set("b", 2)
+		tc.Delete("a")
+		tc.Set("c", 3)
+		if have, want := keys(tc), "[b c]"; have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+		tc.Reset()
+		tc.Set("d", 4)
+		tc.Set("e", 5)
+		if have, want := keys(tc), "[d e]"; have != want {
+			t.Errorf("\nhave: %s\nwant: %s", have, want)
+		}
+	})
+}
+
+func keys[V any](tc *Cache[string, V]) string {
+	k := tc.Keys()
+	sort.Strings(k)
+	return fmt.Sprint(k)
+}
@@ -0,0 +1,123 @@
+package zcache
+
+import (
+	"time"
+)
+
+// MemoizeCompile returns a function that calls compile and caches the result,
+// for example for compiled regular expressions or templates:
+//
+//	compile := zcache.MemoizeCompile(1000, regexp.Compile)
+//	re, err := compile(`^\d+$`)
+//
+// Results never expire, and at most max results are stored; compile is called
+// for every key not in the cache once it's full. A max of 0 means there is no
+// limit.
+//
+// Errors are never cached.
+func MemoizeCompile[K comparable, V any](max int, compile func(K) (V, error)) func(K) (V, error) {
+	c := New[K, V](NoExpiration, 0)
+	return func(k K) (V, error) {
+		if v, ok := c.Get(k); ok {
+			return v, nil
+		}
+		v, err := compile(k)
+		if err != nil {
+			return v, err
+		}
+		if max == 0 || c.ItemCount() < max {
+			_ = c.Add(k, v)
+		}
+		return v, nil
+	}
+}
+
+type (
+	memoKey2[A, B comparable] struct {
+		a A
+		b B
+	}
+	memoKey3[A, B, C comparable] struct {
+		a A
+		b B
+		c C
+	}
+)
+
+// Memo2 memoizes a function with two arguments.
+//
+// The arguments are used as a composite key, so there's no need to build a key
+// from the arguments (which is easy to get wrong, for example "a-b" + "c" and
+// "a" + "b-c" would both be "a-b-c").
+type Memo2[A, B comparable, V any] struct {
+	cache *Cache[memoKey2[A, B], V]
+	f     func(A, B) (V, error)
+}
+
+// NewMemo2 memoizes the function f.
+//
+// See New() for the meaning of the parameters.
+func NewMemo2[A, B comparable, V any](defaultExpiration, cleanupInterval time.Duration, f func(A, B) (V, error)) *Memo2[A, B, V] {
+	return &Memo2[A, B, V]{cache: New[memoKey2[A, B], V](defaultExpiration, cleanupInterval), f: f}
+}
+
+// Get the cached result for these arguments, or call the function if there is
+// no cached result yet.
+//
+// Errors are never cached.
+func (m *Memo2[A, B, V]) Get(a A, b B) (V, error) {
+	k := memoKey2[A, B]{a, b}
+	if v, ok := m.cache.Get(k); ok {
+		return v, nil
+	}
+	v, err := m.f(a, b)
+	if err != nil {
+		return v, err
+	}
+	m.cache.Set(k, v)
+	return v, nil
+}
+
+// Forget the cached result for these arguments.
+func (m *Memo2[A, B, V]) Forget(a A, b B) { m.cache.Delete(memoKey2[A, B]{a, b}) }
+
+// Reset forgets all cached results.
+func (m *Memo2[A, B, V]) Reset() { m.cache.Reset() }
+
+// Memo3 memoizes a function with three arguments.
+//
+// This is like Memo2, but with three arguments.
+type Memo3[A, B, C comparable, V any] struct {
+	cache *Cache[memoKey3[A, B, C], V]
+	f     func(A, B, C) (V, error)
+}
+
+// NewMemo3 memoizes the function f.
+//
+// See New() for the meaning of the parameters.
+func NewMemo3[A, B, C comparable, V any](defaultExpiration, cleanupInterval time.Duration, f func(A, B, C) (V, error)) *Memo3[A, B, C, V] {
+	return &Memo3[A, B, C, V]{cache: New[memoKey3[A, B, C], V](defaultExpiration, cleanupInterval), f: f}
+}
+
+// Get the cached result for these arguments, or call the function if there is
+// no cached result yet.
+//
+// Errors are never cached.
+func (m *Memo3[A, B, C, V]) Get(a A, b B, c C) (V, error) {
+	k := memoKey3[A, B, C]{a, b, c}
+	if v, ok := m.cache.Get(k); ok {
+		return v, nil
+	}
+	v, err := m.f(a, b, c)
+	if err != nil {
+		return v, err
+	}
+	m.cache.Set(k, v)
+	return v, nil
+}
+
+// Forget the cached result for these arguments.
+func (m *Memo3[A, B, C, V]) Forget(a A, b B, c C) { m.cache.Delete(memoKey3[A, B, C]{a, b, c}) }
+
+// Reset forgets all cached results.
+func (m *Memo3[A, B, C, V]) Reset() { m.cache.Reset() }
@@ -0,0 +1,117 @@
+package zcache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMemoizeCompile(t *testing.T) {
+	var calls int
+	compile := MemoizeCompile(2, func(k string) (int, error) {
+		calls++
+		if k == "err" {
+			return 0, errors.New("oh noes")
+		}
+		return strconv.Atoi(k)
+	})
+
+	for i := 0; i < 3; i++ {
+		v, err := compile("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != 1 {
+			t.Fatalf("wrong value: %d", v)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("calls: %d", calls)
+	}
+
+	compile("err")
+	compile("err")
+	if calls != 3 {
+		t.Errorf("calls: %d", calls)
+	}
+
+	// Cache is full after this.
+	compile("2")
+	compile("3")
+	compile("3")
+	if calls != 6 {
+		t.Errorf("calls: %d", calls)
+	}
+}
+
+func TestMemo(t *testing.T) {
+	t.Run("2", func(t *testing.T) {
+		var calls int
+		m := NewMemo2(NoExpiration, 0, func(a, b string) (string, error) {
+			calls++
+			if a == "err" {
+				return "", errors.New("oh noes")
+			}
+			return a + b, nil
+		})
+
+		for i := 0; i < 2; i++ {
+			if v, _ := m.Get("a-b", "c"); v != "a-bc" {
+				t.Errorf("value: %q", v)
+			}
+			if v, _ := m.Get("a", "b-c"); v != "ab-c" {
+				t.Errorf("value: %q", v)
+			}
+		}
+		if calls != 2 {
+			t.Errorf("calls: %d", calls)
+		}
+
+		m.Get("err", "")
+		m.Get("err", "")
+		if calls != 4 {
+			t.Errorf("calls: %d", calls)
+		}
+
+		m.Forget("a-b", "c")
+		m.Get("a-b", "c")
+		m.Get("a", "b-c")
+		if calls != 5 {
+			t.Errorf("calls: %d", calls)
+		}
+		m.Reset()
+		m.Get("a", "b-c")
+		if calls != 6 {
+			t.Errorf("calls: %d", calls)
+		}
+	})
+
+	t.Run("3", func(t *testing.T) {
+		var calls int
+		m := NewMemo3(NoExpiration, 0, func(a string, b int, c bool) (string, error) {
+			calls++
+			return a + strconv.Itoa(b) + strconv.FormatBool(c), nil
+		})
+		for i := 0; i < 2; i++ {
+			if v, _ := m.Get("a", 1, true); v != "a1true" {
+				t.Errorf("value: %q", v)
+			}
+			if v, _ := m.Get("a", 1, false); v != "a1false" {
+				t.Errorf("value: %q", v)
+			}
+		}
+		if calls != 2 {
+			t.Errorf("calls: %d", calls)
+		}
+		m.Forget("a", 1, true)
+		m.Get("a", 1, true)
+		if calls != 3 {
+			t.Errorf("calls: %d", calls)
+		}
+		m.Reset()
+		m.Get("a", 1, false)
+		if calls != 4 {
+			t.Errorf("calls: %d", calls)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package zcache
+
+import (
+	"math"
+	"sort"
+)
+
+// ReleaseMemory deletes a fraction of the items in the cache, for example when
+// the process is close to its memory limit.
+//
+// The items that are closest to expiring are deleted first, and items that
+// never expire last. The fraction is clamped to 0 to 1; 0.25 deletes a quarter
+// of the items, rounded up. It returns the number of deleted items.
+//
+// This calls the OnEvicted callbacks for the deleted items, but doesn't run the
+// garbage collector; call runtime.GC() or debug.FreeOSMemory() after this if
+// you want the memory to be returned right away.
+func (c *cache[K, V]) ReleaseMemory(fraction float64) int {
+	if fraction <= 0 {
+		return 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	var evictedItems []keyAndValue[K, V]
+	c.mu.Lock()
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return 0
+	}
+	type exp struct {
+		k K
+		e int64
+	}
+	all := make([]exp, 0, len(c.items))
+	for k, v := range c.items {
+		e := v.Expiration
+		if e <= 0 {
+			e = math.MaxInt64
+		}
+		all = append(all, exp{k, e})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].e < all[j].e })
+
+	n := int(math.Ceil(float64(len(all)) * fraction))
+	for _, e := range all[:n] {
+		ov, onEvict := c.delete(e.k)
+		if onEvict != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{e.k, ov, onEvict})
+		}
+	}
+	c.sortEvicted(evictedItems)
+	c.mu.Unlock()
+
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
+	}
+	return n
+}
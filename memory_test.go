@@ -0,0 +1,42 @@
+package zcache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestReleaseMemory(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("forever", 1)
+	tc.SetWithExpire("hour", 2, time.Hour)
+	tc.SetWithExpire("minute", 3, time.Minute)
+	tc.SetWithExpire("second", 4, time.Second)
+
+	var evicted []string
+	tc.OnEvicted(func(k string, _ int) { evicted = append(evicted, k) })
+
+	if n := tc.ReleaseMemory(0); n != 0 {
+		t.Fatal(n)
+	}
+	if n := tc.ReleaseMemory(0.4); n != 2 {
+		t.Fatal(n)
+	}
+	sort.Strings(evicted)
+	if want := []string{"minute", "second"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("\nhave: %v\nwant: %v", evicted, want)
+	}
+	if n := tc.ReleaseMemory(0.5); n != 1 {
+		t.Fatal(n)
+	}
+	if _, ok := tc.Get("forever"); !ok {
+		t.Error("forever deleted before hour")
+	}
+	if n := tc.ReleaseMemory(2); n != 1 {
+		t.Fatal(n)
+	}
+	if n := tc.ItemCount(); n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,122 @@
+package zcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// WriteMetrics writes metrics for the cache in the Prometheus text exposition
+// format.
+//
+// This is intended for applications that don't use a metrics library; for
+// example to expose the metrics over HTTP:
+//
+//	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+//		c.WriteMetrics(w)
+//	})
+//
+// The metrics have a "cache" label with the name set with SetName(), if any.
+// The janitor metrics are only written if the cache has a janitor.
+//
+// Use the WriteMetrics() function to write the metrics for several caches.
+func (c *cache[K, V]) WriteMetrics(w io.Writer) error { return WriteMetrics(w, c) }
+
+// MetricsSource is a cache that WriteMetrics() can write metrics for; it's
+// implemented by Cache and Sharded.
+type MetricsSource interface {
+	metrics() []metric
+}
+
+type metric struct {
+	name, typ, help string
+	label           string
+	value           float64
+}
+
+// WriteMetrics writes metrics for all the caches in the Prometheus text
+// exposition format, writing the HELP and TYPE lines once for every metric.
+//
+// Give every cache a different name with SetName(), as the series can't be
+// distinguished otherwise.
+func WriteMetrics(w io.Writer, caches ...MetricsSource) error {
+	var (
+		order  []string
+		series = make(map[string][]metric)
+	)
+	for _, c := range caches {
+		for _, m := range c.metrics() {
+			if _, ok := series[m.name]; !ok {
+				order = append(order, m.name)
+			}
+			series[m.name] = append(series[m.name], m)
+		}
+	}
+
+	b := bufio.NewWriter(w)
+	for _, n := range order {
+		ms := series[n]
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", n, ms[0].help, n, ms[0].typ)
+		for _, m := range ms {
+			fmt.Fprintf(b, "%s%s %s\n", n, m.label, strconv.FormatFloat(m.value, 'g', -1, 64))
+		}
+	}
+	return b.Flush()
+}
+
+func (c *cache[K, V]) metrics() []metric {
+	c.mu.RLock()
+	var (
+		name  = c.name
+		items = len(c.items)
+		j     = c.janitor
+		jr    janitor[K, V]
+		ttl   = c.defaultTTL()
+		fr    = c.frozen
+	)
+	if j != nil {
+		jr = *j // Copy, as the fields are protected by c.mu.
+	}
+	c.mu.RUnlock()
+
+	label := ""
+	if name != "" {
+		label = `{cache="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(name) + `"}`
+	}
+	var ms []metric
+	add := func(n, typ, help string, v float64) {
+		ms = append(ms, metric{name: n, typ: typ, help: help, label: label, value: v})
+	}
+
+	add("zcache_items", "gauge", "Number of items in the cache, including expired items that haven't been deleted yet.", float64(items))
+	exp := ttl.Seconds()
+	if ttl < 0 {
+		exp = -1
+	}
+	add("zcache_default_expiration_seconds", "gauge", "Default expiration; -1 if items don't expire by default.", exp)
+	frozen := 0.0
+	if fr {
+		frozen = 1
+	}
+	add("zcache_frozen", "gauge", "Whether the cache is frozen with Freeze().", frozen)
+	add("zcache_frozen_writes_total", "counter", "Number of writes rejected because the cache was frozen.", float64(atomic.LoadUint64(&c.frozenWrites)))
+	if j != nil {
+		running := 0.0
+		if jr.running {
+			running = 1
+		}
+		var lastRun float64
+		if !jr.lastRun.IsZero() {
+			lastRun = float64(jr.lastRun.UnixNano()) / 1e9
+		}
+		add("zcache_janitor_running", "gauge", "Whether the janitor is running.", running)
+		add("zcache_janitor_interval_seconds", "gauge", "Current interval of the janitor.", jr.interval.Seconds())
+		add("zcache_janitor_last_run_timestamp_seconds", "gauge", "Time the janitor last ran; 0 if it never ran.", lastRun)
+		add("zcache_janitor_last_deleted", "gauge", "Number of items deleted in the last janitor run.", float64(jr.lastDeleted))
+		add("zcache_janitor_lock_seconds", "gauge", "Time the last janitor run held the write lock.", jr.lockTime.Seconds())
+	}
+	return ms
+}
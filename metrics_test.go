@@ -0,0 +1,76 @@
+package zcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+
+	b := new(strings.Builder)
+	if err := tc.WriteMetrics(b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP zcache_items Number of items in the cache, including expired items that haven't been deleted yet.
+# TYPE zcache_items gauge
+zcache_items 2
+# HELP zcache_default_expiration_seconds Default expiration; -1 if items don't expire by default.
+# TYPE zcache_default_expiration_seconds gauge
+zcache_default_expiration_seconds 60
+# HELP zcache_frozen Whether the cache is frozen with Freeze().
+# TYPE zcache_frozen gauge
+zcache_frozen 0
+# HELP zcache_frozen_writes_total Number of writes rejected because the cache was frozen.
+# TYPE zcache_frozen_writes_total counter
+zcache_frozen_writes_total 0
+`
+	if have := b.String(); have != want {
+		t.Errorf("\nhave:\n%s\nwant:\n%s", have, want)
+	}
+
+	tc = New[string, int](time.Minute, time.Hour)
+	tc.SetName(`my "cache"`)
+	b.Reset()
+	if err := tc.WriteMetrics(b); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []string{
+		`zcache_items{cache="my \"cache\""} 0`,
+		`zcache_janitor_running{cache="my \"cache\""} 1`,
+		`zcache_janitor_interval_seconds{cache="my \"cache\""} 3600`,
+		`zcache_janitor_last_run_timestamp_seconds{cache="my \"cache\""} 0`,
+	} {
+		if !strings.Contains(b.String(), w+"\n") {
+			t.Errorf("doesn't contain %q:\n%s", w, b.String())
+		}
+	}
+}
+
+func TestWriteMetricsMultiple(t *testing.T) {
+	a := New[string, int](NoExpiration, 0)
+	a.SetName("a")
+	a.Set("x", 1)
+	b := New[int, string](time.Second, 0)
+	b.SetName("b")
+
+	buf := new(strings.Builder)
+	if err := WriteMetrics(buf, a, b); err != nil {
+		t.Fatal(err)
+	}
+	have := buf.String()
+	if n := strings.Count(have, "# TYPE zcache_items "); n != 1 {
+		t.Errorf("TYPE written %d times:\n%s", n, have)
+	}
+	for _, w := range []string{
+		"# TYPE zcache_items gauge\nzcache_items{cache=\"a\"} 1\nzcache_items{cache=\"b\"} 0\n",
+		"zcache_default_expiration_seconds{cache=\"a\"} -1\nzcache_default_expiration_seconds{cache=\"b\"} 1\n",
+	} {
+		if !strings.Contains(have, w) {
+			t.Errorf("doesn't contain %q:\n%s", w, have)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package zcache
+
+import (
+	"sync/atomic"
+)
+
+const (
+	missFilterBits   = 10 // Bits per key, for a false positive rate of ~1%.
+	missFilterHashes = 7
+)
+
+// missFilter is a Bloom filter of the keys in the cache.
+type missFilter[K comparable] struct {
+	hash  func(K) uint64
+	bits  []uint64 // Updated atomically, as it's read without the lock.
+	size  int      // Number of keys it was sized for.
+	added int      // Number of keys added; protected by cache.mu.
+}
+
+// filterView is what Get() uses to check the filter without the lock.
+type filterView[K comparable, V any] struct {
+	f  *missFilter[K]
+	dv V
+}
+
+func newMissFilter[K comparable](hash func(K) uint64, size int) *missFilter[K] {
+	if size < 64 {
+		size = 64
+	}
+	return &missFilter[K]{
+		hash: hash,
+		bits: make([]uint64, (size*missFilterBits+63)/64),
+		size: size,
+	}
+}
+
+// each calls f with the bit positions for the key.
+func (f *missFilter[K]) each(k K, fn func(word int, bit uint64) bool) bool {
+	// Mix the hash, as defaultHash() doesn't spread small integers very well,
+	// and derive the positions from the two halves with double hashing.
+	h := f.hash(k)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h1, h2 := h&0xffffffff, h>>32|1
+
+	n := uint64(len(f.bits) * 64)
+	for i := uint64(0); i < missFilterHashes; i++ {
+		pos := (h1 + i*h2) % n
+		if !fn(int(pos/64), 1<<(pos%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *missFilter[K]) add(k K) {
+	f.each(k, func(word int, bit uint64) bool {
+		for {
+			old := atomic.LoadUint64(&f.bits[word])
+			if old&bit != 0 || atomic.CompareAndSwapUint64(&f.bits[word], old, old|bit) {
+				return true
+			}
+		}
+	})
+	f.added++
+}
+
+// has reports if the key may be in the cache; it's never false for keys that
+// are in the cache.
+func (f *missFilter[K]) has(k K) bool {
+	return f.each(k, func(word int, bit uint64) bool {
+		return atomic.LoadUint64(&f.bits[word])&bit != 0
+	})
+}
+
+// setMissFilter sets up the filter; the lock must be held.
+func (c *cache[K, V]) setMissFilter(n int, hash func(K) uint64) {
+	c.filter = newMissFilter(hash, n)
+	c.filterSize = n
+	c.rebuildFilter()
+}
+
+// filterAdd adds the key to the filter, rebuilding the filter if it's full;
+// the lock must be held.
+func (c *cache[K, V]) filterAdd(k K) {
+	c.filter.add(k)
+	if c.filter.added > c.filter.size {
+		c.rebuildFilter()
+	}
+}
+
+// rebuildFilter creates a new filter from the keys in the cache, dropping the
+// keys that were deleted; the lock must be held.
+func (c *cache[K, V]) rebuildFilter() {
+	size := c.filterSize
+	if n := len(c.items) * 2; n > size {
+		size = n
+	}
+	f := newMissFilter(c.filter.hash, size)
+	for k := range c.items {
+		f.add(k)
+	}
+	c.filter = f
+	c.storeFilter()
+}
+
+// storeFilter makes the filter available to Get(), if it can be used without
+// the lock; the lock must be held.
+func (c *cache[K, V]) storeFilter() {
+	if c.filter == nil {
+		return
+	}
+	if c.normalize != nil || c.keyStats != nil || c.autoTTL != nil {
+		c.filterView.Store((*filterView[K, V])(nil))
+		return
+	}
+	c.filterView.Store(&filterView[K, V]{f: c.filter, dv: c.defaultValue})
+}
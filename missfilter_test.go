@@ -0,0 +1,105 @@
+package zcache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMissFilter(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		tc := NewWith(WithMissFilter[int, string](100, nil))
+		for i := 0; i < 1000; i++ {
+			tc.Set(i, fmt.Sprint(i))
+		}
+		for i := 0; i < 1000; i++ {
+			if v, ok := tc.Get(i); !ok || v != fmt.Sprint(i) {
+				t.Fatalf("%d: %q %t", i, v, ok)
+			}
+		}
+
+		// Filter was rebuilt, so it should have a low false positive rate.
+		fv := tc.filterView.Load().(*filterView[int, string])
+		fp := 0
+		for i := 1000; i < 11000; i++ {
+			if _, ok := tc.Get(i); ok {
+				t.Fatal(i)
+			}
+			if fv.f.has(i) {
+				fp++
+			}
+		}
+		if fp > 500 {
+			t.Errorf("false positives: %d", fp)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		tc := NewWith(WithMissFilter[string, int](10, nil))
+		tc.Set("a", 1)
+		tc.Delete("a")
+		if _, ok := tc.Get("a"); ok {
+			t.Error("a")
+		}
+		tc.Set("b", 2)
+		tc.Rename("b", "c")
+		if v, ok := tc.Get("c"); !ok || v != 2 {
+			t.Error(v, ok)
+		}
+
+		tc.Reset()
+		if tc.filter.has("c") {
+			t.Error("not reset")
+		}
+		tc.Set("d", 4)
+		tc.DeleteAll()
+		if tc.filter.has("d") {
+			t.Error("not reset")
+		}
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		tc := NewWith(WithMissFilter[string, int](10, func(k string) uint64 { return uint64(len(k)) }))
+		tc.Set("a", 1)
+		if v, ok := tc.Get("a"); !ok || v != 1 {
+			t.Error(v, ok)
+		}
+		if _, ok := tc.Get("b"); ok {
+			t.Error("b")
+		}
+	})
+
+	t.Run("default value", func(t *testing.T) {
+		tc := NewWith(WithMissFilter[string, int](10, nil))
+		tc.DefaultValue(-1)
+		if v, ok := tc.Get("a"); ok || v != -1 {
+			t.Error(v, ok)
+		}
+	})
+
+	t.Run("normalizer", func(t *testing.T) {
+		tc := NewWith(WithMissFilter[string, int](10, nil))
+		tc.KeyNormalizer(strings.ToLower)
+		tc.Set("A", 1)
+		if v, ok := tc.Get("A"); !ok || v != 1 {
+			t.Error(v, ok)
+		}
+
+		tc.KeyNormalizer(nil)
+		if v, ok := tc.Get("a"); !ok || v != 1 {
+			t.Error(v, ok)
+		}
+		if _, ok := tc.Get("A"); ok {
+			t.Error("A")
+		}
+	})
+
+	t.Run("not hashable", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("no panic")
+			}
+		}()
+		NewWith(WithMissFilter[struct{ a int }, int](10, nil))
+	})
+}
@@ -0,0 +1,49 @@
+package zcache
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// NonceStore issues single-use tokens, such as CSRF tokens or nonces.
+//
+// Every token can be consumed only once, even if Consume() is called
+// concurrently.
+type NonceStore struct {
+	cache *Cache[string, struct{}]
+}
+
+// NewNonceStore creates a new nonce store; expired tokens are removed every
+// cleanupInterval.
+func NewNonceStore(cleanupInterval time.Duration) *NonceStore {
+	return &NonceStore{cache: New[string, struct{}](NoExpiration, cleanupInterval)}
+}
+
+// Issue a new token which is valid for the duration ttl.
+//
+// This will panic if reading from crypto/rand fails.
+func (n *NonceStore) Issue(ttl time.Duration) string {
+	token := randomToken("zcache.NonceStore.Issue")
+	n.cache.SetWithMaxUses(token, struct{}{}, ttl, 1)
+	return token
+}
+
+// Consume a token, reporting if it was valid.
+//
+// This returns false if the token was never issued, has expired, or was already
+// consumed.
+func (n *NonceStore) Consume(token string) bool {
+	_, ok := n.cache.Get(token)
+	return ok
+}
+
+// randomToken generates a random URL-safe token, panicking with the prefix if
+// reading from crypto/rand fails.
+func randomToken(prefix string) string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(prefix + ": " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,54 @@
+package zcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNonceStore(t *testing.T) {
+	n := NewNonceStore(0)
+
+	tok := n.Issue(time.Minute)
+	if len(tok) != 32 {
+		t.Errorf("token: %q", tok)
+	}
+	if tok == n.Issue(time.Minute) {
+		t.Error("same token")
+	}
+	if !n.Consume(tok) {
+		t.Error("not valid")
+	}
+	if n.Consume(tok) {
+		t.Error("consumed twice")
+	}
+	if n.Consume("nonexistent") {
+		t.Error("nonexistent token valid")
+	}
+
+	tok = n.Issue(1)
+	time.Sleep(time.Millisecond)
+	if n.Consume(tok) {
+		t.Error("expired token valid")
+	}
+
+	var (
+		wg    sync.WaitGroup
+		valid int32
+	)
+	tok = n.Issue(time.Minute)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n.Consume(tok) {
+				atomic.AddInt32(&valid, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if valid != 1 {
+		t.Errorf("consumed %d times", valid)
+	}
+}
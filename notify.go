@@ -0,0 +1,116 @@
+package zcache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type expiryNotifier[K comparable] struct {
+	out     chan K
+	wake    chan struct{}
+	stop    chan struct{}
+	max     int
+	dropped uint64
+
+	mu      sync.Mutex
+	queue   []K
+	pending map[K]struct{}
+}
+
+func newExpiryNotifier[K comparable](buffer int) *expiryNotifier[K] {
+	n := &expiryNotifier[K]{
+		out:     make(chan K),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		max:     buffer,
+		pending: make(map[K]struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// notify queues k, unless it's already queued.
+func (n *expiryNotifier[K]) notify(keys ...K) {
+	n.mu.Lock()
+	for _, k := range keys {
+		if _, ok := n.pending[k]; ok {
+			continue
+		}
+		if len(n.queue) >= n.max {
+			atomic.AddUint64(&n.dropped, 1)
+			continue
+		}
+		n.queue = append(n.queue, k)
+		n.pending[k] = struct{}{}
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (n *expiryNotifier[K]) run() {
+	defer close(n.out)
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.mu.Unlock()
+			select {
+			case <-n.wake:
+				continue
+			case <-n.stop:
+				return
+			}
+		}
+		k := n.queue[0]
+		n.mu.Unlock()
+
+		select {
+		case n.out <- k:
+			n.mu.Lock()
+			n.queue = n.queue[1:]
+			delete(n.pending, k)
+			n.mu.Unlock()
+		case <-n.stop:
+			return
+		}
+	}
+}
+
+// ExpiryNotifications gets a channel on which the keys of expired items are
+// sent as they're deleted, either by the janitor or by ExpireOnAccess().
+//
+// Up to buffer keys are queued if the channel isn't read; further keys are
+// dropped, and counted in DroppedExpiryNotifications(). A key that's already
+// queued isn't queued again. Items that are deleted manually (e.g. with
+// Delete()) are not sent.
+//
+// Calling this again replaces and closes the previous channel. Use a buffer
+// of 0 to stop sending notifications and close the channel; it will also be
+// closed if the cache is garbage collected.
+func (c *cache[K, V]) ExpiryNotifications(buffer int) <-chan K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.notifier != nil {
+		close(c.notifier.stop)
+		c.notifier = nil
+	}
+	if buffer < 1 {
+		return nil
+	}
+	c.notifier = newExpiryNotifier[K](buffer)
+	return c.notifier.out
+}
+
+// DroppedExpiryNotifications gets the number of keys that were dropped because
+// the ExpiryNotifications() buffer was full.
+func (c *cache[K, V]) DroppedExpiryNotifications() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.notifier == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.notifier.dropped)
+}
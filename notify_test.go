@@ -0,0 +1,76 @@
+package zcache
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestExpiryNotifications(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	ch := tc.ExpiryNotifications(2)
+
+	tc.SetWithExpire("a", 1, time.Nanosecond)
+	tc.SetWithExpire("b", 1, time.Nanosecond)
+	tc.SetWithExpire("c", 1, time.Nanosecond)
+	tc.Set("d", 1)
+	tc.SetWithExpire("e", 1, time.Nanosecond)
+	tc.Delete("e")
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+
+	// Re-queue "a" while it's still pending; should be coalesced.
+	tc.SetWithExpire("a", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+
+	var keys []string
+	for i := 0; i < 2; i++ {
+		select {
+		case k := <-ch:
+			keys = append(keys, k)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout; have %v", keys)
+		}
+	}
+	select {
+	case k := <-ch:
+		t.Fatalf("unexpected key %q", k)
+	case <-time.After(10 * time.Millisecond):
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] == keys[1] {
+		t.Errorf("wrong keys: %v", keys)
+	}
+
+	// 3 expired with a buffer of 2, and "a" was dropped or coalesced.
+	if d := tc.DroppedExpiryNotifications(); d < 1 || d > 2 {
+		t.Errorf("dropped: %d", d)
+	}
+
+	// With ExpireOnAccess.
+	tc.ExpireOnAccess(true)
+	tc.SetWithExpire("x", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	tc.Get("x")
+	select {
+	case k := <-ch:
+		if k != "x" {
+			t.Errorf("wrong key: %q", k)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	if c := tc.ExpiryNotifications(0); c != nil {
+		t.Error("not nil")
+	}
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("channel not closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
@@ -0,0 +1,153 @@
+package zcache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option is an option for NewWith().
+//
+// The options are typed with the cache's key and value types, so that options
+// such as WithOnEvicted() are checked at compile time. Options that don't have
+// the types in their parameters need them as type arguments:
+//
+//	zcache.NewWith(
+//		zcache.WithDefaultExpiration[string, int](time.Minute),
+//		zcache.WithCapacity[string, int](1000, zcache.PolicyLRU),
+//		zcache.WithOnEvicted(func(k string, v int) { ... }),
+//	)
+type Option[K comparable, V any] func(*options[K, V])
+
+type options[K comparable, V any] struct {
+	defaultExpiration time.Duration
+	cleanupInterval   time.Duration
+	initialSize       int
+	capacity          int
+	policy            EvictionPolicy
+	coarseTime        time.Duration
+	onEvicted         func(K, V)
+	watermark         *watermark
+	maxEvictions      int
+	missFilter        int
+	missFilterHash    func(K) uint64
+}
+
+// WithDefaultExpiration sets the default expiration; the default is
+// NoExpiration.
+func WithDefaultExpiration[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(o *options[K, V]) { o.defaultExpiration = d }
+}
+
+// WithCleanupInterval sets the interval at which the janitor deletes expired
+// items; the default is to not run the janitor.
+func WithCleanupInterval[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(o *options[K, V]) { o.cleanupInterval = d }
+}
+
+// WithInitialSize allocates space for n items when the cache is created.
+func WithInitialSize[K comparable, V any](n int) Option[K, V] {
+	return func(o *options[K, V]) { o.initialSize = n }
+}
+
+// WithCapacity sets the maximum number of items; see MaxItems().
+func WithCapacity[K comparable, V any](n int, policy EvictionPolicy) Option[K, V] {
+	return func(o *options[K, V]) { o.capacity, o.policy = n, policy }
+}
+
+// WithCoarseTime reads the time once every resolution; see CoarseTime().
+func WithCoarseTime[K comparable, V any](resolution time.Duration) Option[K, V] {
+	return func(o *options[K, V]) { o.coarseTime = resolution }
+}
+
+// WithOnEvicted sets the function to call when an item is evicted; see
+// OnEvicted().
+func WithOnEvicted[K comparable, V any](f func(K, V)) Option[K, V] {
+	return func(o *options[K, V]) { o.onEvicted = f }
+}
+
+// WithHighWatermark calls f when the cache fills up to fraction (e.g. 0.9 for
+// 90%) of the capacity set with MaxItems() or MaxCost(); this can be used to
+// alert or to evict items before the cache is full and hot items get evicted.
+//
+// It's called with the number of items and the maximum, or with the total cost
+// and the maximum cost if the cost crossed the fraction. It's called again
+// only after the cache drops below the fraction. The function is called after
+// the cache is unlocked, from the goroutine that wrote to the cache.
+func WithHighWatermark[K comparable, V any](fraction float64, f func(count, capacity int)) Option[K, V] {
+	return func(o *options[K, V]) { o.watermark = &watermark{fraction: fraction, f: f} }
+}
+
+// WithMaxEvictionsPerRun limits the number of expired items the janitor deletes
+// in one run, so that the OnEvicted callbacks for many items expiring at the
+// same time are spread over several runs. Items over the limit are deleted in
+// the next run, in no particular order.
+//
+// This doesn't affect DeleteExpired(), which always deletes all expired items.
+func WithMaxEvictionsPerRun[K comparable, V any](n int) Option[K, V] {
+	return func(o *options[K, V]) { o.maxEvictions = n }
+}
+
+// WithMissFilter keeps a Bloom filter of the keys in the cache, so that Get()
+// can return early for keys that definitely aren't in the cache without
+// locking the cache or looking them up in the map.
+//
+// This is useful if most lookups are for keys that are never cached. The
+// filter is sized for n keys, and is rebuilt from the keys that are still in
+// the cache once n keys were added, or once it's full if the cache has more
+// than n items. It uses about 10 bits per key, for a false positive rate of
+// about 1%.
+//
+// The hash function is used to hash keys; the default hashes strings,
+// numbers, booleans, pointers, and channels. NewWith() panics if it's nil and
+// keys of type K can't be hashed.
+//
+// The filter isn't used while KeyNormalizer(), TrackKeys(), or AutoTTL() is
+// set, as those need the lock for every lookup.
+func WithMissFilter[K comparable, V any](n int, hash func(K) uint64) Option[K, V] {
+	return func(o *options[K, V]) { o.missFilter, o.missFilterHash = n, hash }
+}
+
+// NewWith creates a new cache with the given options.
+//
+// Without any options this is the same as New(NoExpiration, 0).
+func NewWith[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
+	var o options[K, V]
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.missFilter > 0 && o.missFilterHash == nil {
+		if !hashable[K]() {
+			var k K
+			panic(fmt.Sprintf("zcache.WithMissFilter: need a hash function for keys of type %T", k))
+		}
+		o.missFilterHash = defaultHash[K]
+	}
+
+	c := newCacheWithJanitor(o.defaultExpiration, o.cleanupInterval, make(map[K]Item[V], o.initialSize))
+	if o.onEvicted != nil {
+		c.OnEvicted(o.onEvicted)
+	}
+	if o.capacity > 0 {
+		c.MaxItems(o.capacity, o.policy)
+	}
+	if o.coarseTime > 0 {
+		c.CoarseTime(o.coarseTime)
+	}
+	if o.maxEvictions > 0 {
+		c.mu.Lock()
+		c.maxEvictions = o.maxEvictions
+		c.mu.Unlock()
+	}
+	if o.watermark != nil && o.watermark.f != nil {
+		c.mu.Lock()
+		c.watermark = o.watermark
+		c.checkWatermark()
+		c.unlock()
+	}
+	if o.missFilter > 0 {
+		c.mu.Lock()
+		c.setMissFilter(o.missFilter, o.missFilterHash)
+		c.mu.Unlock()
+	}
+	return c
+}
@@ -0,0 +1,136 @@
+package zcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewWith(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		tc := NewWith[string, int]()
+		if d := tc.DefaultExpiration(); d != NoExpiration {
+			t.Error(d)
+		}
+		if running, _, _ := tc.JanitorStatus(); running {
+			t.Error("janitor running")
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var evicted []string
+		tc := NewWith(
+			WithDefaultExpiration[string, int](time.Minute),
+			WithCleanupInterval[string, int](time.Hour),
+			WithInitialSize[string, int](10),
+			WithCapacity[string, int](2, PolicyFIFO),
+			WithOnEvicted(func(k string, v int) { evicted = append(evicted, k) }),
+		)
+
+		if d := tc.DefaultExpiration(); d != time.Minute {
+			t.Error(d)
+		}
+		if running, _, _ := tc.JanitorStatus(); !running {
+			t.Error("janitor not running")
+		}
+
+		tc.Set("a", 1)
+		tc.Set("b", 2)
+		tc.Set("c", 3)
+		if have := keys(tc); have != "[b c]" {
+			t.Error(have)
+		}
+		if len(evicted) != 1 || evicted[0] != "a" {
+			t.Error(evicted)
+		}
+	})
+}
+
+func TestMaxEvictionsPerRun(t *testing.T) {
+	var evicted int
+	tc := NewWith(
+		WithMaxEvictionsPerRun[string, int](2),
+		WithOnEvicted(func(k string, v int) { evicted++ }),
+	)
+	for i := 0; i < 5; i++ {
+		tc.SetWithExpire(fmt.Sprint(i), i, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+
+	for i, want := range []int{2, 2, 1, 0} {
+		if n, _ := tc.deleteExpired(true); n != want {
+			t.Errorf("run %d: deleted %d; want %d", i, n, want)
+		}
+	}
+	if evicted != 5 || tc.ItemCount() != 0 {
+		t.Error(evicted, tc.ItemCount())
+	}
+
+	// DeleteExpired() isn't capped.
+	for i := 0; i < 5; i++ {
+		tc.SetWithExpire(fmt.Sprint(i), i, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	if tc.ItemCount() != 0 {
+		t.Error(tc.ItemCount())
+	}
+}
+
+func TestHighWatermark(t *testing.T) {
+	t.Run("items", func(t *testing.T) {
+		var fired []string
+		tc := NewWith(
+			WithCapacity[string, int](10, PolicyFIFO),
+			WithHighWatermark[string, int](0.8, func(count, capacity int) {
+				fired = append(fired, fmt.Sprintf("%d/%d", count, capacity))
+			}),
+		)
+		for i := 0; i < 20; i++ {
+			tc.Set(fmt.Sprint(i), i)
+		}
+		if have := fmt.Sprint(fired); have != "[8/10]" {
+			t.Fatal(have)
+		}
+
+		// Fires again only after dropping below the watermark.
+		tc.Delete("19")
+		tc.Set("19", 19)
+		for i := 0; i < 5; i++ {
+			tc.Delete(fmt.Sprint(i + 10))
+		}
+		tc.Set("a", 1)
+		tc.Set("b", 1)
+		tc.Set("c", 1)
+		if have := fmt.Sprint(fired); have != "[8/10 8/10]" {
+			t.Fatal(have)
+		}
+	})
+
+	t.Run("cost", func(t *testing.T) {
+		var fired []string
+		tc := NewWith(WithHighWatermark[string, []byte](0.5, func(count, capacity int) {
+			fired = append(fired, fmt.Sprintf("%d/%d", count, capacity))
+		}))
+		tc.MaxCost(100, PolicyFIFO, func(k string, v []byte) int64 { return int64(len(v)) })
+
+		tc.Set("a", make([]byte, 40))
+		tc.Set("b", make([]byte, 5))
+		if len(fired) != 0 {
+			t.Fatal(fired)
+		}
+		tc.Modify("b", func(v []byte) []byte { return make([]byte, 20) })
+		if have := fmt.Sprint(fired); have != "[60/100]" {
+			t.Fatal(have)
+		}
+	})
+
+	t.Run("no capacity", func(t *testing.T) {
+		tc := NewWith(WithHighWatermark[string, int](0.5, func(count, capacity int) {
+			t.Error("called")
+		}))
+		for i := 0; i < 10; i++ {
+			tc.Set(fmt.Sprint(i), i)
+		}
+	})
+}
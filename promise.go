@@ -0,0 +1,110 @@
+package zcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Promise is a value that's being created, returned by Cache.Promise().
+//
+// The first caller for a key owns the promise and must resolve it with
+// Resolve() or Reject(); other callers can wait for the value with Wait().
+type Promise[V any] struct {
+	call    *call[V]
+	once    sync.Once
+	resolve func(V, error, time.Duration)
+}
+
+// Promise gets a promise for the key.
+//
+// If the key isn't set and there is no value being created for it, the
+// returned promise is owned by the caller, who must create the value and call
+// Resolve() or Reject(). Otherwise the promise is for the existing value or the
+// value that's being created, and Owner() returns false.
+//
+// This shares the in-flight calls with GetOrSet() and GetFresh(): a promise
+// may be for the value a GetOrSet() loader is creating, and GetOrSet() will
+// wait for an owned promise to be resolved.
+//
+// For example:
+//
+//	p := c.Promise(k)
+//	if p.Owner() {
+//		v, err := create()
+//		if err != nil {
+//			p.Reject(err)
+//		} else {
+//			p.Resolve(v, zcache.DefaultExpiration)
+//		}
+//	}
+//	v, err := p.Wait(ctx)
+func (c *cache[K, V]) Promise(k K) *Promise[V] {
+	k = c.normalizeKey(k)
+	if v, ok := c.Get(k); ok {
+		return resolvedPromise(v)
+	}
+
+	cl, owner := c.flights.claim(k)
+	p := &Promise[V]{call: cl}
+	if !owner {
+		return p
+	}
+	// May have been set between Get() and claim().
+	if v, ok := c.Get(k); ok {
+		c.flights.finish(k, cl, v, nil)
+		return resolvedPromise(v)
+	}
+	p.resolve = func(v V, err error, d time.Duration) {
+		if err == nil && d != DontCache {
+			c.SetWithExpire(k, v, d)
+		}
+		if err == nil {
+			v = c.readValue(v)
+		}
+		c.flights.finish(k, cl, v, err)
+	}
+	return p
+}
+
+func resolvedPromise[V any](v V) *Promise[V] {
+	cl := &call[V]{done: make(chan struct{}), val: v}
+	close(cl.done)
+	return &Promise[V]{call: cl}
+}
+
+// Owner reports if this promise is owned by the caller, and must be resolved
+// with Resolve() or Reject().
+func (p *Promise[V]) Owner() bool { return p.resolve != nil }
+
+// Resolve the promise with the value, storing it in the cache with the given
+// expiration; DontCache can be used to not store it.
+//
+// This does nothing if the caller isn't the owner or if the promise was
+// already resolved or rejected.
+func (p *Promise[V]) Resolve(v V, d time.Duration) {
+	if p.resolve != nil {
+		p.once.Do(func() { p.resolve(v, nil, d) })
+	}
+}
+
+// Reject the promise with an error, which is returned from Wait(). Nothing is
+// stored in the cache.
+//
+// This does nothing if the caller isn't the owner or if the promise was
+// already resolved or rejected.
+func (p *Promise[V]) Reject(err error) {
+	if p.resolve != nil {
+		var zero V
+		p.once.Do(func() { p.resolve(zero, err, DontCache) })
+	}
+}
+
+// Wait for the promise to be resolved or rejected, or until the context is
+// cancelled.
+//
+// The owner of a promise should resolve it before calling Wait(), as it will
+// otherwise wait forever (or until the context is cancelled).
+func (p *Promise[V]) Wait(ctx context.Context) (V, error) {
+	return p.call.wait(ctx)
+}
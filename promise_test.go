@@ -0,0 +1,85 @@
+package zcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPromise(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	ctx := context.Background()
+
+	var (
+		wg     sync.WaitGroup
+		owners int32
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := tc.Promise("a")
+			if p.Owner() {
+				atomic.AddInt32(&owners, 1)
+				time.Sleep(5 * time.Millisecond)
+				p.Resolve(42, DefaultExpiration)
+			}
+			if v, err := p.Wait(ctx); v != 42 || err != nil {
+				t.Errorf("%v %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if owners != 1 {
+		t.Errorf("owners: %d", owners)
+	}
+	if v, ok := tc.Get("a"); v != 42 || !ok {
+		t.Errorf("%v %v", v, ok)
+	}
+
+	// Existing value.
+	p := tc.Promise("a")
+	if p.Owner() {
+		t.Error("owner for existing value")
+	}
+	if v, err := p.Wait(ctx); v != 42 || err != nil {
+		t.Errorf("%v %v", v, err)
+	}
+
+	// Reject.
+	errFail := errors.New("fail")
+	p = tc.Promise("b")
+	p2 := tc.Promise("b")
+	if !p.Owner() || p2.Owner() {
+		t.Fatal("wrong owner")
+	}
+	p.Reject(errFail)
+	p.Resolve(1, DefaultExpiration) // No-op.
+	if _, err := p2.Wait(ctx); err != errFail {
+		t.Error(err)
+	}
+	if _, ok := tc.Get("b"); ok {
+		t.Error("b is set")
+	}
+
+	// Context cancelled.
+	p = tc.Promise("c")
+	p2 = tc.Promise("c")
+	cctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	if _, err := p2.Wait(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(err)
+	}
+
+	// GetOrSet waits for the promise.
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		p.Resolve(3, DefaultExpiration)
+	}()
+	if v := tc.GetOrSet("c", func() (int, time.Duration) { return 4, 0 }); v != 3 {
+		t.Error(v)
+	}
+}
@@ -58,6 +58,25 @@ func (p *Proxy[ProxyK, MainK, V]) Key(proxyKey ProxyK) (MainK, bool) {
 	return mainKey, ok
 }
 
+// Keys gets the main keys for all the given proxy keys, for use with batch
+// operations on the main cache such as ExpireMany().
+//
+// Proxy keys that aren't set are skipped; the main keys are in the same order as
+// the proxy keys, and may contain duplicates if several proxy keys point to the
+// same main key.
+func (p *Proxy[ProxyK, MainK, V]) Keys(proxyKeys ...ProxyK) []MainK {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	keys := make([]MainK, 0, len(proxyKeys))
+	for _, k := range proxyKeys {
+		if mainKey, ok := p.m[k]; ok {
+			keys = append(keys, mainKey)
+		}
+	}
+	return keys
+}
+
 // Cache gets the associated cache.
 func (p *Proxy[ProxyK, MainK, V]) Cache() *Cache[MainK, V] {
 	return p.cache
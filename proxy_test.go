@@ -63,3 +63,16 @@ func TestProxy(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestProxyKeys(t *testing.T) {
+	tc := New[int, string](DefaultExpiration, 0)
+	pc := NewProxy[string, int, string](tc)
+	pc.Set(1, "one", "x")
+	pc.Set(2, "two", "y")
+	pc.Proxy(1, "uno")
+
+	have := pc.Keys("one", "missing", "two", "uno")
+	if want := []int{1, 2, 1}; !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+}
@@ -0,0 +1,69 @@
+package zcache
+
+import (
+	"sort"
+	"sync"
+)
+
+var registry struct {
+	mu sync.RWMutex
+	m  map[string]any
+}
+
+// Register a cache with a name in the process-wide registry, so that it can be
+// found with Lookup() and Names() (e.g. for debugging or exporting metrics)
+// without passing it around.
+//
+// This also sets the cache's name with SetName(), if it doesn't have one yet.
+// It will panic if the name is already registered.
+//
+// Registered caches are never garbage collected; use Unregister() to remove it
+// from the registry.
+func Register[K comparable, V any](name string, c *Cache[K, V]) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.m[name]; ok {
+		panic("zcache.Register: name already registered: " + name)
+	}
+	if registry.m == nil {
+		registry.m = make(map[string]any)
+	}
+	registry.m[name] = c
+
+	c.mu.Lock()
+	if c.name == "" {
+		c.name = name
+	}
+	c.mu.Unlock()
+}
+
+// Unregister removes the cache with this name from the registry. Does nothing
+// if there is no cache with this name.
+func Unregister(name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.m, name)
+}
+
+// Lookup gets a cache registered with Register().
+//
+// The boolean return indicates if a cache with this name and the given types
+// was found.
+func Lookup[K comparable, V any](name string) (*Cache[K, V], bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	c, ok := registry.m[name].(*Cache[K, V])
+	return c, ok
+}
+
+// Names gets the names of all registered caches, in alphabetical order.
+func Names() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	names := make([]string, 0, len(registry.m))
+	for n := range registry.m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,51 @@
+package zcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	a := New[string, int](NoExpiration, 0)
+	b := New[int, string](NoExpiration, 0)
+	b.SetName("custom")
+
+	Register("test-a", a)
+	Register("test-b", b)
+	defer Unregister("test-a")
+	defer Unregister("test-b")
+
+	if have, want := Names(), []string{"test-a", "test-b"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+	if n := a.Name(); n != "test-a" {
+		t.Error(n)
+	}
+	if n := b.Name(); n != "custom" {
+		t.Error(n)
+	}
+
+	if c, ok := Lookup[string, int]("test-a"); !ok || c != a {
+		t.Errorf("%v %v", c, ok)
+	}
+	if c, ok := Lookup[string, string]("test-a"); ok || c != nil {
+		t.Errorf("%v %v", c, ok)
+	}
+	if c, ok := Lookup[string, int]("test-x"); ok || c != nil {
+		t.Errorf("%v %v", c, ok)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("no panic")
+			}
+		}()
+		Register("test-a", a)
+	}()
+
+	Unregister("test-b")
+	if have, want := Names(), []string{"test-a"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+}
@@ -0,0 +1,1222 @@
+package zcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sharded is a cache that spreads the keys over several independently locked
+// caches, to reduce lock contention when there are many concurrent writers.
+//
+// Every shard is a regular Cache with its own janitor. Methods that operate on
+// a single key only lock the shard for that key; methods that operate on the
+// whole cache such as Items() and Keys() lock every shard in turn, and are not
+// atomic across shards. Settings such as OnEvicted() are applied to every
+// shard.
+//
+// Some methods behave slightly different than on Cache:
+//
+//   - MaxItems() and MaxCost() limit every shard to an equal part of the
+//     maximum, so items may be evicted before the total reaches the maximum.
+//   - OnEvictedBatch() is called once for every shard.
+//   - ExpiryNotifications() queues up to buffer keys for every shard.
+//   - History() combines the snapshots of all shards, which are taken at
+//     slightly different times.
+//   - RenameFunc() locks all shards at once.
+type Sharded[K comparable, V any] struct {
+	shards    []*Cache[K, V]
+	hash      func(K) uint64
+	normalize atomic.Value // func(K) K
+
+	mu         sync.Mutex // Protects notifyStop.
+	notifyStop chan struct{}
+}
+
+var _ Cacher[string, any] = &Sharded[string, any]{}
+
+// NewSharded creates a new cache with n shards.
+//
+// The shard for a key is picked with hash. If it's nil a default hash is used,
+// which works for keys that are strings, numbers, bools, pointers, or channels;
+// pointers and channels are hashed by address. This panics if hash is nil and
+// K is another type, such as a struct or array.
+//
+// See New() for the meaning of the other parameters.
+func NewSharded[K comparable, V any](n int, defaultExpiration, cleanupInterval time.Duration, hash func(K) uint64) *Sharded[K, V] {
+	if n < 1 {
+		n = 1
+	}
+	if hash == nil {
+		if !hashable[K]() {
+			panic(fmt.Sprintf("zcache.NewSharded: need a hash function for keys of type %s",
+				reflect.TypeOf((*K)(nil)).Elem()))
+		}
+		hash = defaultHash[K]
+	}
+	s := &Sharded[K, V]{
+		shards: make([]*Cache[K, V], n),
+		hash:   hash,
+	}
+	for i := range s.shards {
+		s.shards[i] = New[K, V](defaultExpiration, cleanupInterval)
+	}
+	return s
+}
+
+// index gets the index of the shard for the key.
+func (s *Sharded[K, V]) index(k K) int {
+	if f, _ := s.normalize.Load().(func(K) K); f != nil {
+		k = f(k)
+	}
+	return int(s.hash(k) % uint64(len(s.shards)))
+}
+
+func (s *Sharded[K, V]) shard(k K) *Cache[K, V] { return s.shards[s.index(k)] }
+
+// byShard groups the keys by shard.
+func (s *Sharded[K, V]) byShard(keys []K) map[int][]K {
+	m := make(map[int][]K)
+	for _, k := range keys {
+		i := s.index(k)
+		m[i] = append(m[i], k)
+	}
+	return m
+}
+
+// Set a cache item, replacing any existing item.
+func (s *Sharded[K, V]) Set(k K, v V) { s.shard(k).Set(k, v) }
+
+// SetWithExpire sets a cache item, replacing any existing item.
+//
+// If the duration is 0 (DefaultExpiration), the cache's default expiration time
+// is used. If it is -1 (NoExpiration), the item never expires.
+func (s *Sharded[K, V]) SetWithExpire(k K, v V, d time.Duration) { s.shard(k).SetWithExpire(k, v, d) }
+
+// SetKeepTTL replaces the value of an existing item, keeping the expiration
+// time.
+func (s *Sharded[K, V]) SetKeepTTL(k K, v V) bool { return s.shard(k).SetKeepTTL(k, v) }
+
+// TrySet sets a cache item if the lock can be acquired without waiting; see
+// Cache.TrySet().
+func (s *Sharded[K, V]) TrySet(k K, v V, d time.Duration) bool { return s.shard(k).TrySet(k, v, d) }
+
+// SetWithEvict sets a cache item with its own OnEvicted callback; see
+// Cache.SetWithEvict().
+func (s *Sharded[K, V]) SetWithEvict(k K, v V, d time.Duration, onEvict func(K, V)) {
+	s.shard(k).SetWithEvict(k, v, d, onEvict)
+}
+
+// SetWithMaxUses sets a cache item which is deleted after it's retrieved n
+// times; see Cache.SetWithMaxUses().
+func (s *Sharded[K, V]) SetWithMaxUses(k K, v V, d time.Duration, n int) {
+	s.shard(k).SetWithMaxUses(k, v, d, n)
+}
+
+// SetErr stores an error for the key; see Cache.SetErr().
+func (s *Sharded[K, V]) SetErr(k K, err error, d time.Duration) { s.shard(k).SetErr(k, err, d) }
+
+// SetLazy sets a cache item with a function that's run when it's first
+// retrieved; see Cache.SetLazy().
+func (s *Sharded[K, V]) SetLazy(k K, f func() V, d time.Duration) { s.shard(k).SetLazy(k, f, d) }
+
+// SetUntil sets a cache item which is deleted when the done channel is closed;
+// see Cache.SetUntil().
+func (s *Sharded[K, V]) SetUntil(k K, v V, done <-chan struct{}, d time.Duration) {
+	s.shard(k).SetUntil(k, v, done, d)
+}
+
+// Entry creates a new Entry to set the key k; see Cache.Entry().
+func (s *Sharded[K, V]) Entry(k K) Entry[K, V] { return s.shard(k).Entry(k) }
+
+// Touch replaces the expiry of a key with the default expiration and returns
+// the current value, if any.
+func (s *Sharded[K, V]) Touch(k K) (V, bool) { return s.shard(k).Touch(k) }
+
+// TouchWithExpire replaces the expiry of a key and returns the current value, if
+// any.
+func (s *Sharded[K, V]) TouchWithExpire(k K, d time.Duration) (V, bool) {
+	return s.shard(k).TouchWithExpire(k, d)
+}
+
+// ExpireMany replaces the expiry of all the given keys.
+//
+// The keys are updated with a single lock per shard; it returns the number of
+// items that were updated.
+func (s *Sharded[K, V]) ExpireMany(keys []K, d time.Duration) int {
+	var n int
+	for i, keys := range s.byShard(keys) {
+		n += s.shards[i].ExpireMany(keys, d)
+	}
+	return n
+}
+
+// Revalidate checks if an item is still valid; see Cache.Revalidate().
+func (s *Sharded[K, V]) Revalidate(k K, check func(V) (stillValid bool, newTTL time.Duration)) bool {
+	return s.shard(k).Revalidate(k, check)
+}
+
+// Add an item to the cache only if it doesn't exist yet or if it has expired.
+//
+// It will return an error if the cache key already exists.
+func (s *Sharded[K, V]) Add(k K, v V) error { return s.shard(k).Add(k, v) }
+
+// AddWithExpire adds an item to the cache only if it doesn't exist yet or if it
+// has expired.
+func (s *Sharded[K, V]) AddWithExpire(k K, v V, d time.Duration) error {
+	return s.shard(k).AddWithExpire(k, v, d)
+}
+
+// AddGetExisting adds an item, or gets the existing item; see
+// Cache.AddGetExisting().
+func (s *Sharded[K, V]) AddGetExisting(k K, v V, d time.Duration) (Item[V], bool, error) {
+	return s.shard(k).AddGetExisting(k, v, d)
+}
+
+// GetOrAdd gets the value for the key, or sets it to v if the key doesn't exist
+// or has expired, using the default expiration.
+func (s *Sharded[K, V]) GetOrAdd(k K, v V) V { return s.shard(k).GetOrAdd(k, v) }
+
+// GetOrAddWithExpire is like GetOrAdd(), but with an expiration.
+func (s *Sharded[K, V]) GetOrAddWithExpire(k K, v V, d time.Duration) V {
+	return s.shard(k).GetOrAddWithExpire(k, v, d)
+}
+
+// Replace sets a new value for the key only if it already exists and isn't
+// expired.
+//
+// It will return an error if the cache key doesn't exist.
+func (s *Sharded[K, V]) Replace(k K, v V) error { return s.shard(k).Replace(k, v) }
+
+// ReplaceWithExpire sets a new value for the key only if it already exists and
+// isn't expired.
+func (s *Sharded[K, V]) ReplaceWithExpire(k K, v V, d time.Duration) error {
+	return s.shard(k).ReplaceWithExpire(k, v, d)
+}
+
+// Get an item from the cache.
+//
+// Returns the item or the zero value and a bool indicating whether the key is
+// set.
+func (s *Sharded[K, V]) Get(k K) (V, bool) { return s.shard(k).Get(k) }
+
+// GetWithin gets an item, waiting at most maxWait for the lock; see
+// Cache.GetWithin().
+func (s *Sharded[K, V]) GetWithin(k K, maxWait time.Duration) (v V, ok bool, busy bool) {
+	return s.shard(k).GetWithin(k, maxWait)
+}
+
+// GetResult gets an item, or the error stored with SetErr().
+func (s *Sharded[K, V]) GetResult(k K) (V, error, bool) { return s.shard(k).GetResult(k) }
+
+// GetOrSet gets an item, or sets it to the value returned by f if it doesn't
+// exist yet or if it has expired.
+func (s *Sharded[K, V]) GetOrSet(k K, f func() (V, time.Duration)) V {
+	return s.shard(k).GetOrSet(k, f)
+}
+
+// GetOrSetErr is like GetOrSet, but f can return an error, in which case the
+// value isn't stored.
+func (s *Sharded[K, V]) GetOrSetErr(k K, f func() (V, time.Duration, error)) (V, error) {
+	return s.shard(k).GetOrSetErr(k, f)
+}
+
+// GetOrSetContext is like GetOrSetErr(), but stops waiting when the context is
+// cancelled; see Cache.GetOrSetContext().
+func (s *Sharded[K, V]) GetOrSetContext(ctx context.Context, k K, f func(context.Context) (V, time.Duration, error)) (V, error) {
+	return s.shard(k).GetOrSetContext(ctx, k, f)
+}
+
+// Promise gets a promise for the key; see Cache.Promise().
+func (s *Sharded[K, V]) Promise(k K) *Promise[V] { return s.shard(k).Promise(k) }
+
+// GetFresh gets an item, or loads it with f; see Cache.GetFresh().
+func (s *Sharded[K, V]) GetFresh(k K, f func() (V, error)) (V, error) {
+	return s.shard(k).GetFresh(k, f)
+}
+
+// GetStale gets an item from the cache without checking if it's expired.
+func (s *Sharded[K, V]) GetStale(k K) (v V, expired bool, ok bool) { return s.shard(k).GetStale(k) }
+
+// GetWithExpire returns an item and its expiration time when it exists.
+func (s *Sharded[K, V]) GetWithExpire(k K) (V, time.Time, bool) { return s.shard(k).GetWithExpire(k) }
+
+// Modify the value of an existing key; this can be used for appending to a list
+// or setting map keys.
+func (s *Sharded[K, V]) Modify(k K, f func(V) V) (V, bool) { return s.shard(k).Modify(k, f) }
+
+// ModifyErr is like Modify(), but the function can return an error; see
+// Cache.ModifyErr().
+func (s *Sharded[K, V]) ModifyErr(k K, f func(V) (V, error)) (V, error) {
+	return s.shard(k).ModifyErr(k, f)
+}
+
+// ModifyMany modifies the values of many keys, with a single lock per shard.
+func (s *Sharded[K, V]) ModifyMany(keys []K, f func(K, V) V) []ModifyResult[V] {
+	res := make([]ModifyResult[V], len(keys))
+	pos := make(map[int][]int)
+	for j, k := range keys {
+		i := s.index(k)
+		pos[i] = append(pos[i], j)
+	}
+	for i, p := range pos {
+		sk := make([]K, 0, len(p))
+		for _, j := range p {
+			sk = append(sk, keys[j])
+		}
+		for n, r := range s.shards[i].ModifyMany(sk, f) {
+			res[p[n]] = r
+		}
+	}
+	return res
+}
+
+// ModifyOrSet applies f to the value of the key if it's set, or stores def; see
+// Cache.ModifyOrSet().
+func (s *Sharded[K, V]) ModifyOrSet(k K, f func(V) V, def V) V {
+	return s.shard(k).ModifyOrSet(k, f, def)
+}
+
+// lock the write locks of the shards in order, and return a function to unlock
+// them.
+func (s *Sharded[K, V]) lock(idx ...int) func() {
+	sort.Ints(idx)
+	var locked []int
+	for _, i := range idx {
+		if len(locked) > 0 && locked[len(locked)-1] == i {
+			continue
+		}
+		s.shards[i].mu.Lock()
+		locked = append(locked, i)
+	}
+	return func() {
+		for _, i := range locked {
+			s.shards[i].unlock()
+		}
+	}
+}
+
+// move an item taken with take() from another shard to c; the locks for both
+// shards must be held.
+func (c *cache[K, V]) move(dst K, m movedItem[K, V]) {
+	// The goroutine for SetUntil() only looks at the shard it was started
+	// for, so stop it and start a new one for this shard.
+	var p *pin[K]
+	if m.pin != nil {
+		close(m.pin.stop)
+		p = &pin[K]{stop: make(chan struct{}), done: m.pin.done}
+		m.pin = p
+	}
+	c.put(dst, m)
+	if c.overLimit() {
+		c.evictOverflow()
+	}
+	if p != nil {
+		go c.watch(p)
+	}
+}
+
+// Rename a key; the value and expiry will be left untouched; onEvicted will not
+// be called.
+//
+// Existing keys will be overwritten; returns false is the src key doesn't
+// exist.
+func (s *Sharded[K, V]) Rename(src, dst K) bool {
+	si, di := s.index(src), s.index(dst)
+	if si == di {
+		return s.shards[si].Rename(src, dst)
+	}
+
+	sc, dc := s.shards[si].cache, s.shards[di].cache
+	defer s.lock(si, di)()
+	if sc.rejectWrite() {
+		return false
+	}
+	src, dst = sc.key(src), dc.key(dst)
+	if dc.checkKey("zcache.Rename", dst) != nil {
+		return false
+	}
+	item, ok := sc.items[src]
+	if !ok || (item.Expiration > 0 && sc.nanotime() > item.Expiration) {
+		return false
+	}
+	dc.move(dst, sc.take(src))
+	return true
+}
+
+// RenameFunc renames all keys for which the function returns true, with the
+// locks for all shards held; see Cache.RenameFunc().
+func (s *Sharded[K, V]) RenameFunc(f func(K) (K, bool)) int {
+	all := make([]int, len(s.shards))
+	for i := range all {
+		all[i] = i
+	}
+	defer s.lock(all...)()
+	if s.shards[0].rejectWrite() {
+		return 0
+	}
+
+	type rename struct {
+		src, dst K
+		from, to *cache[K, V]
+		m        movedItem[K, V]
+	}
+	var renames []rename
+	for _, sh := range s.shards {
+		c := sh.cache
+		now := c.nanotime()
+		for k, item := range c.items {
+			if item.Expiration > 0 && now > item.Expiration {
+				continue
+			}
+			if dst, ok := f(k); ok && dst != k {
+				to := s.shard(dst).cache
+				if dst = to.key(dst); to.checkKey("zcache.Rename", dst) == nil {
+					renames = append(renames, rename{src: k, dst: dst, from: c, to: to})
+				}
+			}
+		}
+	}
+
+	// Take all items first, so that renaming e.g. a→b and b→c works.
+	for i := range renames {
+		renames[i].m = renames[i].from.take(renames[i].src)
+	}
+	for _, r := range renames {
+		if r.from == r.to {
+			r.to.put(r.dst, r.m)
+		} else {
+			r.to.move(r.dst, r.m)
+		}
+	}
+	return len(renames)
+}
+
+// Pop gets an item from the cache and deletes it.
+func (s *Sharded[K, V]) Pop(k K) (V, bool) { return s.shard(k).Pop(k) }
+
+// Refresh loads a new value for the key with f; see Cache.Refresh().
+func (s *Sharded[K, V]) Refresh(k K, f func() (V, error)) error { return s.shard(k).Refresh(k, f) }
+
+// Items returns a copy of all unexpired items in all shards.
+func (s *Sharded[K, V]) Items() map[K]Item[V] {
+	m := make(map[K]Item[V])
+	for _, sh := range s.shards {
+		for k, v := range sh.Items() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// ItemsFrozen returns a read-only view of all items in all shards; see
+// Cache.ItemsFrozen(). All shards are read-locked until the view is closed.
+func (s *Sharded[K, V]) ItemsFrozen() *ItemsView[K, V] {
+	caches := make([]*cache[K, V], len(s.shards))
+	for i, sh := range s.shards {
+		caches[i] = sh.cache
+	}
+	return newItemsView(s.index, caches...)
+}
+
+// ItemsWhere returns a copy of all unexpired items for which the filter
+// function returns true.
+func (s *Sharded[K, V]) ItemsWhere(filter func(K, Item[V]) bool) map[K]Item[V] {
+	m := make(map[K]Item[V])
+	for _, sh := range s.shards {
+		for k, v := range sh.ItemsWhere(filter) {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// ItemsAll returns a copy of all items in all shards, including expired items.
+func (s *Sharded[K, V]) ItemsAll() map[K]Item[V] {
+	m := make(map[K]Item[V])
+	for _, sh := range s.shards {
+		for k, v := range sh.ItemsAll() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Keys gets a list of all keys in all shards, in no particular order.
+func (s *Sharded[K, V]) Keys() []K {
+	var keys []K
+	for _, sh := range s.shards {
+		keys = append(keys, sh.Keys()...)
+	}
+	return keys
+}
+
+// KeysAll gets a list of all keys in all shards, including expired items.
+func (s *Sharded[K, V]) KeysAll() []K {
+	var keys []K
+	for _, sh := range s.shards {
+		keys = append(keys, sh.KeysAll()...)
+	}
+	return keys
+}
+
+// ExpiringSoon gets up to n keys that are closest to expiring, ordered by
+// expiration time.
+func (s *Sharded[K, V]) ExpiringSoon(n int) []K {
+	if n < 1 {
+		return nil
+	}
+	type exp struct {
+		k K
+		e int64
+	}
+	var all []exp
+	for _, sh := range s.shards {
+		keys := sh.ExpiringSoon(n)
+		sh.mu.RLock()
+		for _, k := range keys {
+			if v, ok := sh.items[k]; ok {
+				all = append(all, exp{k, v.Expiration})
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].e < all[j].e })
+	if n > len(all) {
+		n = len(all)
+	}
+	keys := make([]K, 0, n)
+	for _, e := range all[:n] {
+		keys = append(keys, e.k)
+	}
+	return keys
+}
+
+// ItemCount returns the number of items in all shards.
+//
+// This may include items that have expired but have not yet been cleaned up.
+func (s *Sharded[K, V]) ItemCount() int {
+	var n int
+	for _, sh := range s.shards {
+		n += sh.ItemCount()
+	}
+	return n
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
+func (s *Sharded[K, V]) Delete(k K) { s.shard(k).Delete(k) }
+
+// TryDelete deletes an item if the lock can be acquired without waiting; see
+// Cache.TryDelete().
+func (s *Sharded[K, V]) TryDelete(k K) bool { return s.shard(k).TryDelete(k) }
+
+// BindContext deletes the keys when the context is done; see
+// Cache.BindContext().
+func (s *Sharded[K, V]) BindContext(ctx context.Context, keys ...K) {
+	for i, keys := range s.byShard(keys) {
+		s.shards[i].BindContext(ctx, keys...)
+	}
+}
+
+// DeleteExpired deletes all expired items from all shards.
+func (s *Sharded[K, V]) DeleteExpired() {
+	for _, sh := range s.shards {
+		sh.DeleteExpired()
+	}
+}
+
+// WriteMetrics writes metrics for the cache in the Prometheus text exposition
+// format; see Cache.WriteMetrics().
+//
+// The metrics are for all shards combined.
+func (s *Sharded[K, V]) WriteMetrics(w io.Writer) error { return WriteMetrics(w, s) }
+
+func (s *Sharded[K, V]) metrics() []metric {
+	var (
+		ms  []metric
+		idx = make(map[string]int)
+	)
+	for _, sh := range s.shards {
+		for _, m := range sh.metrics() {
+			i, ok := idx[m.name]
+			if !ok {
+				idx[m.name] = len(ms)
+				ms = append(ms, m)
+				continue
+			}
+			switch m.name {
+			case "zcache_items", "zcache_frozen_writes_total", "zcache_janitor_last_deleted":
+				ms[i].value += m.value
+			default:
+				ms[i].value = math.Max(ms[i].value, m.value)
+			}
+		}
+	}
+	return ms
+}
+
+// JanitorStatus gets the status of the janitors of all shards.
+//
+// The cache is reported as running if the janitor of any shard is running,
+// with the most recent run and the total number of deleted items.
+func (s *Sharded[K, V]) JanitorStatus() (running bool, lastRun time.Time, lastDeleted int) {
+	for _, sh := range s.shards {
+		r, l, d := sh.JanitorStatus()
+		running = running || r
+		if l.After(lastRun) {
+			lastRun = l
+		}
+		lastDeleted += d
+	}
+	return running, lastRun, lastDeleted
+}
+
+// JanitorLockTime gets the longest time the last janitor run held the write
+// lock of a shard, and the longest janitor interval.
+func (s *Sharded[K, V]) JanitorLockTime() (took, interval time.Duration) {
+	for _, sh := range s.shards {
+		t, i := sh.JanitorLockTime()
+		if t > took {
+			took = t
+		}
+		if i > interval {
+			interval = i
+		}
+	}
+	return took, interval
+}
+
+// JanitorBackoff makes the janitor of every shard run less often if it holds
+// the write lock for too long; see Cache.JanitorBackoff().
+func (s *Sharded[K, V]) JanitorBackoff(threshold, maxInterval time.Duration, notify func(interval, took time.Duration)) {
+	for _, sh := range s.shards {
+		sh.JanitorBackoff(threshold, maxInterval, notify)
+	}
+}
+
+// JanitorSchedule changes when the janitors of all shards run; see
+// Cache.JanitorSchedule().
+func (s *Sharded[K, V]) JanitorSchedule(delay, align time.Duration) {
+	for _, sh := range s.shards {
+		sh.JanitorSchedule(delay, align)
+	}
+}
+
+// Validate scans all shards for anomalies; see Cache.Validate().
+func (s *Sharded[K, V]) Validate(maxTTL time.Duration) []Problem[K] {
+	var probs []Problem[K]
+	for _, sh := range s.shards {
+		probs = append(probs, sh.Validate(maxTTL)...)
+	}
+	return probs
+}
+
+// DeleteAll deletes all items from all shards and returns them.
+func (s *Sharded[K, V]) DeleteAll() map[K]Item[V] {
+	m := make(map[K]Item[V])
+	for _, sh := range s.shards {
+		for k, v := range sh.DeleteAll() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// DeleteFunc deletes and returns items for which the filter returns true; see
+// Cache.DeleteFunc().
+//
+// The shards are processed in turn; returning stop also stops processing the
+// remaining shards.
+func (s *Sharded[K, V]) DeleteFunc(filter func(key K, item Item[V]) (del, stop bool)) map[K]Item[V] {
+	var (
+		m       = make(map[K]Item[V])
+		stopped bool
+	)
+	for _, sh := range s.shards {
+		for k, v := range sh.DeleteFunc(func(k K, item Item[V]) (bool, bool) {
+			del, stop := filter(k, item)
+			stopped = stopped || stop
+			return del, stop
+		}) {
+			m[k] = v
+		}
+		if stopped {
+			break
+		}
+	}
+	return m
+}
+
+// DeleteFuncWithin is like DeleteFunc(), but stops after the time budget is
+// used; see Cache.DeleteFuncWithin().
+//
+// The keys of all shards are snapshotted when the cursor is created, and the
+// shards are processed in turn.
+func (s *Sharded[K, V]) DeleteFuncWithin(budget time.Duration, cur *Cursor[K], filter func(key K, item Item[V]) bool) (map[K]Item[V], *Cursor[K]) {
+	start := time.Now()
+	if cur == nil {
+		cur = &Cursor[K]{}
+		for _, sh := range s.shards {
+			cur.keys = append(cur.keys, sh.KeysAll()...)
+		}
+	}
+
+	m := map[K]Item[V]{}
+	keys := cur.keys
+	for len(keys) > 0 {
+		// The keys were added per shard, so process the run of keys for the
+		// same shard at once.
+		i, n := s.index(keys[0]), 1
+		for n < len(keys) && s.index(keys[n]) == i {
+			n++
+		}
+		del, rest := s.shards[i].DeleteFuncWithin(budget-time.Since(start), &Cursor[K]{keys: keys[:n]}, filter)
+		for k, v := range del {
+			m[k] = v
+		}
+		if rest != nil { // The remaining keys are always at the end.
+			return m, &Cursor[K]{keys: keys[n-len(rest.keys):]}
+		}
+		keys = keys[n:]
+		if len(keys) > 0 && time.Since(start) > budget {
+			return m, &Cursor[K]{keys: keys}
+		}
+	}
+	return m, nil
+}
+
+// Reset deletes all items from all shards without calling OnEvicted.
+func (s *Sharded[K, V]) Reset() {
+	for _, sh := range s.shards {
+		sh.Reset()
+	}
+}
+
+// ReleaseMemory deletes a fraction of the items of every shard; see
+// Cache.ReleaseMemory().
+func (s *Sharded[K, V]) ReleaseMemory(fraction float64) int {
+	var n int
+	for _, sh := range s.shards {
+		n += sh.ReleaseMemory(fraction)
+	}
+	return n
+}
+
+// TrackFrequency enables tracking how often items are retrieved; see
+// Cache.TrackFrequency().
+func (s *Sharded[K, V]) TrackFrequency(enable bool, decay time.Duration) {
+	for _, sh := range s.shards {
+		sh.TrackFrequency(enable, decay)
+	}
+}
+
+// Frequency gets how often an item was retrieved; see Cache.Frequency().
+func (s *Sharded[K, V]) Frequency(k K) uint32 { return s.shard(k).Frequency(k) }
+
+// EvictLFU deletes the n least frequently used items from all shards; see
+// Cache.EvictLFU().
+func (s *Sharded[K, V]) EvictLFU(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	type freq struct {
+		k K
+		f uint32
+		e int64
+		i int
+	}
+	var all []freq
+	for i, sh := range s.shards {
+		c := sh.cache
+		c.mu.RLock()
+		if c.lfu != nil {
+			c.lfu.mu.Lock()
+			c.lfu.maybeDecay()
+		}
+		for k, v := range c.items {
+			e := v.Expiration
+			if e <= 0 {
+				e = math.MaxInt64
+			}
+			var f uint32
+			if c.lfu != nil {
+				f = c.lfu.counts[k]
+			}
+			all = append(all, freq{k, f, e, i})
+		}
+		if c.lfu != nil {
+			c.lfu.mu.Unlock()
+		}
+		c.mu.RUnlock()
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].f == all[j].f {
+			return all[i].e < all[j].e
+		}
+		return all[i].f < all[j].f
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+
+	evict := make(map[int][]K)
+	for _, f := range all[:n] {
+		evict[f.i] = append(evict[f.i], f.k)
+	}
+	var deleted int
+	for i, keys := range evict {
+		deleted += s.shards[i].evictKeys(keys)
+	}
+	return deleted
+}
+
+// evictKeys deletes the keys and calls the OnEvicted callbacks, returning the
+// number of deleted keys.
+func (c *cache[K, V]) evictKeys(keys []K) int {
+	var evictedItems []keyAndValue[K, V]
+	c.mu.Lock()
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return 0
+	}
+	var n int
+	for _, k := range keys {
+		if _, ok := c.items[k]; !ok {
+			continue
+		}
+		n++
+		v, onEvict := c.delete(k)
+		if onEvict != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{k, v, onEvict})
+		}
+	}
+	c.sortEvicted(evictedItems)
+	c.mu.Unlock()
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
+	}
+	return n
+}
+
+// MaxItems sets the maximum number of items; every shard is limited to an
+// equal part of n, rounded up. See Cache.MaxItems().
+func (s *Sharded[K, V]) MaxItems(n int, policy EvictionPolicy) {
+	per := (n + len(s.shards) - 1) / len(s.shards)
+	for _, sh := range s.shards {
+		sh.MaxItems(per, policy)
+	}
+}
+
+// MaxCost sets the maximum total cost of all items; every shard is limited to
+// an equal part of max, rounded up. See Cache.MaxCost().
+func (s *Sharded[K, V]) MaxCost(max int64, policy EvictionPolicy, weigher func(K, V) int64) {
+	n := int64(len(s.shards))
+	per := (max + n - 1) / n
+	for _, sh := range s.shards {
+		sh.MaxCost(per, policy, weigher)
+	}
+}
+
+// Cost gets the total cost of all items and the maximum of all shards.
+func (s *Sharded[K, V]) Cost() (cost, max int64) {
+	for _, sh := range s.shards {
+		c, m := sh.Cost()
+		cost, max = cost+c, max+m
+	}
+	return cost, max
+}
+
+// TryLockKey locks a key; see Cache.TryLockKey().
+func (s *Sharded[K, V]) TryLockKey(k K, ttl time.Duration) (string, bool) {
+	return s.shard(k).TryLockKey(k, ttl)
+}
+
+// UnlockKey unlocks a key locked with TryLockKey(); see Cache.UnlockKey().
+func (s *Sharded[K, V]) UnlockKey(k K, token string) bool { return s.shard(k).UnlockKey(k, token) }
+
+// OnEvicted sets an function to call when an item is evicted from any of the
+// shards.
+//
+// See Cache.OnEvicted().
+func (s *Sharded[K, V]) OnEvicted(f func(K, V)) {
+	for _, sh := range s.shards {
+		sh.OnEvicted(f)
+	}
+}
+
+// OnEvictedBatch sets a function to call with all items that were evicted at
+// once; it's called once for every shard. See Cache.OnEvictedBatch().
+func (s *Sharded[K, V]) OnEvictedBatch(f func(map[K]V)) {
+	for _, sh := range s.shards {
+		sh.OnEvictedBatch(f)
+	}
+}
+
+// AddEvictionHandler adds a function to call when an item is evicted from any
+// of the shards; see Cache.AddEvictionHandler().
+func (s *Sharded[K, V]) AddEvictionHandler(f func(K, V)) (remove func()) {
+	rm := make([]func(), 0, len(s.shards))
+	for _, sh := range s.shards {
+		rm = append(rm, sh.AddEvictionHandler(f))
+	}
+	return func() {
+		for _, r := range rm {
+			r()
+		}
+	}
+}
+
+// EvictionOrder sets the order in which OnEvicted is called for items that are
+// evicted at once; see Cache.EvictionOrder().
+func (s *Sharded[K, V]) EvictionOrder(less func(a, b K) bool) {
+	for _, sh := range s.shards {
+		sh.EvictionOrder(less)
+	}
+}
+
+// OnExpiring sets a function to call for items that are about to expire; see
+// Cache.OnExpiring().
+func (s *Sharded[K, V]) OnExpiring(lead time.Duration, f func(K, V)) {
+	for _, sh := range s.shards {
+		sh.OnExpiring(lead, f)
+	}
+}
+
+// ExpiryNotifications gets a channel on which the keys of expired items from
+// all shards are sent; see Cache.ExpiryNotifications().
+//
+// Up to buffer keys are queued for every shard.
+func (s *Sharded[K, V]) ExpiryNotifications(buffer int) <-chan K {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notifyStop != nil {
+		close(s.notifyStop)
+		s.notifyStop = nil
+	}
+	chans := make([]<-chan K, 0, len(s.shards))
+	for _, sh := range s.shards {
+		if ch := sh.ExpiryNotifications(buffer); ch != nil {
+			chans = append(chans, ch)
+		}
+	}
+	if len(chans) == 0 {
+		return nil
+	}
+
+	var (
+		out  = make(chan K)
+		stop = make(chan struct{})
+		wg   sync.WaitGroup
+	)
+	s.notifyStop = stop
+	wg.Add(len(chans))
+	for _, ch := range chans {
+		go func(ch <-chan K) {
+			defer wg.Done()
+			for k := range ch {
+				select {
+				case out <- k:
+				case <-stop:
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// DroppedExpiryNotifications gets the number of keys that were dropped because
+// the ExpiryNotifications() buffer of a shard was full.
+func (s *Sharded[K, V]) DroppedExpiryNotifications() uint64 {
+	var n uint64
+	for _, sh := range s.shards {
+		n += sh.DroppedExpiryNotifications()
+	}
+	return n
+}
+
+// KeepHistory keeps the last n snapshots of every shard; see
+// Cache.KeepHistory().
+func (s *Sharded[K, V]) KeepHistory(n int, interval time.Duration) {
+	for _, sh := range s.shards {
+		sh.KeepHistory(n, interval)
+	}
+}
+
+// History gets the snapshots taken with KeepHistory(), oldest first.
+//
+// Every snapshot contains the items of all shards; the time is that of the
+// snapshot of the first shard.
+func (s *Sharded[K, V]) History() []Snapshot[K] {
+	var all [][]Snapshot[K]
+	n := -1
+	for _, sh := range s.shards {
+		h := sh.History()
+		if n == -1 || len(h) < n {
+			n = len(h)
+		}
+		all = append(all, h)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	hist := make([]Snapshot[K], n)
+	for i := range hist {
+		hist[i].Items = make(map[K]Item[uint64])
+		for j, h := range all {
+			snap := h[len(h)-n+i] // Align on the newest snapshot.
+			if j == 0 {
+				hist[i].Time = snap.Time
+			}
+			for k, v := range snap.Items {
+				hist[i].Items[k] = v
+			}
+		}
+	}
+	return hist
+}
+
+// ExpireOnAccess deletes expired items when they're accessed; see
+// Cache.ExpireOnAccess().
+func (s *Sharded[K, V]) ExpireOnAccess(enable bool) {
+	for _, sh := range s.shards {
+		sh.ExpireOnAccess(enable)
+	}
+}
+
+// CoarseTime makes every shard read the time once every resolution; see
+// Cache.CoarseTime().
+func (s *Sharded[K, V]) CoarseTime(resolution time.Duration) {
+	for _, sh := range s.shards {
+		sh.CoarseTime(resolution)
+	}
+}
+
+// Strict enables strict mode; see Cache.Strict().
+func (s *Sharded[K, V]) Strict(enable bool) {
+	for _, sh := range s.shards {
+		sh.Strict(enable)
+	}
+}
+
+// Freeze makes all shards read-only; see Cache.Freeze().
+func (s *Sharded[K, V]) Freeze() {
+	for _, sh := range s.shards {
+		sh.Freeze()
+	}
+}
+
+// Unfreeze makes all shards writable again.
+func (s *Sharded[K, V]) Unfreeze() {
+	for _, sh := range s.shards {
+		sh.Unfreeze()
+	}
+}
+
+// Frozen reports if the cache is frozen, and the total number of writes that
+// were rejected.
+func (s *Sharded[K, V]) Frozen() (frozen bool, rejected uint64) {
+	for _, sh := range s.shards {
+		f, r := sh.Frozen()
+		frozen, rejected = frozen || f, rejected+r
+	}
+	return frozen, rejected
+}
+
+// SetName sets the name of this cache, for diagnostics; see Cache.SetName().
+func (s *Sharded[K, V]) SetName(name string) {
+	for _, sh := range s.shards {
+		sh.SetName(name)
+	}
+}
+
+// Name gets the name set with SetName().
+func (s *Sharded[K, V]) Name() string { return s.shards[0].Name() }
+
+// DefaultValue sets the value Get() returns for missing keys; see
+// Cache.DefaultValue().
+func (s *Sharded[K, V]) DefaultValue(v V) {
+	for _, sh := range s.shards {
+		sh.DefaultValue(v)
+	}
+}
+
+// KeyNormalizer sets a function to normalize keys; see Cache.KeyNormalizer().
+//
+// The shard for a key is picked after normalizing it. Keys that are already in
+// the cache are not moved to another shard.
+func (s *Sharded[K, V]) KeyNormalizer(f func(K) K) {
+	s.normalize.Store(f)
+	for _, sh := range s.shards {
+		sh.KeyNormalizer(f)
+	}
+}
+
+// KeyFormatter sets a function to format keys; see Cache.KeyFormatter().
+func (s *Sharded[K, V]) KeyFormatter(f func(K) string) {
+	for _, sh := range s.shards {
+		sh.KeyFormatter(f)
+	}
+}
+
+// KeyValidator sets a function to validate keys; see Cache.KeyValidator().
+func (s *Sharded[K, V]) KeyValidator(f func(K) error) {
+	for _, sh := range s.shards {
+		sh.KeyValidator(f)
+	}
+}
+
+// ReadPipeline sets functions to apply to values when they're read; see
+// Cache.ReadPipeline().
+func (s *Sharded[K, V]) ReadPipeline(fs ...func(V) V) {
+	for _, sh := range s.shards {
+		sh.ReadPipeline(fs...)
+	}
+}
+
+// AutoTTL adjusts the default expiration of every shard to the hit rate; see
+// Cache.AutoTTL().
+func (s *Sharded[K, V]) AutoTTL(target float64, min, max time.Duration) {
+	for _, sh := range s.shards {
+		sh.AutoTTL(target, min, max)
+	}
+}
+
+// DefaultExpiration gets the default expiration of the first shard.
+func (s *Sharded[K, V]) DefaultExpiration() time.Duration { return s.shards[0].DefaultExpiration() }
+
+// TrackTTL enables TTL statistics; see Cache.TrackTTL().
+func (s *Sharded[K, V]) TrackTTL(enable bool, group func(K) K) {
+	for _, sh := range s.shards {
+		sh.TrackTTL(enable, group)
+	}
+}
+
+// TTLStats gets the TTL statistics of all shards; see Cache.TTLStats().
+func (s *Sharded[K, V]) TTLStats() map[K]TTLStats {
+	m := make(map[K]TTLStats)
+	for _, sh := range s.shards {
+		for k, v := range sh.TTLStats() {
+			m[k] = m[k].merge(v)
+		}
+	}
+	return m
+}
+
+// TrackKeys tracks statistics for the keys; see Cache.TrackKeys().
+func (s *Sharded[K, V]) TrackKeys(keys ...K) {
+	byShard := s.byShard(keys)
+	for i, sh := range s.shards {
+		sh.TrackKeys(byShard[i]...)
+	}
+}
+
+// KeyStats gets the statistics for the keys tracked with TrackKeys().
+func (s *Sharded[K, V]) KeyStats() map[K]KeyStats {
+	var m map[K]KeyStats
+	for _, sh := range s.shards {
+		for k, v := range sh.KeyStats() {
+			if m == nil {
+				m = make(map[K]KeyStats)
+			}
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// defaultHash hashes keys with FNV-1a. Pointers and channels are hashed by
+// address, and negative zero floats the same as positive zero, to match how
+// they're compared as map keys.
+//
+// The key must be of a type for which hashable() is true.
+func defaultHash[K comparable](k K) uint64 {
+	switch kk := any(k).(type) {
+	case string:
+		return hashString(kk)
+	case int:
+		return hashUint(uint64(kk))
+	case int64:
+		return hashUint(uint64(kk))
+	case uint64:
+		return hashUint(kk)
+	}
+
+	rv := reflect.ValueOf(k)
+	switch rv.Kind() {
+	case reflect.String:
+		return hashString(rv.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return hashUint(uint64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return hashUint(rv.Uint())
+	case reflect.Bool:
+		if rv.Bool() {
+			return hashUint(1)
+		}
+		return hashUint(0)
+	case reflect.Float32, reflect.Float64:
+		return hashUint(floatBits(rv.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := rv.Complex()
+		return hashUint(floatBits(real(c))) ^ hashUint(floatBits(imag(c)))*31
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Chan:
+		return hashUint(uint64(rv.Pointer()))
+	}
+	panic(fmt.Sprintf("zcache.defaultHash: can't hash %T", k))
+}
+
+// hashable reports if defaultHash() can hash keys of type K.
+func hashable[K comparable]() bool {
+	switch reflect.TypeOf((*K)(nil)).Elem().Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128,
+		reflect.Pointer, reflect.UnsafePointer, reflect.Chan:
+		return true
+	}
+	return false
+}
+
+func floatBits(f float64) uint64 {
+	if f == 0 { // Also true for -0.
+		f = 0
+	}
+	return math.Float64bits(f)
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset)
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint64(s[i])) * fnvPrime
+	}
+	return h
+}
+
+func hashUint(n uint64) uint64 {
+	h := uint64(fnvOffset)
+	for i := 0; i < 8; i++ {
+		h = (h ^ (n & 0xff)) * fnvPrime
+		n >>= 8
+	}
+	return h
+}
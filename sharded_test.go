@@ -0,0 +1,306 @@
+package zcache
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSharded(t *testing.T) {
+	tc := NewSharded[string, int](4, NoExpiration, 0, nil)
+
+	for i := 0; i < 100; i++ {
+		tc.Set(strconv.Itoa(i), i)
+	}
+	if n := tc.ItemCount(); n != 100 {
+		t.Fatal(n)
+	}
+	for _, sh := range tc.shards {
+		if n := sh.ItemCount(); n == 0 || n == 100 {
+			t.Errorf("keys not spread over shards: %d", n)
+		}
+	}
+
+	if v, ok := tc.Get("42"); !ok || v != 42 {
+		t.Error(v, ok)
+	}
+	if err := tc.Add("42", 1); err == nil {
+		t.Error("no error")
+	}
+	if v, ok := tc.Modify("42", func(v int) int { return v + 1 }); !ok || v != 43 {
+		t.Error(v, ok)
+	}
+	if v, ok := tc.Pop("42"); !ok || v != 43 {
+		t.Error(v, ok)
+	}
+	if _, ok := tc.Get("42"); ok {
+		t.Error("not popped")
+	}
+
+	keys := tc.Keys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	if len(keys) != 99 || keys[0] != "0" {
+		t.Error(len(keys), keys[0])
+	}
+	if items := tc.Items(); len(items) != 99 || items["7"].Object != 7 {
+		t.Error(len(items), items["7"])
+	}
+
+	if n := tc.ExpireMany([]string{"1", "2", "3", "nonexistent"}, time.Nanosecond); n != 3 {
+		t.Error(n)
+	}
+	var evicted []string
+	tc.OnEvicted(func(k string, _ int) { evicted = append(evicted, k) })
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	sort.Strings(evicted)
+	if fmt.Sprint(evicted) != "[1 2 3]" {
+		t.Error(evicted)
+	}
+
+	if all := tc.DeleteAll(); len(all) != 96 {
+		t.Error(len(all))
+	}
+	if n := tc.ItemCount(); n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	tc := NewSharded[int, int](8, NoExpiration, 0, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tc.Set(i*100+j, j)
+				tc.Get(i*100 + j)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := tc.ItemCount(); n != 800 {
+		t.Error(n)
+	}
+}
+
+func TestDefaultHash(t *testing.T) {
+	type myString string
+	if defaultHash("a") == defaultHash("b") {
+		t.Error("string")
+	}
+	if defaultHash("a") != defaultHash(myString("a")) {
+		t.Error("named string")
+	}
+	if defaultHash(1) == defaultHash(2) {
+		t.Error("int")
+	}
+	negZero := math.Copysign(0, -1)
+	if defaultHash(0.0) != defaultHash(negZero) {
+		t.Error("negative zero")
+	}
+
+	a, b := new(int), new(int)
+	if defaultHash(a) == defaultHash(b) {
+		t.Error("pointer")
+	}
+
+	tc := NewSharded[*int, string](16, NoExpiration, 0, nil)
+	tc.Set(a, "a")
+	*a = 42
+	if _, ok := tc.Get(a); !ok {
+		t.Error("pointer key not found after changing the value")
+	}
+
+	tf := NewSharded[float64, string](16, NoExpiration, 0, nil)
+	tf.Set(0, "zero")
+	tf.Set(negZero, "negative zero")
+	if n := tf.ItemCount(); n != 1 {
+		t.Error(n)
+	}
+
+	for _, f := range []func(){
+		func() { NewSharded[struct{ a int }, string](2, NoExpiration, 0, nil) },
+		func() { NewSharded[[2]int, string](2, NoExpiration, 0, nil) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("no panic")
+				}
+			}()
+			f()
+		}()
+	}
+	NewSharded[struct{ a int }, string](2, NoExpiration, 0, func(k struct{ a int }) uint64 { return uint64(k.a) })
+}
+
+// byFirst puts keys starting with an even byte in the first shard, and keys
+// starting with an odd byte in the second.
+func byFirst(k string) uint64 { return uint64(k[0]) }
+
+func TestShardedRename(t *testing.T) {
+	tc := NewSharded[string, int](2, NoExpiration, 0, byFirst)
+
+	tc.Set("a1", 1)
+	if !tc.Rename("a1", "a2") {
+		t.Error("same shard")
+	}
+	if !tc.Rename("a2", "b1") {
+		t.Error("other shard")
+	}
+	if tc.Rename("a2", "b2") {
+		t.Error("renamed nonexistent key")
+	}
+	if have := fmt.Sprint(tc.shards[0].Keys(), tc.shards[1].Keys()); have != "[b1] []" {
+		t.Error(have)
+	}
+
+	done := make(chan struct{})
+	tc.SetUntil("a3", 3, done, NoExpiration)
+	tc.Rename("a3", "b3")
+	close(done)
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := tc.Get("b3"); ok {
+		t.Error("pinned item not deleted after moving it to another shard")
+	}
+
+	tc.Set("a1", 1)
+	tc.Set("a3", 3)
+	n := tc.RenameFunc(func(k string) (string, bool) {
+		if k[0] == 'a' {
+			return "b" + k[1:], true
+		}
+		if k[0] == 'b' {
+			return "c" + k[1:], true
+		}
+		return k, false
+	})
+	if n != 3 {
+		t.Error(n)
+	}
+	keys := tc.Keys()
+	sort.Strings(keys)
+	if have := fmt.Sprint(keys); have != "[b1 b3 c1]" {
+		t.Error(have)
+	}
+}
+
+func TestShardedDeleteFuncWithin(t *testing.T) {
+	tc := NewSharded[int, int](4, NoExpiration, 0, nil)
+	for i := 0; i < 1000; i++ {
+		tc.Set(i, i)
+	}
+
+	var (
+		cur     *Cursor[int]
+		deleted int
+		rounds  int
+	)
+	for {
+		var m map[int]Item[int]
+		m, cur = tc.DeleteFuncWithin(time.Nanosecond, cur, func(k int, _ Item[int]) bool { return k%2 == 0 })
+		deleted += len(m)
+		rounds++
+		if cur == nil {
+			break
+		}
+	}
+	if deleted != 500 || tc.ItemCount() != 500 {
+		t.Error(deleted, tc.ItemCount())
+	}
+	if rounds < 2 {
+		t.Error(rounds)
+	}
+}
+
+func TestShardedEvictLFU(t *testing.T) {
+	tc := NewSharded[string, int](2, NoExpiration, 0, byFirst)
+	tc.TrackFrequency(true, 0)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		tc.Set(k, 1)
+	}
+	tc.Get("a")
+	tc.Get("b")
+	tc.Get("b")
+	tc.Get("c")
+
+	if n := tc.EvictLFU(2); n != 2 {
+		t.Error(n)
+	}
+	keys := tc.Keys()
+	sort.Strings(keys)
+	if have := fmt.Sprint(keys); have != "[b c]" && have != "[a b]" {
+		t.Error(have)
+	}
+	if _, ok := tc.Get("d"); ok {
+		t.Error("d not evicted")
+	}
+}
+
+func TestShardedExpiryNotifications(t *testing.T) {
+	tc := NewSharded[string, int](2, NoExpiration, 0, byFirst)
+	ch := tc.ExpiryNotifications(10)
+
+	tc.SetWithExpire("a", 1, time.Nanosecond)
+	tc.SetWithExpire("b", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case k := <-ch:
+			got = append(got, k)
+		case <-time.After(time.Second):
+			t.Fatal("timeout", got)
+		}
+	}
+	sort.Strings(got)
+	if have := fmt.Sprint(got); have != "[a b]" {
+		t.Error(have)
+	}
+
+	if tc.ExpiryNotifications(0) != nil {
+		t.Error("not nil")
+	}
+	if _, ok := <-ch; ok {
+		t.Error("not closed")
+	}
+}
+
+func TestShardedMaxItems(t *testing.T) {
+	tc := NewSharded[string, int](2, NoExpiration, 0, byFirst)
+	tc.MaxItems(4, PolicyFIFO)
+	for _, k := range []string{"a1", "a2", "a3", "b1"} {
+		tc.Set(k, 1)
+	}
+	if have := fmt.Sprint(tc.shards[1].Keys()); have != "[a3 a2]" && have != "[a2 a3]" {
+		t.Error(have)
+	}
+	if n := tc.ItemCount(); n != 3 {
+		t.Error(n)
+	}
+}
+
+func TestShardedTTLStats(t *testing.T) {
+	tc := NewSharded[string, int](4, NoExpiration, 0, nil)
+	tc.TrackTTL(true, func(k string) string { return k[:1] })
+	for i := 0; i < 20; i++ {
+		tc.Set("a"+strconv.Itoa(i), i)
+	}
+	tc.Reset()
+
+	stats := tc.TTLStats()
+	if len(stats) != 1 || stats["a"].Items != 20 || stats["a"].Unused != 20 {
+		t.Errorf("%+v", stats)
+	}
+}
@@ -0,0 +1,76 @@
+package zcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenCache caches a single token, such as an OAuth or JWT token, and refreshes
+// it before it expires.
+//
+// The token is refreshed with the refresh callback if it's within the refresh
+// window of the expiry; the current token is returned while it's being
+// refreshed. If there is no token yet or if it has expired Get() will block
+// until a new token is retrieved.
+//
+// Only one refresh is run at a time, no matter how many goroutines call Get().
+type TokenCache[T any] struct {
+	refresh func(context.Context) (T, time.Time, error)
+	window  time.Duration
+	mu      sync.RWMutex
+	token   T
+	expires time.Time
+	flight  group[struct{}, T]
+}
+
+// NewTokenCache creates a new token cache.
+//
+// The refresh callback retrieves a new token and returns it with the time it
+// expires. The token is refreshed once it's within window of the expiry time.
+//
+// The refresh callback is run with a context that is never cancelled, as a
+// refresh is shared between callers; use a timeout in the callback if need be.
+func NewTokenCache[T any](window time.Duration, refresh func(context.Context) (T, time.Time, error)) *TokenCache[T] {
+	return &TokenCache[T]{refresh: refresh, window: window}
+}
+
+// Get the token, refreshing it if needed.
+//
+// The context is only used for waiting on a refresh; an error is returned if
+// the context is cancelled before the refresh completes.
+func (t *TokenCache[T]) Get(ctx context.Context) (T, error) {
+	t.mu.RLock()
+	token, expires := t.token, t.expires
+	t.mu.RUnlock()
+
+	now := time.Now()
+	if !expires.IsZero() && now.Before(expires) {
+		if now.Add(t.window).After(expires) {
+			t.start()
+		}
+		return token, nil
+	}
+	return t.start().wait(ctx)
+}
+
+// Reset discards the current token, so that the next Get() will refresh it.
+func (t *TokenCache[T]) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var zero T
+	t.token, t.expires = zero, time.Time{}
+}
+
+func (t *TokenCache[T]) start() *call[T] {
+	return t.flight.start(struct{}{}, func() (T, error) {
+		token, expires, err := t.refresh(context.Background())
+		if err != nil {
+			return token, err
+		}
+		t.mu.Lock()
+		t.token, t.expires = token, expires
+		t.mu.Unlock()
+		return token, nil
+	})
+}
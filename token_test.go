@@ -0,0 +1,84 @@
+package zcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("refresh", func(t *testing.T) {
+		var calls int32
+		tc := NewTokenCache(20*time.Millisecond, func(context.Context) (int32, time.Time, error) {
+			time.Sleep(5 * time.Millisecond)
+			n := atomic.AddInt32(&calls, 1)
+			return n, time.Now().Add(30 * time.Millisecond), nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tok, err := tc.Get(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+				if tok != 1 {
+					t.Errorf("token: %d", tok)
+				}
+			}()
+		}
+		wg.Wait()
+		if calls != 1 {
+			t.Fatalf("calls: %d", calls)
+		}
+
+		// Within the window: return the current token and refresh in the
+		// background.
+		time.Sleep(15 * time.Millisecond)
+		tok, _ := tc.Get(ctx)
+		if tok != 1 {
+			t.Errorf("token: %d", tok)
+		}
+		time.Sleep(10 * time.Millisecond)
+		tok, _ = tc.Get(ctx)
+		if tok != 2 {
+			t.Errorf("token: %d", tok)
+		}
+
+		tc.Reset()
+		tok, _ = tc.Get(ctx)
+		if tok != 3 {
+			t.Errorf("token: %d", tok)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		tc := NewTokenCache(time.Second, func(context.Context) (string, time.Time, error) {
+			return "", time.Time{}, errors.New("oh noes")
+		})
+		_, err := tc.Get(ctx)
+		if err == nil || err.Error() != "oh noes" {
+			t.Errorf("wrong error: %v", err)
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		tc := NewTokenCache(time.Second, func(context.Context) (string, time.Time, error) {
+			time.Sleep(50 * time.Millisecond)
+			return "tok", time.Now().Add(time.Hour), nil
+		})
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		_, err := tc.Get(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("wrong error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package zcache
+
+import (
+	"strings"
+	"time"
+)
+
+// Tree is a cache with hierarchical string keys, such as "user/42/posts".
+//
+// In addition to the usual operations all keys under a path can be listed and
+// deleted. This uses a trie of the keys, so it doesn't need to look at every
+// key in the cache.
+type Tree[V any] struct {
+	cache *Cache[string, V]
+	sep   string
+	root  *treeNode // Protected by cache.mu
+}
+
+type treeNode struct {
+	children map[string]*treeNode
+	leaf     bool
+}
+
+// NewTree creates a new cache with hierarchical keys, separated by sep.
+//
+// See New() for the meaning of the other parameters.
+func NewTree[V any](sep string, defaultExpiration, cleanupInterval time.Duration) *Tree[V] {
+	t := &Tree[V]{
+		cache: New[string, V](defaultExpiration, cleanupInterval),
+		sep:   sep,
+		root:  &treeNode{},
+	}
+	t.cache.OnEvicted(t.evicted)
+	return t
+}
+
+// Set a cache item, replacing any existing item.
+func (t *Tree[V]) Set(k string, v V) { t.SetWithExpire(k, v, DefaultExpiration) }
+
+// SetWithExpire sets a cache item, replacing any existing item.
+//
+// If the duration is 0 (DefaultExpiration), the cache's default expiration time
+// is used. If it is -1 (NoExpiration), the item never expires.
+func (t *Tree[V]) SetWithExpire(k string, v V, d time.Duration) {
+	c := t.cache
+	c.mu.Lock()
+	defer c.unlock()
+	c.set(k, v, d)
+
+	n := t.root
+	for _, p := range strings.Split(k, t.sep) {
+		child, ok := n.children[p]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*treeNode)
+			}
+			child = &treeNode{}
+			n.children[p] = child
+		}
+		n = child
+	}
+	n.leaf = true
+}
+
+// Get an item from the cache.
+//
+// Returns the item or the zero value and a bool indicating whether the key is
+// set.
+func (t *Tree[V]) Get(k string) (V, bool) {
+	return t.cache.Get(k)
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
+func (t *Tree[V]) Delete(k string) {
+	c := t.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(k)
+	t.remove(t.root, strings.Split(k, t.sep), false)
+}
+
+// KeysUnder lists all keys under the path, including the path itself if it's
+// set, in no particular order.
+func (t *Tree[V]) KeysUnder(path string) []string {
+	c := t.cache
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := t.find(path)
+	if n == nil {
+		return nil
+	}
+	var keys []string
+	now := c.nanotime()
+	t.walk(n, path, func(k string) {
+		if item, ok := c.items[k]; ok && (item.Expiration <= 0 || now <= item.Expiration) {
+			keys = append(keys, k)
+		}
+	})
+	return keys
+}
+
+// InvalidateSubtree deletes all keys under the path, including the path itself,
+// returning the number of deleted items.
+func (t *Tree[V]) InvalidateSubtree(path string) int {
+	c := t.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := t.find(path)
+	if n == nil {
+		return 0
+	}
+	var del int
+	t.walk(n, path, func(k string) {
+		if _, ok := c.items[k]; ok {
+			c.delete(k)
+			del++
+		}
+	})
+	t.remove(t.root, strings.Split(path, t.sep), true)
+	return del
+}
+
+// ItemCount returns the number of items in the cache.
+//
+// This may include items that have expired but have not yet been cleaned up.
+func (t *Tree[V]) ItemCount() int {
+	return t.cache.ItemCount()
+}
+
+// DeleteExpired deletes all expired items from the cache.
+func (t *Tree[V]) DeleteExpired() {
+	t.cache.DeleteExpired()
+}
+
+func (t *Tree[V]) find(path string) *treeNode {
+	n := t.root
+	for _, p := range strings.Split(path, t.sep) {
+		n = n.children[p]
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+func (t *Tree[V]) walk(n *treeNode, path string, f func(string)) {
+	if n.leaf {
+		f(path)
+	}
+	for p, child := range n.children {
+		t.walk(child, path+t.sep+p, f)
+	}
+}
+
+// remove the path from the trie, pruning nodes that are no longer needed; the
+// entire subtree is removed if subtree is set.
+//
+// Returns true if the node is now empty.
+func (t *Tree[V]) remove(n *treeNode, path []string, subtree bool) bool {
+	if len(path) == 0 {
+		n.leaf = false
+		if subtree {
+			n.children = nil
+		}
+		return len(n.children) == 0
+	}
+
+	child, ok := n.children[path[0]]
+	if !ok {
+		return false
+	}
+	if t.remove(child, path[1:], subtree) {
+		delete(n.children, path[0])
+	}
+	return !n.leaf && len(n.children) == 0
+}
+
+// evicted removes the key from the trie when the janitor deletes expired
+// items.
+func (t *Tree[V]) evicted(k string, _ V) {
+	c := t.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[k]; !ok {
+		t.remove(t.root, strings.Split(k, t.sep), false)
+	}
+}
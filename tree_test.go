@@ -0,0 +1,69 @@
+package zcache
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTree(t *testing.T) {
+	tr := NewTree[int]("/", NoExpiration, 0)
+
+	keys := func(path string) string {
+		k := tr.KeysUnder(path)
+		sort.Strings(k)
+		return fmt.Sprintf("%v", k)
+	}
+
+	tr.Set("a", 1)
+	tr.Set("a/b", 2)
+	tr.Set("a/b/c", 3)
+	tr.Set("a/b/d", 4)
+	tr.Set("a/e", 5)
+	tr.Set("x/y", 6)
+	tr.SetWithExpire("a/b/exp", 7, 1)
+	time.Sleep(time.Millisecond)
+
+	if h := keys("a"); h != "[a a/b a/b/c a/b/d a/e]" {
+		t.Error(h)
+	}
+	if h := keys("a/b"); h != "[a/b a/b/c a/b/d]" {
+		t.Error(h)
+	}
+	if h := keys("x"); h != "[x/y]" {
+		t.Error(h)
+	}
+	if h := keys("nonexistent"); h != "[]" {
+		t.Error(h)
+	}
+
+	tr.DeleteExpired()
+	if n := tr.find("a/b/exp"); n != nil {
+		t.Error("expired key still in trie")
+	}
+
+	tr.Delete("a/b/c")
+	if h := keys("a/b"); h != "[a/b a/b/d]" {
+		t.Error(h)
+	}
+
+	if n := tr.InvalidateSubtree("a/b"); n != 2 {
+		t.Errorf("deleted %d", n)
+	}
+	if h := keys("a"); h != "[a a/e]" {
+		t.Error(h)
+	}
+	if _, ok := tr.Get("a/b/d"); ok {
+		t.Error("a/b/d still in cache")
+	}
+	if n := tr.ItemCount(); n != 3 {
+		t.Errorf("ItemCount: %d", n)
+	}
+
+	tr.InvalidateSubtree("a")
+	tr.InvalidateSubtree("x")
+	if len(tr.root.children) != 0 || tr.ItemCount() != 0 {
+		t.Errorf("not empty: %v", tr.root.children)
+	}
+}
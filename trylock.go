@@ -0,0 +1,89 @@
+package zcache
+
+import "time"
+
+// GetWithin gets an item from the cache like Get(), but gives up if the cache
+// can't be read-locked within maxWait.
+//
+// The last return value is true if the cache was busy, in which case the other
+// return values should be ignored. This is useful for latency-critical code
+// that would rather fall back to something else than wait for a long-running
+// write operation such as DeleteExpired() on a large cache.
+func (c *cache[K, V]) GetWithin(k K, maxWait time.Duration) (v V, ok bool, busy bool) {
+	if !c.tryRLock(maxWait) {
+		return c.zero(), false, true
+	}
+	v, ok = c.getRLocked(k)
+	return v, ok, false
+}
+
+// tryRLock tries to acquire the read lock within maxWait.
+func (c *cache[K, V]) tryRLock(maxWait time.Duration) bool {
+	if c.mu.TryRLock() {
+		return true
+	}
+	var (
+		deadline = time.Now().Add(maxWait)
+		wait     = 10 * time.Microsecond
+	)
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return false
+		}
+		if wait > left {
+			wait = left
+		}
+		time.Sleep(wait)
+		if c.mu.TryRLock() {
+			return true
+		}
+		if wait < time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// TrySet sets an item like SetWithExpire(), but only if the cache can be
+// locked right away.
+//
+// It returns false if the cache is locked by another operation, in which case
+// nothing is stored. This is useful for best-effort writers (e.g. telemetry)
+// that shouldn't block on long-running operations.
+func (c *cache[K, V]) TrySet(k K, v V, d time.Duration) bool {
+	if !c.mu.TryLock() {
+		return false
+	}
+	defer c.unlock()
+	if c.rejectWrite() {
+		return true
+	}
+	k = c.key(k)
+	if c.checkKey("zcache.Set", k) != nil {
+		return true
+	}
+	c.set(k, v, d)
+	return true
+}
+
+// TryDelete deletes an item like Delete(), but only if the cache can be locked
+// right away.
+//
+// It returns false if the cache is locked by another operation, in which case
+// nothing is deleted.
+func (c *cache[K, V]) TryDelete(k K) bool {
+	if !c.mu.TryLock() {
+		return false
+	}
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return true
+	}
+	k = c.key(k)
+	v, onEvict := c.delete(k)
+	c.mu.Unlock()
+	if onEvict != nil {
+		onEvict(k, v)
+	}
+	return true
+}
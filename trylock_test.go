@@ -0,0 +1,61 @@
+package zcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetWithin(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+
+	if v, ok, busy := tc.GetWithin("a", time.Millisecond); v != 1 || !ok || busy {
+		t.Fatalf("%v %v %v", v, ok, busy)
+	}
+	if v, ok, busy := tc.GetWithin("x", time.Millisecond); v != 0 || ok || busy {
+		t.Fatalf("%v %v %v", v, ok, busy)
+	}
+
+	tc.mu.Lock()
+	start := time.Now()
+	if _, _, busy := tc.GetWithin("a", 5*time.Millisecond); !busy {
+		t.Fatal("not busy")
+	}
+	if took := time.Since(start); took < 5*time.Millisecond || took > time.Second {
+		t.Errorf("took %s", took)
+	}
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		tc.mu.Unlock()
+	}()
+	if v, ok, busy := tc.GetWithin("a", time.Second); v != 1 || !ok || busy {
+		t.Fatalf("%v %v %v", v, ok, busy)
+	}
+}
+
+func TestTrySetDelete(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	if !tc.TrySet("a", 1, DefaultExpiration) {
+		t.Fatal("TrySet failed")
+	}
+	if v, ok := tc.Get("a"); !ok || v != 1 {
+		t.Fatalf("%v %v", v, ok)
+	}
+
+	tc.mu.RLock()
+	if tc.TrySet("b", 1, DefaultExpiration) {
+		t.Error("TrySet succeeded")
+	}
+	if tc.TryDelete("a") {
+		t.Error("TryDelete succeeded")
+	}
+	tc.mu.RUnlock()
+
+	if !tc.TryDelete("a") {
+		t.Fatal("TryDelete failed")
+	}
+	if n := tc.ItemCount(); n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,212 @@
+package zcache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLStats are usage statistics for a key or group of keys, which can be used
+// to tune the expiry times.
+//
+// If AvgUsed is much lower than AvgTTL then items are kept around much longer
+// than they're used, and the TTL can probably be lower. If AvgGap is very low
+// then the items are set again right after they expired, and a higher TTL may
+// be better.
+type TTLStats struct {
+	Items   int           // Number of items that were deleted, expired, or replaced.
+	Unused  int           // Number of items that were never retrieved.
+	AvgTTL  time.Duration // Average TTL the items were set with; 0 if they never expire.
+	AvgUsed time.Duration // Average time between the item being set and the last Get().
+	AvgGap  time.Duration // Average time between the item expiring and the key being set again.
+
+	gaps int
+}
+
+// merge adds the statistics in o to s.
+func (s TTLStats) merge(o TTLStats) TTLStats {
+	wavg := func(a time.Duration, n int, b time.Duration, m int) time.Duration {
+		if n+m == 0 {
+			return 0
+		}
+		return (a*time.Duration(n) + b*time.Duration(m)) / time.Duration(n+m)
+	}
+	return TTLStats{
+		Items:   s.Items + o.Items,
+		Unused:  s.Unused + o.Unused,
+		AvgTTL:  wavg(s.AvgTTL, s.Items, o.AvgTTL, o.Items),
+		AvgUsed: wavg(s.AvgUsed, s.Items, o.AvgUsed, o.Items),
+		AvgGap:  wavg(s.AvgGap, s.gaps, o.AvgGap, o.gaps),
+		gaps:    s.gaps + o.gaps,
+	}
+}
+
+type ttlLive struct {
+	set, lastGet, expires time.Time
+	ttl                   time.Duration
+}
+
+// maxTTLStats is the maximum number of keys or groups to keep statistics for,
+// and the maximum number of expired keys to remember for AvgGap.
+const maxTTLStats = 10_000
+
+type ttlStats[K comparable] struct {
+	mu      sync.Mutex
+	group   func(K) K
+	live    map[K]ttlLive
+	expired map[K]time.Time
+	stats   map[K]TTLStats
+}
+
+func newTTLStats[K comparable](group func(K) K) *ttlStats[K] {
+	return &ttlStats[K]{
+		group:   group,
+		live:    make(map[K]ttlLive),
+		expired: make(map[K]time.Time),
+		stats:   make(map[K]TTLStats),
+	}
+}
+
+// key gets the key in the stats for k, and reports if there's room for it.
+func (s *ttlStats[K]) key(k K) (K, bool) {
+	if s.group != nil {
+		k = s.group(k)
+	}
+	if _, ok := s.stats[k]; ok {
+		return k, true
+	}
+	return k, len(s.stats) < maxTTLStats
+}
+
+// set records that k was set with the TTL d.
+func (s *ttlStats[K]) set(k K, d time.Duration) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.end(k, now)
+	if exp, ok := s.expired[k]; ok {
+		if g, ok := s.key(k); ok {
+			st := s.stats[g]
+			st.AvgGap = avg(st.AvgGap, st.gaps, now.Sub(exp))
+			st.gaps++
+			s.stats[g] = st
+		}
+		delete(s.expired, k)
+	}
+
+	l := ttlLive{set: now}
+	if d > 0 {
+		l.ttl, l.expires = d, now.Add(d)
+	}
+	s.live[k] = l
+}
+
+// get records that k was retrieved.
+func (s *ttlStats[K]) get(k K) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.live[k]; ok {
+		l.lastGet = now
+		s.live[k] = l
+	}
+}
+
+// remove records that k was removed from the cache.
+func (s *ttlStats[K]) remove(k K) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.end(k, now)
+}
+
+// removeAll records that all keys were removed from the cache.
+func (s *ttlStats[K]) removeAll() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.live {
+		s.end(k, now)
+	}
+}
+
+func (s *ttlStats[K]) end(k K, now time.Time) {
+	l, ok := s.live[k]
+	if !ok {
+		return
+	}
+	delete(s.live, k)
+
+	g, ok := s.key(k)
+	if !ok {
+		return
+	}
+	st := s.stats[g]
+	st.AvgTTL = avg(st.AvgTTL, st.Items, l.ttl)
+	if l.lastGet.IsZero() {
+		st.AvgUsed = avg(st.AvgUsed, st.Items, 0)
+		st.Unused++
+	} else {
+		st.AvgUsed = avg(st.AvgUsed, st.Items, l.lastGet.Sub(l.set))
+	}
+	st.Items++
+	s.stats[g] = st
+
+	if !l.expires.IsZero() && now.After(l.expires) && len(s.expired) < maxTTLStats {
+		s.expired[k] = l.expires
+	}
+}
+
+func (s *ttlStats[K]) copy() map[K]TTLStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := make(map[K]TTLStats, len(s.stats))
+	for k, v := range s.stats {
+		m[k] = v
+	}
+	return m
+}
+
+func avg(cur time.Duration, n int, add time.Duration) time.Duration {
+	return (cur*time.Duration(n) + add) / time.Duration(n+1)
+}
+
+// TrackTTL enables or disables tracking of usage statistics, which can be
+// retrieved with TTLStats().
+//
+// The statistics are per key, or per group if group is given; the group
+// function gets the group for a key, for example a prefix:
+//
+//	c.TrackTTL(true, func(k string) string {
+//		prefix, _, _ := strings.Cut(k, ":")
+//		return prefix
+//	})
+//
+// Statistics are kept for at most 10,000 keys or groups; keys after that are
+// not tracked.
+//
+// This adds some overhead to every operation, so it's disabled by default.
+// Calling this again or disabling it discards all statistics collected so far.
+func (c *cache[K, V]) TrackTTL(enable bool, group func(K) K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttlStats = nil
+	if enable {
+		c.ttlStats = newTTLStats(group)
+	}
+}
+
+// TTLStats gets the usage statistics for all keys or groups.
+//
+// Statistics are only collected after TrackTTL(true) is called, and are only
+// updated once an item is deleted, expired, or replaced; items that are still
+// in the cache are not included.
+func (c *cache[K, V]) TTLStats() map[K]TTLStats {
+	c.mu.RLock()
+	s := c.ttlStats
+	c.mu.RUnlock()
+	if s == nil {
+		return nil
+	}
+	return s.copy()
+}
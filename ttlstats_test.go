@@ -0,0 +1,91 @@
+package zcache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLStats(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	if tc.TTLStats() != nil {
+		t.Fatal("not nil")
+	}
+	tc.TrackTTL(true, nil)
+
+	tc.SetWithExpire("a", 1, 10*time.Millisecond)
+	tc.Set("b", 1)
+	time.Sleep(5 * time.Millisecond)
+	tc.Get("a")
+	tc.Delete("b")
+
+	if _, ok := tc.TTLStats()["a"]; ok {
+		t.Error("a is still in the cache and shouldn't be in the stats")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	tc.SetWithExpire("a", 2, 10*time.Millisecond)
+
+	stats := tc.TTLStats()
+	a, b := stats["a"], stats["b"]
+	if a.Items != 1 || a.Unused != 0 || a.AvgTTL != 10*time.Millisecond {
+		t.Errorf("a: %+v", a)
+	}
+	if a.AvgUsed < 5*time.Millisecond || a.AvgUsed > 10*time.Millisecond {
+		t.Errorf("a.AvgUsed: %s", a.AvgUsed)
+	}
+	if a.AvgGap <= 0 || a.AvgGap > 10*time.Millisecond {
+		t.Errorf("a.AvgGap: %s", a.AvgGap)
+	}
+	if b.Items != 1 || b.Unused != 1 || b.AvgTTL != 0 || b.AvgUsed != 0 || b.AvgGap != 0 {
+		t.Errorf("b: %+v", b)
+	}
+
+	tc.Reset()
+	if n := tc.TTLStats()["a"].Items; n != 2 {
+		t.Errorf("a.Items: %d", n)
+	}
+
+	tc.TrackTTL(false, nil)
+	if tc.TTLStats() != nil {
+		t.Fatal("not nil")
+	}
+}
+
+func TestTTLStatsGroup(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.TrackTTL(true, func(k string) string {
+		prefix, _, _ := strings.Cut(k, ":")
+		return prefix
+	})
+
+	tc.Set("user:1", 1)
+	tc.Set("user:2", 1)
+	tc.Set("post:1", 1)
+	tc.Reset()
+
+	stats := tc.TTLStats()
+	if len(stats) != 2 || stats["user"].Items != 2 || stats["post"].Items != 1 {
+		t.Errorf("%+v", stats)
+	}
+}
+
+func TestTTLStatsMax(t *testing.T) {
+	tc := New[int, int](NoExpiration, 0)
+	tc.TrackTTL(true, nil)
+	for i := 0; i < maxTTLStats+10; i++ {
+		tc.SetWithExpire(i, i, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+
+	if n := len(tc.TTLStats()); n != maxTTLStats {
+		t.Errorf("len: %d", n)
+	}
+	tc.cache.ttlStats.mu.Lock()
+	n := len(tc.cache.ttlStats.expired)
+	tc.cache.ttlStats.mu.Unlock()
+	if n != maxTTLStats {
+		t.Errorf("len(expired): %d", n)
+	}
+}
@@ -0,0 +1,128 @@
+package zcache
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+// Problem is an anomaly found by Validate().
+type Problem[K comparable] struct {
+	Key    K
+	Reason string
+}
+
+// Validate scans the cache for anomalies, such as items that expire absurdly
+// far in the future or that expired a long time ago, zero keys, and nil values.
+//
+// Expiration times are considered absurd if they're more than maxTTL in the
+// future or past; if maxTTL is 0 the default expiration is used, and if that's
+// NoExpiration the expiration times are only checked for negative values.
+//
+// This is intended as a sanity check after loading items with NewFrom() or
+// migrating from another cache; none of these things are errors as such. The
+// order of the returned problems is undefined.
+func (c *cache[K, V]) Validate(maxTTL time.Duration) []Problem[K] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if maxTTL == 0 {
+		maxTTL = c.defaultExpiration
+	}
+	var (
+		zero  K
+		now   = c.nanotime()
+		probs []Problem[K]
+	)
+	for k, item := range c.items {
+		if k == zero {
+			probs = append(probs, Problem[K]{Key: k, Reason: "zero key"})
+		}
+		if isNil(item.Object) {
+			probs = append(probs, Problem[K]{Key: k, Reason: "nil value"})
+		}
+		switch {
+		case item.Expiration < 0:
+			probs = append(probs, Problem[K]{Key: k, Reason: "negative expiration"})
+		case item.Expiration > 0 && maxTTL > 0 && item.Expiration > now+int64(maxTTL):
+			probs = append(probs, Problem[K]{Key: k, Reason: "expires more than " + maxTTL.String() + " in the future"})
+		case item.Expiration > 0 && maxTTL > 0 && item.Expiration < now-int64(maxTTL):
+			probs = append(probs, Problem[K]{Key: k, Reason: "expired more than " + maxTTL.String() + " ago"})
+		}
+	}
+	return probs
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// KeyValidator sets a function to validate keys when items are set, for
+// example to reject keys that are far too long.
+//
+// Items with invalid keys are not stored. Methods that return an error, such
+// as AddWithExpire(), return the error from the validator, and methods that
+// return a bool to indicate if the item was set return false. In strict mode
+// (see Strict()) it will panic instead.
+//
+// The validator is called with the lock held, after the key normalizer. Set to
+// nil to disable it (the default). See MaxKeyLength() and KeyRunes() for some
+// ready-made validators.
+func (c *cache[K, V]) KeyValidator(f func(K) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keyValidator = f
+}
+
+// checkKey validates the key with the KeyValidator(); the lock must be held.
+func (c *cache[K, V]) checkKey(op string, k K) error {
+	if c.keyValidator == nil {
+		return nil
+	}
+	err := c.keyValidator(k)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%s: invalid key: %w", op, err)
+	if c.strict {
+		panic(err)
+	}
+	return err
+}
+
+// MaxKeyLength returns a key validator that rejects keys longer than n bytes.
+func MaxKeyLength[K ~string](n int) func(K) error {
+	return func(k K) error {
+		if len(k) > n {
+			return fmt.Errorf("key is %d bytes; maximum is %d", len(k), n)
+		}
+		return nil
+	}
+}
+
+// KeyRunes returns a key validator that rejects keys containing characters for
+// which allow returns false, or invalid UTF-8. For example KeyRunes(unicode.IsPrint)
+// rejects keys with control characters.
+func KeyRunes[K ~string](allow func(rune) bool) func(K) error {
+	return func(k K) error {
+		for i, r := range string(k) {
+			if r == utf8.RuneError {
+				if _, size := utf8.DecodeRuneInString(string(k[i:])); size == 1 {
+					return fmt.Errorf("invalid UTF-8 at byte %d", i)
+				}
+			}
+			if !allow(r) {
+				return fmt.Errorf("character %q at byte %d not allowed", r, i)
+			}
+		}
+		return nil
+	}
+}
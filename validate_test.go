@@ -0,0 +1,98 @@
+package zcache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"unicode"
+)
+
+func TestValidate(t *testing.T) {
+	one := 1
+	now := time.Now()
+	tc := NewFrom[string, *int](time.Minute, 0, map[string]Item[*int]{
+		"":       {Object: &one},
+		"nil":    {Object: nil},
+		"future": {Object: &one, Expiration: now.Add(time.Hour).UnixNano()},
+		"past":   {Object: &one, Expiration: now.Add(-time.Hour).UnixNano()},
+		"neg":    {Object: &one, Expiration: -5},
+		"ok":     {Object: &one, Expiration: now.Add(time.Second).UnixNano()},
+		"forevr": {Object: &one},
+	})
+
+	probs := tc.Validate(0)
+	sort.Slice(probs, func(i, j int) bool { return probs[i].Key < probs[j].Key })
+	want := []Problem[string]{
+		{"", "zero key"},
+		{"future", "expires more than 1m0s in the future"},
+		{"neg", "negative expiration"},
+		{"nil", "nil value"},
+		{"past", "expired more than 1m0s ago"},
+	}
+	if !reflect.DeepEqual(probs, want) {
+		t.Errorf("\nhave: %v\nwant: %v", probs, want)
+	}
+
+	if probs := tc.Validate(2 * time.Hour); len(probs) != 3 {
+		t.Errorf("%v", probs)
+	}
+}
+
+func TestKeyValidator(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.KeyValidator(MaxKeyLength[string](3))
+
+	tc.Set("abc", 1)
+	tc.Set("abcd", 1)
+	tc.SetWithEvict("abcd", 1, DefaultExpiration, func(string, int) {})
+	tc.SetLazy("abcd", func() int { return 1 }, DefaultExpiration)
+	tc.Entry("abcd").Set(1)
+	if v := tc.GetOrAdd("abcd", 2); v != 2 {
+		t.Error(v)
+	}
+	if tc.Rename("abc", "abcd") {
+		t.Error("renamed")
+	}
+	if have := keys(tc); have != "[abc]" {
+		t.Error(have)
+	}
+	if len(tc.evictFuncs) > 0 || len(tc.lazy) > 0 {
+		t.Error("side data for invalid key")
+	}
+
+	err := tc.Add("abcd", 1)
+	if have, want := fmt.Sprint(err), "zcache.Add: invalid key: key is 4 bytes; maximum is 3"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+
+	tc.Strict(true)
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("didn't panic")
+			}
+		}()
+		tc.Set("abcd", 1)
+	}()
+}
+
+func TestKeyRunes(t *testing.T) {
+	f := KeyRunes[string](unicode.IsPrint)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "<nil>"},
+		{"héllo", "<nil>"},
+		{"\uFFFD", "<nil>"},
+		{"a\nb", `character '\n' at byte 1 not allowed`},
+		{"a\xffb", "invalid UTF-8 at byte 1"},
+	}
+	for _, tt := range tests {
+		if have := fmt.Sprint(f(tt.in)); have != tt.want {
+			t.Errorf("%q\nhave: %s\nwant: %s", tt.in, have, tt.want)
+		}
+	}
+}
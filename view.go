@@ -0,0 +1,78 @@
+package zcache
+
+import "sync"
+
+// ItemsView is a read-only view of the items in the cache, returned by
+// ItemsFrozen().
+//
+// The view reads the cache's map directly, without copying it. The cache is
+// read-locked until Close() is called: methods that modify the cache block
+// until then, and so do other readers if a writer is waiting. Don't call
+// methods on the cache from the goroutine holding the view, and call Close()
+// as soon as possible.
+type ItemsView[K comparable, V any] struct {
+	caches []*cache[K, V]
+	index  func(K) int // Shard for a key; nil if there is only one cache.
+	now    []int64
+	close  sync.Once
+}
+
+func newItemsView[K comparable, V any](index func(K) int, caches ...*cache[K, V]) *ItemsView[K, V] {
+	v := &ItemsView[K, V]{caches: caches, index: index, now: make([]int64, len(caches))}
+	for i, c := range caches {
+		c.mu.RLock()
+		v.now[i] = c.now()
+	}
+	return v
+}
+
+// Get an unexpired item.
+func (v *ItemsView[K, V]) Get(k K) (Item[V], bool) {
+	i := 0
+	if v.index != nil {
+		i = v.index(k)
+	}
+	c := v.caches[i]
+	item, ok := c.items[c.key(k)]
+	if !ok || (item.Expiration > 0 && v.now[i] > item.Expiration) {
+		return Item[V]{}, false
+	}
+	item.Object = c.read(item.Object)
+	return item, true
+}
+
+// Range calls f for all unexpired items, until f returns false.
+func (v *ItemsView[K, V]) Range(f func(K, Item[V]) bool) {
+	for i, c := range v.caches {
+		for k, item := range c.items {
+			if item.Expiration > 0 && v.now[i] > item.Expiration {
+				continue
+			}
+			item.Object = c.read(item.Object)
+			if !f(k, item) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of items.
+//
+// This may include items that have expired but have not yet been cleaned up,
+// like ItemCount().
+func (v *ItemsView[K, V]) Len() int {
+	n := 0
+	for _, c := range v.caches {
+		n += len(c.items)
+	}
+	return n
+}
+
+// Close the view, unlocking the cache. The view can't be used afterwards.
+func (v *ItemsView[K, V]) Close() {
+	v.close.Do(func() {
+		for _, c := range v.caches {
+			c.mu.RUnlock()
+		}
+	})
+}
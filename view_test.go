@@ -0,0 +1,86 @@
+package zcache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestItemsFrozen(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.SetWithExpire("expired", 3, time.Nanosecond)
+	tc.ReadPipeline(func(v int) int { return v * 10 })
+	time.Sleep(time.Millisecond)
+
+	v := tc.ItemsFrozen()
+	if it, ok := v.Get("a"); !ok || it.Object != 10 {
+		t.Error(it, ok)
+	}
+	if _, ok := v.Get("expired"); ok {
+		t.Error("expired")
+	}
+	if _, ok := v.Get("x"); ok {
+		t.Error("x")
+	}
+	if n := v.Len(); n != 3 {
+		t.Error(n)
+	}
+
+	var have []string
+	v.Range(func(k string, it Item[int]) bool {
+		have = append(have, fmt.Sprintf("%s=%d", k, it.Object))
+		return true
+	})
+	sort.Strings(have)
+	if h := strings.Join(have, " "); h != "a=10 b=20" {
+		t.Error(h)
+	}
+
+	// Writes block until the view is closed.
+	done := make(chan struct{})
+	go func() {
+		tc.Set("c", 3)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("write not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	v.Close()
+	v.Close()
+	<-done
+	if _, ok := tc.Get("c"); !ok {
+		t.Error("c")
+	}
+}
+
+func TestItemsFrozenSharded(t *testing.T) {
+	s := NewSharded[string, int](4, NoExpiration, 0, nil)
+	for i := 0; i < 20; i++ {
+		s.Set(fmt.Sprint(i), i)
+	}
+
+	v := s.ItemsFrozen()
+	defer v.Close()
+	if n := v.Len(); n != 20 {
+		t.Error(n)
+	}
+	for i := 0; i < 20; i++ {
+		if it, ok := v.Get(fmt.Sprint(i)); !ok || it.Object != i {
+			t.Error(i, it, ok)
+		}
+	}
+	n := 0
+	v.Range(func(k string, it Item[int]) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Error(n)
+	}
+}
@@ -0,0 +1,45 @@
+package zcache
+
+// watermark calls a function when the cache fills up to a fraction of the
+// capacity set with MaxItems() or MaxCost().
+type watermark struct {
+	fraction float64
+	f        func(count, capacity int)
+	above    bool // Already called; reset once it drops below the fraction.
+}
+
+// fill gets the fill level that's over the watermark, if any; the lock must be
+// held.
+func (c *cache[K, V]) fill() (count, capacity int, over bool) {
+	w := c.watermark
+	if c.maxItems > 0 && float64(len(c.items)) >= w.fraction*float64(c.maxItems) {
+		return len(c.items), c.maxItems, true
+	}
+	if c.maxCost > 0 && float64(c.cost) >= w.fraction*float64(c.maxCost) {
+		return int(c.cost), int(c.maxCost), true
+	}
+	return 0, 0, false
+}
+
+// rearmWatermark resets the watermark if the cache dropped below it since the
+// last write; the lock must be held.
+func (c *cache[K, V]) rearmWatermark() {
+	if c.watermark != nil && c.watermark.above {
+		if _, _, over := c.fill(); !over {
+			c.watermark.above = false
+		}
+	}
+}
+
+// checkWatermark schedules the watermark function if the cache went over the
+// watermark; it's run by unlock(). The lock must be held.
+func (c *cache[K, V]) checkWatermark() {
+	w := c.watermark
+	if w == nil || w.above {
+		return
+	}
+	if count, capacity, over := c.fill(); over {
+		w.above = true
+		c.watermarkFire = func() { w.f(count, capacity) }
+	}
+}
@@ -12,9 +12,13 @@
 package zcache
 
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"runtime/pprof"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,6 +30,11 @@ const (
 	// Equivalent to passing in the same expiration duration as was given to
 	// New() or NewFrom() when the cache was created (e.g. 5 minutes.)
 	DefaultExpiration time.Duration = 0
+
+	// DontCache indicates a value returned from a loader function should not be
+	// stored in the cache. This is only valid as the return value of the
+	// GetOrSet() loader.
+	DontCache time.Duration = -2
 )
 
 type (
@@ -37,19 +46,75 @@ type (
 	cache[K comparable, V any] struct {
 		defaultExpiration time.Duration
 		items             map[K]Item[V]
+		clock             atomic.Value // *coarseClock
+		expiring          int          // Number of items with an expiration.
 		mu                sync.RWMutex
-		onEvicted         func(K, V)
+		onEvicted         func(K, V) // OnEvicted() and all handlers.
+		onEvictedFunc     func(K, V)
+		onEvictedBatch    func(map[K]V)
+		evictHandlers     []*evictHandler[K, V]
+		evictFuncs        map[K]func(K, V)
 		janitor           *janitor[K, V]
+		ttlStats          *ttlStats[K]
+		lfu               *lfu[K]
+		keyStats          *keyStats[K]
+		lru               *lru[K]
+		maxItems          int
+		maxCost           int64
+		cost              int64
+		costs             map[K]int64
+		weigher           func(K, V) int64
+		overflow          []keyAndValue[K, V]
+		frozen            bool
+		frozenWrites      uint64 // Atomic, as it's updated with the read lock.
+		autoTTL           *autoTTL
+		expireOnAccess    bool
+		flights           group[K, V]
+		revalidations     group[K, bool]
+		revalidating      map[K]bool // Keys being revalidated; true if written since.
+		onExpiring        func(K, V)
+		expiringLead      time.Duration
+		expiringSeen      map[K]int64
+		uses              map[K]int
+		errs              map[K]error
+		lazy              map[K]*lazyValue[V]
+		filter            *missFilter[K]
+		filterSize        int
+		filterView        atomic.Value // *filterView
+		pins              map[K]*pin[K]
+		notifier          *expiryNotifier[K]
+		history           *history[K]
+		defaultValue      V
+		locks             map[K]keyLock
+		normalize         func(K) K
+		readPipeline      []func(V) V
+		evictOrder        func(a, b K) bool
+		maxEvictions      int // Per janitor run; 0 is unlimited.
+		watermark         *watermark
+		watermarkFire     func()
+		strict            bool
+		name              string
+		keyFormatter      func(K) string
+		keyValidator      func(K) error
 	}
 
 	// Item stored in the cache; it holds the value and the expiration time as
 	// timestamp.
+	//
+	// The expiration is in nanoseconds since the Unix epoch, but is calculated
+	// from the monotonic clock and isn't affected by changes to the system
+	// time after the process started; see nanotime().
 	Item[V any] struct {
 		Object     V
 		Expiration int64
 	}
 )
 
+// Expired reports if this item has expired.
+func (item Item[V]) Expired() bool {
+	return item.Expiration > 0 && nanotime() > item.Expiration
+}
+
 // New creates a new cache with a given expiration duration and cleanup
 // interval.
 //
@@ -91,6 +156,11 @@ func newCache[K comparable, V any](de time.Duration, m map[K]Item[V]) *cache[K,
 		defaultExpiration: de,
 		items:             m,
 	}
+	for _, v := range m {
+		if v.Expiration > 0 {
+			c.expiring++
+		}
+	}
 	return c
 }
 
@@ -100,11 +170,14 @@ func newCacheWithJanitor[K comparable, V any](de time.Duration, ci time.Duration
 	// DeleteExpired on c forever) does not keep the returned C object from
 	// being garbage collected. When it is garbage collected, the finalizer
 	// stops the janitor goroutine, after which c can be collected.
+	//
+	// The finalizer is always set, as CoarseTime(), KeepHistory(), and
+	// ExpiryNotifications() also start goroutines.
 	C := &Cache[K, V]{c}
 	if ci > 0 {
 		runJanitor(c, ci)
-		runtime.SetFinalizer(C, stopJanitor[K, V])
 	}
+	runtime.SetFinalizer(C, stopJanitor[K, V])
 	return C
 }
 
@@ -133,339 +206,2160 @@ func (c *cache[K, V]) Replace(k K, v V) error { return c.ReplaceWithExpire(k, v,
 // If the duration is 0 (DefaultExpiration), the cache's default expiration time
 // is used. If it is -1 (NoExpiration), the item never expires.
 func (c *cache[K, V]) SetWithExpire(k K, v V, d time.Duration) {
-	// "Inlining" of set
-	var e int64
-	if d == DefaultExpiration {
-		d = c.defaultExpiration
-	}
-	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
-	}
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items[k] = Item[V]{
-		Object:     v,
-		Expiration: e,
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return
 	}
+	if c.checkKey("zcache.Set", k) != nil {
+		return
+	}
+	c.set(k, v, d)
 }
 
-// TouchWithExpire replaces the expiry of a key and returns the current value, if any.
+// SetKeepTTL replaces the value of an existing item, keeping the expiration
+// time.
 //
-// The boolean return value indicates if this item was set. If the duration is 0
-// (DefaultExpiration), the cache's default expiration time is used. If it is -1
-// (NoExpiration), the item never expires.
-func (c *cache[K, V]) TouchWithExpire(k K, d time.Duration) (V, bool) {
-	if d == DefaultExpiration {
-		d = c.defaultExpiration
-	}
-
+// If the item doesn't exist or has expired it's set with the default
+// expiration, and false is returned. As with Set(), any callback set with
+// SetWithEvict() or max uses set with SetWithMaxUses() are discarded.
+func (c *cache[K, V]) SetKeepTTL(k K, v V) bool {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return false
+	}
+	if c.checkKey("zcache.Set", k) != nil {
+		return false
+	}
 
 	item, ok := c.items[k]
-	if !ok {
-		return c.zero(), false
+	if !ok || (item.Expiration > 0 && c.nanotime() > item.Expiration) {
+		c.set(k, v, DefaultExpiration)
+		return false
 	}
-
-	item.Expiration = time.Now().Add(d).UnixNano()
+	if item.Expiration <= 0 {
+		c.set(k, v, NoExpiration)
+		return true
+	}
+	// Use the remaining time for the TTL statistics, but make sure the
+	// expiration is exactly the same.
+	rem := time.Duration(item.Expiration - c.nanotime())
+	if rem < 1 {
+		rem = 1
+	}
+	c.set(k, v, rem)
+	item.Object = v
 	c.items[k] = item
-	return item.Object, true
+	return true
 }
 
-// AddWithExpire adds an item to the cache only if it doesn't exist yet, or if
-// it has expired.
+// SetWithEvict sets a cache item with a callback to run when it's evicted,
+// replacing any existing item.
 //
-// It will return an error if the cache key already exists. If the duration is 0
-// (DefaultExpiration), the cache's default expiration time is used. If it is -1
-// (NoExpiration), the item never expires.
-func (c *cache[K, V]) AddWithExpire(k K, v V, d time.Duration) error {
+// The callback takes precedence over the callback set with OnEvicted() and is
+// discarded if the item is replaced. The duration is used as with
+// SetWithExpire().
+func (c *cache[K, V]) SetWithEvict(k K, v V, d time.Duration, onEvict func(K, V)) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	_, ok := c.get(k)
-	if ok {
-		return fmt.Errorf("zcache.Add: item %v already exists", k)
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return
+	}
+	if c.checkKey("zcache.Set", k) != nil {
+		return
 	}
 	c.set(k, v, d)
-	return nil
+	if onEvict != nil {
+		if c.evictFuncs == nil {
+			c.evictFuncs = make(map[K]func(K, V))
+		}
+		c.evictFuncs[k] = onEvict
+	}
 }
 
-// ReplaceWithExpire sets a new value for the key only if it already exists and isn't
-// expired.
+// SetWithMaxUses sets a cache item which is deleted after it's been retrieved n
+// times, replacing any existing item.
 //
-// It will return an error if the cache key doesn't exist. If the duration is 0
-// (DefaultExpiration), the cache's default expiration time is used. If it is -1
-// (NoExpiration), the item never expires.
-func (c *cache[K, V]) ReplaceWithExpire(k K, v V, d time.Duration) error {
+// Only Get() and GetWithExpire() count as a use. For example with n=1 the item
+// can be retrieved only once, which is useful for one-time tokens. The use
+// count is decremented and the item deleted atomically, so an item is never
+// returned more than n times even if retrieved concurrently.
+//
+// The duration is used as with SetWithExpire(); the item may expire before
+// it's been retrieved n times. The item is stored normally if n < 1.
+func (c *cache[K, V]) SetWithMaxUses(k K, v V, d time.Duration, n int) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	_, ok := c.get(k)
-	if !ok {
-		return fmt.Errorf("zcache.Replace: item %v doesn't exist", k)
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return
+	}
+	if c.checkKey("zcache.Set", k) != nil {
+		return
 	}
 	c.set(k, v, d)
-	return nil
+	if n > 0 {
+		if c.uses == nil {
+			c.uses = make(map[K]int)
+		}
+		c.uses[k] = n
+	}
 }
 
-// Get an item from the cache.
+// SetErr stores an error for the key, replacing any existing item.
 //
-// Returns the item or the zero value and a bool indicating whether the key is
-// set.
-func (c *cache[K, V]) Get(k K) (V, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// "Inlining" of get and Expired
-	item, ok := c.items[k]
-	if !ok {
-		return c.zero(), false
+// This can be used to cache errors (e.g. "not found") with their own
+// expiration, without having to wrap every value in a struct. The error is
+// retrieved with GetResult(); other methods such as Get() will see the zero
+// value for V. The duration is used as with SetWithExpire().
+func (c *cache[K, V]) SetErr(k K, err error, d time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return
 	}
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
-		return c.zero(), false
+	if c.checkKey("zcache.Set", k) != nil {
+		return
+	}
+	c.set(k, c.zero(), d)
+	if err != nil {
+		if c.errs == nil {
+			c.errs = make(map[K]error)
+		}
+		c.errs[k] = err
 	}
-	return item.Object, true
 }
 
-// GetStale gets an item from the cache without checking if it's expired.
+// SetLazy sets a cache item of which the value is created by f on first access,
+// replacing any existing item.
 //
-// Returns the item or the zero value and a bool indicating whether the key was
-// expired and a bool indicating whether the key was set.
-func (c *cache[K, V]) GetStale(k K) (v V, expired bool, ok bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// "Inlining" of get and Expired
-	item, ok := c.items[k]
-	if !ok {
-		return c.zero(), false, false
+// This allows cheaply setting many items which may never be used. The function
+// is run once, even if the item is retrieved concurrently; the item is then
+// replaced with the value. Methods that get or modify the value of a key, such
+// as Get(), GetStale(), Pop(), and Modify(), run the function; it's run without
+// the cache locked, so it can use the cache. Methods that return many items,
+// such as Items(), will see the zero value for V until then.
+//
+// The duration is used as with SetWithExpire(), and starts counting when
+// SetLazy() is called.
+func (c *cache[K, V]) SetLazy(k K, f func() V, d time.Duration) {
+	c.mu.Lock()
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return
+	}
+	if c.checkKey("zcache.Set", k) != nil {
+		return
 	}
-	return item.Object,
-		item.Expiration > 0 && time.Now().UnixNano() > item.Expiration,
-		true
+	c.set(k, c.zero(), d)
+	if c.lazy == nil {
+		c.lazy = make(map[K]*lazyValue[V])
+	}
+	c.lazy[k] = &lazyValue[V]{f: f}
 }
 
-// GetWithExpire returns an item and its expiration time from the cache.
+// SetUntil sets a cache item which is deleted when the done channel is closed,
+// replacing any existing item.
 //
-// It returns the item or the zero value, the expiration time if one is set (if
-// the item never expires a zero value for time.Time is returned), and a bool
-// indicating whether the key was set.
-func (c *cache[K, V]) GetWithExpire(k K) (V, time.Time, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	// "Inlining" of get and Expired
-	item, ok := c.items[k]
-	if !ok {
-		return c.zero(), time.Time{}, false
+// This can be used to keep an item for as long as some other operation is
+// running, for example a job or request. The duration is used as with
+// SetWithExpire(), and can be used to set a maximum lifetime; use NoExpiration
+// to keep the item until done is closed.
+//
+// The item is deleted with Delete() when done is closed, unless it was already
+// replaced or deleted.
+func (c *cache[K, V]) SetUntil(k K, v V, done <-chan struct{}, d time.Duration) {
+	if p := c.setPinned(k, v, done, d); p != nil {
+		go c.watch(p)
 	}
+}
 
-	if item.Expiration > 0 {
-		if time.Now().UnixNano() > item.Expiration {
-			return c.zero(), time.Time{}, false
+// watch deletes the item for the pin when its done channel is closed.
+func (c *cache[K, V]) watch(p *pin[K]) {
+	select {
+	case <-p.stop:
+	case <-p.done:
+		c.mu.Lock()
+		if c.pins[p.k] != p { // Replaced or deleted.
+			c.mu.Unlock()
+			return
+		}
+		k := p.k
+		v, onEvict := c.delete(k)
+		c.mu.Unlock()
+		if onEvict != nil {
+			onEvict(k, v)
 		}
-
-		// Return the item and the expiration time
-		return item.Object, time.Unix(0, item.Expiration), true
 	}
-
-	// If expiration <= 0 (i.e. no expiration time set) then return the item
-	// and a zeroed time.Time
-	return item.Object, time.Time{}, true
 }
 
-// Modify the value of an existing key.
-//
-// This is thread-safe; for example to increment a number:
-//
-//	cache.Modify("one", func(v int) int { return v + 1 })
-//
-// Or setting a map key:
-//
-//	cache.Modify("key", func(v map[string]string) map[string]string {
-//	      v["k"] = "v"
-//	      return v
-//	})
-//
-// This is thread-safe and can be safely run by multiple goroutines modifying
-// the same key. If you would use Get() + Set() then two goroutines may Get()
-// the same value and the modification of one of them will be lost.
-//
-// This is not run for keys that are not set yet; the boolean return indicates
-// if the key was set and if the function was applied.
-func (c *cache[K, V]) Modify(k K, f func(V) V) (V, bool) {
+// setPinned sets an item for SetUntil() and pins it; it returns nil if the item
+// wasn't set.
+func (c *cache[K, V]) setPinned(k K, v V, done <-chan struct{}, d time.Duration) *pin[K] {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// "Inlining" of get and Expired
-	item, ok := c.items[k]
-	if !ok {
-		return c.zero(), false
+	defer c.unlock()
+	if c.rejectWrite() {
+		return nil
 	}
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
-		return c.zero(), false
+	k = c.key(k)
+	if c.checkKey("zcache.Set", k) != nil {
+		return nil
 	}
+	c.set(k, v, d)
+	p := &pin[K]{k: k, stop: make(chan struct{}), done: done}
+	if c.pins == nil {
+		c.pins = make(map[K]*pin[K])
+	}
+	c.pins[k] = p
+	return p
+}
 
-	item.Object = f(item.Object)
-	c.items[k] = item
-	return item.Object, true
+// pin is an item set with SetUntil().
+type pin[K comparable] struct {
+	k    K // Protected by cache.mu; the key changes on Rename().
+	stop chan struct{}
+	done <-chan struct{}
 }
 
-// Delete an item from the cache. Does nothing if the key is not in the cache.
-func (c *cache[K, V]) Delete(k K) {
-	c.mu.Lock()
-	v, evicted := c.delete(k)
-	c.mu.Unlock()
-	if evicted {
-		c.onEvicted(k, v)
+// unpin stops waiting for the done channel of an item set with SetUntil();
+// the lock must be held.
+func (c *cache[K, V]) unpin(k K) {
+	if p, ok := c.pins[k]; ok {
+		close(p.stop)
+		delete(c.pins, k)
 	}
 }
 
-// Rename a key; the value and expiry will be left untouched; onEvicted will not
-// be called.
-//
-// Existing keys will be overwritten; returns false is the src key doesn't
-// exist.
-func (c *cache[K, V]) Rename(src, dst K) bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// "Inlining" of get and Expired
-	item, ok := c.items[src]
-	if !ok {
-		return false
-	}
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
-		return false
+// unpinAll calls unpin() for all keys; the lock must be held.
+func (c *cache[K, V]) unpinAll() {
+	for _, p := range c.pins {
+		close(p.stop)
 	}
+	c.pins = nil
+}
 
-	delete(c.items, src)
-	c.items[dst] = item
-	return true
+type lazyValue[V any] struct {
+	once sync.Once
+	f    func() V
+	v    V
 }
 
-// Pop gets an item from the cache and deletes it.
-//
-// The bool return indicates if the item was set.
-func (c *cache[K, V]) Pop(k K) (V, bool) {
-	c.mu.Lock()
+// value runs the function once and returns the value.
+func (l *lazyValue[V]) value() V {
+	l.once.Do(func() {
+		l.v = l.f()
+		l.f = nil
+	})
+	return l.v
+}
 
-	// "Inlining" of get and Expired
-	item, ok := c.items[k]
-	if !ok {
-		c.mu.Unlock()
-		return c.zero(), false
-	}
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
-		c.mu.Unlock()
-		return c.zero(), false
+// install replaces the item for a key set with SetLazy() with the value, if it
+// wasn't set or deleted in the meanwhile; the lock must be held and the
+// function must have run.
+func (c *cache[K, V]) install(k K, l *lazyValue[V]) {
+	if c.lazy[k] != l {
+		return
 	}
+	item := c.items[k]
+	item.Object = l.v
+	c.items[k] = item
+	delete(c.lazy, k)
+	c.weigh(k, l.v)
+}
 
-	v, evicted := c.delete(k)
-	c.mu.Unlock()
-	if evicted {
-		c.onEvicted(k, v)
-	}
+// resolve gets the value for an item set with SetLazy(), replacing the item in
+// the cache with the value.
+func (c *cache[K, V]) resolve(k K, l *lazyValue[V]) V {
+	l.value()
 
-	return item.Object, true
+	c.mu.Lock()
+	defer c.unlock()
+	c.install(k, l)
+	return c.read(l.v)
 }
 
-// DeleteExpired deletes all expired items from the cache.
-func (c *cache[K, V]) DeleteExpired() {
-	var evictedItems []keyAndValue[K, V]
-	now := time.Now().UnixNano()
+// lockKey acquires the write lock and normalizes the key, first running the
+// SetLazy() function for the key if there is one. The function is run without
+// the lock, so it can use the cache.
+func (c *cache[K, V]) lockKey(k K) K {
 	c.mu.Lock()
+	k = c.key(k)
+	c.resolveLocked(k)
+	return k
+}
 
-	for k, v := range c.items {
-		// "Inlining" of expired
-		if v.Expiration > 0 && now > v.Expiration {
-			ov, evicted := c.delete(k)
-			if evicted {
-				evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov})
-			}
+// resolveLocked runs the SetLazy() function for the key, if there is one; the
+// lock must be held, and is released while the function runs.
+func (c *cache[K, V]) resolveLocked(k K) {
+	for {
+		l, ok := c.lazy[k]
+		if !ok {
+			return
 		}
-	}
-	c.mu.Unlock()
-	for _, v := range evictedItems {
-		c.onEvicted(v.key, v.value)
+		c.mu.Unlock()
+		l.value()
+		c.mu.Lock()
+		c.install(k, l)
 	}
 }
 
-// OnEvicted sets an function to call when an item is evicted from the cache.
-//
-// The function is run with the key and value. This is also run when a cache
-// item is is deleted manually, but *not* when it is overwritten.
-//
-// Can be set to nil to disable it (the default).
-func (c *cache[K, V]) OnEvicted(f func(K, V)) {
+// lockKeys is like lockKey(), for many keys.
+func (c *cache[K, V]) lockKeys(keys []K) []K {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.onEvicted = f
-}
-
-// Items returns a copy of all unexpired items in the cache.
-func (c *cache[K, V]) Items() map[K]Item[V] {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	m := make(map[K]Item[V], len(c.items))
-	now := time.Now().UnixNano()
-	for k, v := range c.items {
-		// "Inlining" of Expired
-		if v.Expiration > 0 && now > v.Expiration {
-			continue
+	if c.normalize != nil {
+		norm := make([]K, len(keys))
+		for i, k := range keys {
+			norm[i] = c.key(k)
 		}
-		m[k] = v
+		keys = norm
 	}
-	return m
+	for len(c.lazy) > 0 {
+		var (
+			k K
+			l *lazyValue[V]
+		)
+		for _, kk := range keys {
+			if ll, ok := c.lazy[kk]; ok {
+				k, l = kk, ll
+				break
+			}
+		}
+		if l == nil {
+			break
+		}
+		c.mu.Unlock()
+		l.value()
+		c.mu.Lock()
+		c.install(k, l)
+	}
+	return keys
 }
 
-// Keys gets a list of all keys, in no particular order.
-func (c *cache[K, V]) Keys() []K {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// use retrieves an item set with SetWithMaxUses(), decrementing the uses and
+// deleting it if this was the last use.
+func (c *cache[K, V]) use(k K) (Item[V], bool) {
+	c.mu.Lock()
+	item, ok := c.items[k]
+	if !ok || (item.Expiration > 0 && c.nanotime() > item.Expiration) {
+		dv := c.defaultValue
+		c.mu.Unlock()
+		return Item[V]{Object: dv}, false
+	}
+	if c.ttlStats != nil {
+		c.ttlStats.get(k)
+	}
+	if c.lfu != nil {
+		c.lfu.hit(k)
+	}
+	if c.lru != nil {
+		c.lru.get(k)
+	}
 
-	keys := make([]K, 0, len(c.items))
-	now := time.Now().UnixNano()
-	for k, v := range c.items {
-		// "Inlining" of Expired
-		if v.Expiration > 0 && now > v.Expiration {
-			continue
+	var (
+		v       V
+		onEvict func(K, V)
+	)
+	if n, ok := c.uses[k]; ok {
+		if n <= 1 {
+			v, onEvict = c.delete(k)
+		} else {
+			c.uses[k] = n - 1
 		}
-		keys = append(keys, k)
 	}
-	return keys
+	item.Object = c.read(item.Object)
+	c.mu.Unlock()
+	if onEvict != nil {
+		onEvict(k, v)
+	}
+	return item, true
 }
 
-// ItemCount returns the number of items in the cache.
+// TouchWithExpire replaces the expiry of a key and returns the current value, if any.
 //
-// This may include items that have expired but have not yet been cleaned up.
-func (c *cache[K, V]) ItemCount() int {
+// The boolean return value indicates if this item was set. If the duration is 0
+// (DefaultExpiration), the cache's default expiration time is used. If it is -1
+// (NoExpiration), the item never expires. Other negative durations are treated
+// as NoExpiration, or panic in strict mode.
+func (c *cache[K, V]) TouchWithExpire(k K, d time.Duration) (V, bool) {
+	k = c.lockKey(k)
+	defer c.unlock()
+	if c.rejectWrite() {
+		return c.zero(), false
+	}
+
+	c.checkDuration("zcache.Touch", d)
+	if d == DefaultExpiration {
+		d = c.defaultTTL()
+	}
+
+	item, ok := c.items[k]
+	if !ok {
+		return c.zero(), false
+	}
+
+	var e int64
+	if d > 0 {
+		e = c.nanotime() + int64(d)
+	}
+	c.trackExpiring(item.Expiration, e)
+	item.Expiration = e
+	c.items[k] = item
+	return c.read(item.Object), true
+}
+
+// Revalidate checks if an item is still valid, and either extends the
+// expiration or deletes it.
+//
+// The check function is called with the current value, which may be expired.
+// If it returns true the expiration is set to the returned duration without
+// changing the value; DefaultExpiration and NoExpiration can be used as with
+// SetWithExpire(). If it returns false the item is deleted.
+//
+// This is useful to do a cheap check if an item is still valid against
+// upstream, such as an HTTP request with If-None-Match.
+//
+// The check isn't run with the cache locked, so it can be slow. If the item was
+// set, modified, or deleted while the check was running the result is
+// discarded and the item is left alone. Only one check runs for a key at a
+// time; concurrent calls for the same key wait for it and get the same result.
+//
+// The return value reports if the item was kept with a new expiration; it's
+// false if the key wasn't set.
+func (c *cache[K, V]) Revalidate(k K, check func(V) (stillValid bool, newTTL time.Duration)) bool {
+	k = c.normalizeKey(k)
+	kept, _ := c.revalidations.do(k, func() (bool, error) {
+		return c.revalidate(k, check), nil
+	})
+	return kept
+}
+
+func (c *cache[K, V]) revalidate(k K, check func(V) (stillValid bool, newTTL time.Duration)) bool {
+	c.mu.Lock()
+	c.resolveLocked(k)
+	if c.rejectWrite() {
+		c.unlock()
+		return false
+	}
+	item, ok := c.items[k]
+	if !ok {
+		c.unlock()
+		return false
+	}
+	item.Object = c.read(item.Object)
+	if c.revalidating == nil {
+		c.revalidating = make(map[K]bool)
+	}
+	c.revalidating[k] = false
+	c.unlock()
+
+	valid, d := check(item.Object)
+
+	c.mu.Lock()
+	written := c.revalidating[k]
+	delete(c.revalidating, k)
+	cur, ok := c.items[k]
+	if written || !ok {
+		c.mu.Unlock()
+		return false
+	}
+	if !valid {
+		v, onEvict := c.delete(k)
+		c.mu.Unlock()
+		if onEvict != nil {
+			onEvict(k, v)
+		}
+		return false
+	}
+	defer c.mu.Unlock()
+
+	c.checkDuration("zcache.Revalidate", d)
+	if d == DefaultExpiration {
+		d = c.defaultTTL()
+	}
+	var e int64
+	if d > 0 {
+		e = c.nanotime() + int64(d)
+	}
+	c.trackExpiring(cur.Expiration, e)
+	cur.Expiration = e
+	c.items[k] = cur
+	return true
+}
+
+// written records that the item for the key was set, modified, or deleted, for
+// Revalidate(); the lock must be held.
+func (c *cache[K, V]) written(k K) {
+	if len(c.revalidating) == 0 {
+		return
+	}
+	if _, ok := c.revalidating[k]; ok {
+		c.revalidating[k] = true
+	}
+}
+
+// writtenAll is like written(), for all keys.
+func (c *cache[K, V]) writtenAll() {
+	for k := range c.revalidating {
+		c.revalidating[k] = true
+	}
+}
+
+// ExpireMany replaces the expiry of all the given keys, with a single lock.
+//
+// This is like calling TouchWithExpire() for every key. Keys that aren't set are
+// ignored; it returns the number of items that were updated.
+func (c *cache[K, V]) ExpireMany(keys []K, d time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejectWrite() {
+		return 0
+	}
+
+	c.checkDuration("zcache.ExpireMany", d)
+	if d == DefaultExpiration {
+		d = c.defaultTTL()
+	}
+
+	var e int64
+	if d > 0 {
+		e = c.nanotime() + int64(d)
+	}
+	var n int
+	for _, k := range keys {
+		k = c.key(k)
+		item, ok := c.items[k]
+		if !ok {
+			continue
+		}
+		c.trackExpiring(item.Expiration, e)
+		item.Expiration = e
+		c.items[k] = item
+		n++
+	}
+	return n
+}
+
+// AddWithExpire adds an item to the cache only if it doesn't exist yet, or if
+// it has expired.
+//
+// It will return an error if the cache key already exists. If the duration is 0
+// (DefaultExpiration), the cache's default expiration time is used. If it is -1
+// (NoExpiration), the item never expires. Other negative durations return an
+// error.
+func (c *cache[K, V]) AddWithExpire(k K, v V, d time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return ErrFrozen
+	}
+	if err := c.checkKey("zcache.Add", k); err != nil {
+		return err
+	}
+
+	if err := c.checkDuration("zcache.Add", d); err != nil {
+		return err
+	}
+	_, ok := c.get(k)
+	if ok {
+		return fmt.Errorf("zcache.Add: item %s already exists", c.formatKey(k))
+	}
+	c.set(k, v, d)
+	return nil
+}
+
+// AddGetExisting adds an item to the cache only if it doesn't exist yet, or if
+// it has expired.
+//
+// This is like AddWithExpire(), except that it returns the existing item if the
+// key already exists, so that there's no need for a separate Get() which may
+// race with the item expiring or being deleted. The boolean return indicates
+// if the item was added; the returned Item is the zero value if it was.
+//
+// It will return an error if the key or duration is invalid, or ErrFrozen if
+// the item doesn't exist and the cache is frozen; the item is never added if
+// there is an error.
+func (c *cache[K, V]) AddGetExisting(k K, v V, d time.Duration) (Item[V], bool, error) {
+	k = c.lockKey(k)
+	defer c.unlock()
+	if err := c.checkKey("zcache.AddGetExisting", k); err != nil {
+		return Item[V]{}, false, err
+	}
+	if err := c.checkDuration("zcache.AddGetExisting", d); err != nil {
+		return Item[V]{}, false, err
+	}
+
+	item, ok := c.items[k]
+	if ok && (item.Expiration <= 0 || c.nanotime() <= item.Expiration) {
+		item.Object = c.read(item.Object)
+		return item, false, nil
+	}
+	if c.rejectWrite() {
+		return Item[V]{}, false, ErrFrozen
+	}
+	c.set(k, v, d)
+	return Item[V]{}, true, nil
+}
+
+// GetOrAdd gets the value for the key, or sets it to v if the key doesn't exist
+// or has expired, using the default expiration.
+//
+// The lookup and set are done under a single lock, so only one value is ever
+// stored if this is called from multiple goroutines at the same time; all
+// callers get the same value back.
+func (c *cache[K, V]) GetOrAdd(k K, v V) V {
+	return c.GetOrAddWithExpire(k, v, DefaultExpiration)
+}
+
+// GetOrAddWithExpire is like GetOrAdd(), but with a custom expiration.
+func (c *cache[K, V]) GetOrAddWithExpire(k K, v V, d time.Duration) V {
+	k = c.lockKey(k)
+	defer c.unlock()
+
+	if have, ok := c.get(k); ok {
+		return c.read(have)
+	}
+	if !c.rejectWrite() && c.checkKey("zcache.Add", k) == nil {
+		c.set(k, v, d)
+	}
+	return c.read(v)
+}
+
+// ReplaceWithExpire sets a new value for the key only if it already exists and isn't
+// expired.
+//
+// It will return an error if the cache key doesn't exist. If the duration is 0
+// (DefaultExpiration), the cache's default expiration time is used. If it is -1
+// (NoExpiration), the item never expires. Other negative durations return an
+// error.
+func (c *cache[K, V]) ReplaceWithExpire(k K, v V, d time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	k = c.key(k)
+	if c.rejectWrite() {
+		return ErrFrozen
+	}
+
+	if err := c.checkDuration("zcache.Replace", d); err != nil {
+		return err
+	}
+	_, ok := c.get(k)
+	if !ok {
+		return fmt.Errorf("zcache.Replace: item %s doesn't exist", c.formatKey(k))
+	}
+	c.set(k, v, d)
+	return nil
+}
+
+// Get an item from the cache.
+//
+// Returns the item or the zero value and a bool indicating whether the key is
+// set.
+func (c *cache[K, V]) Get(k K) (V, bool) {
+	if fv, _ := c.filterView.Load().(*filterView[K, V]); fv != nil && !fv.f.has(k) {
+		return fv.dv, false
+	}
+	c.mu.RLock()
+	if s := c.keyStats; s != nil {
+		if nk := c.key(k); s.tracked(nk) {
+			start := time.Now()
+			v, ok := c.getRLocked(nk)
+			s.record(nk, ok, time.Since(start))
+			return v, ok
+		}
+	}
+	return c.getRLocked(k)
+}
+
+// getRLocked gets an item; the read lock must be held, and is released.
+func (c *cache[K, V]) getRLocked(k K) (V, bool) {
+	k = c.key(k)
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok {
+		if c.autoTTL != nil {
+			c.autoTTL.record(false)
+		}
+		dv := c.defaultValue
+		c.mu.RUnlock()
+		return dv, false
+	}
+	if item.Expiration > 0 && c.nanotime() > item.Expiration {
+		if c.autoTTL != nil {
+			c.autoTTL.record(false)
+		}
+		expire, dv := c.expireOnAccess, c.defaultValue
+		c.mu.RUnlock()
+		if expire {
+			c.expire(k)
+		}
+		return dv, false
+	}
+	if _, ok := c.uses[k]; ok {
+		c.mu.RUnlock()
+		item, ok := c.use(k)
+		return item.Object, ok
+	}
+	if c.ttlStats != nil {
+		c.ttlStats.get(k)
+	}
+	if c.lfu != nil {
+		c.lfu.hit(k)
+	}
+	if c.lru != nil {
+		c.lru.get(k)
+	}
+	if c.autoTTL != nil {
+		c.autoTTL.record(true)
+	}
+	if l, ok := c.lazy[k]; ok {
+		c.mu.RUnlock()
+		return c.resolve(k, l), true
+	}
+	v := c.read(item.Object)
+	c.mu.RUnlock()
+	return v, true
+}
+
+// GetResult gets an item from the cache, or the error stored with SetErr().
+//
+// The error is nil for items stored with any of the other Set methods. The bool
+// indicates whether the key is set, as with Get().
+func (c *cache[K, V]) GetResult(k K) (V, error, bool) {
+	c.mu.RLock()
+	err := c.errs[c.key(k)]
+	v, ok := c.getRLocked(k)
+	if !ok {
+		return v, nil, false
+	}
+	return v, err, true
+}
+
+// GetOrSet gets an item from the cache, or runs the loader function to get the
+// value and stores it if the key isn't set.
+//
+// The loader returns the value and the expiration to use for it, so the
+// expiration can be based on the value (e.g. the Cache-Control header of an
+// HTTP response). Use DefaultExpiration to use the cache's default expiration,
+// or DontCache to return the value without storing it (e.g. for error
+// responses or very large values).
+//
+// The loader is run only once if GetOrSet is called concurrently for the same
+// key; other calls will wait for the loader to finish and return the same value.
+// The cache isn't locked while the loader runs.
+func (c *cache[K, V]) GetOrSet(k K, f func() (V, time.Duration)) V {
+	k = c.normalizeKey(k)
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	v, _ := c.flights.do(k, func() (V, error) {
+		// May have been set while waiting for the flight.
+		if v, ok := c.Get(k); ok {
+			return v, nil
+		}
+		var (
+			v V
+			d time.Duration
+		)
+		c.withLabels(k, func() { v, d = f() })
+		if d != DontCache {
+			c.SetWithExpire(k, v, d)
+		}
+		return c.readValue(v), nil
+	})
+	return v
+}
+
+// GetOrSetErr is like GetOrSet(), but the loader can return an error.
+//
+// If the loader returns an error nothing is stored, and the error is returned
+// to the caller and all other callers that were waiting for the loader.
+func (c *cache[K, V]) GetOrSetErr(k K, f func() (V, time.Duration, error)) (V, error) {
+	k = c.normalizeKey(k)
+	if v, ok := c.Get(k); ok {
+		return v, nil
+	}
+	return c.flights.do(k, func() (V, error) {
+		// May have been set while waiting for the flight.
+		if v, ok := c.Get(k); ok {
+			return v, nil
+		}
+		var (
+			v   V
+			d   time.Duration
+			err error
+		)
+		c.withLabels(k, func() { v, d, err = f() })
+		if err != nil {
+			return c.zero(), err
+		}
+		if d != DontCache {
+			c.SetWithExpire(k, v, d)
+		}
+		return c.readValue(v), nil
+	})
+}
+
+// GetOrSetContext is like GetOrSetErr(), but stops waiting for the loader if
+// the context is cancelled, in which case the context's error is returned.
+//
+// The loader keeps running if the context is cancelled, and its value is
+// stored and returned to any other callers that are still waiting. As the
+// loader is shared between callers it's run with a context that is never
+// cancelled; use a timeout in the loader if need be.
+func (c *cache[K, V]) GetOrSetContext(ctx context.Context, k K, f func(context.Context) (V, time.Duration, error)) (V, error) {
+	k = c.normalizeKey(k)
+	if v, ok := c.Get(k); ok {
+		return v, nil
+	}
+	return c.flights.start(k, func() (V, error) {
+		// May have been set while starting the flight.
+		if v, ok := c.Get(k); ok {
+			return v, nil
+		}
+		var (
+			v   V
+			d   time.Duration
+			err error
+		)
+		c.withLabels(k, func() { v, d, err = f(context.Background()) })
+		if err != nil {
+			return c.zero(), err
+		}
+		if d != DontCache {
+			c.SetWithExpire(k, v, d)
+		}
+		return c.readValue(v), nil
+	}).wait(ctx)
+}
+
+// GetFresh gets a new value with the loader function and stores it, ignoring
+// any value that's currently in the cache.
+//
+// This is useful to force refreshing a value. The existing value remains in the
+// cache until the loader returns, so other goroutines will never see a missing
+// value. If the loader returns an error the existing item is left untouched and
+// the error is returned.
+//
+// The item is stored with the default expiration. Concurrent calls for the
+// same key are coalesced, see Refresh().
+func (c *cache[K, V]) GetFresh(k K, f func() (V, error)) (V, error) {
+	k = c.normalizeKey(k)
+	return c.flights.do(k, func() (V, error) {
+		var (
+			v   V
+			err error
+		)
+		c.withLabels(k, func() { v, err = f() })
+		if err != nil {
+			return c.zero(), err
+		}
+		c.SetWithExpire(k, v, DefaultExpiration)
+		return c.readValue(v), nil
+	})
+}
+
+// Refresh gets a new value with the loader function and stores it.
+//
+// This is like GetFresh(), except that it doesn't return the value. The new
+// value replaces the existing value atomically; there is never a moment where
+// the key isn't set, as would be the case with Delete() followed by Set().
+//
+// If there's already a refresh running for this key it will wait for that to
+// finish, rather than running the loader again.
+func (c *cache[K, V]) Refresh(k K, f func() (V, error)) error {
+	_, err := c.GetFresh(k, f)
+	return err
+}
+
+// GetStale gets an item from the cache without checking if it's expired.
+//
+// Returns the item or the zero value and a bool indicating whether the key was
+// expired and a bool indicating whether the key was set.
+func (c *cache[K, V]) GetStale(k K) (v V, expired bool, ok bool) {
+	c.mu.RLock()
+	k = c.key(k)
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok {
+		dv := c.defaultValue
+		c.mu.RUnlock()
+		return dv, false, false
+	}
+	expired = item.Expiration > 0 && c.nanotime() > item.Expiration
+	if l, ok := c.lazy[k]; ok {
+		c.mu.RUnlock()
+		return c.resolve(k, l), expired, true
+	}
+	v = c.read(item.Object)
+	c.mu.RUnlock()
+	return v, expired, true
+}
+
+// GetWithExpire returns an item and its expiration time from the cache.
+//
+// It returns the item or the zero value, the expiration time if one is set (if
+// the item never expires a zero value for time.Time is returned), and a bool
+// indicating whether the key was set.
+func (c *cache[K, V]) GetWithExpire(k K) (V, time.Time, bool) {
+	c.mu.RLock()
+	k = c.key(k)
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok {
+		dv := c.defaultValue
+		c.mu.RUnlock()
+		return dv, time.Time{}, false
+	}
+
+	if item.Expiration > 0 && c.nanotime() > item.Expiration {
+		expire, dv := c.expireOnAccess, c.defaultValue
+		c.mu.RUnlock()
+		if expire {
+			c.expire(k)
+		}
+		return dv, time.Time{}, false
+	}
+	if _, ok := c.uses[k]; ok {
+		c.mu.RUnlock()
+		item, ok = c.use(k)
+		if !ok {
+			return item.Object, time.Time{}, false
+		}
+	} else {
+		if c.ttlStats != nil {
+			c.ttlStats.get(k)
+		}
+		if c.lfu != nil {
+			c.lfu.hit(k)
+		}
+		if c.lru != nil {
+			c.lru.get(k)
+		}
+		if l, ok := c.lazy[k]; ok {
+			c.mu.RUnlock()
+			item.Object = c.resolve(k, l)
+		} else {
+			item.Object = c.read(item.Object)
+			c.mu.RUnlock()
+		}
+	}
+
+	if item.Expiration > 0 {
+		// Return the item and the expiration time
+		return item.Object, time.Unix(0, item.Expiration), true
+	}
+	// If expiration <= 0 (i.e. no expiration time set) then return the item
+	// and a zeroed time.Time
+	return item.Object, time.Time{}, true
+}
+
+// Modify the value of an existing key.
+//
+// This is thread-safe; for example to increment a number:
+//
+//	cache.Modify("one", func(v int) int { return v + 1 })
+//
+// Or setting a map key:
+//
+//	cache.Modify("key", func(v map[string]string) map[string]string {
+//	      v["k"] = "v"
+//	      return v
+//	})
+//
+// This is thread-safe and can be safely run by multiple goroutines modifying
+// the same key. If you would use Get() + Set() then two goroutines may Get()
+// the same value and the modification of one of them will be lost.
+//
+// This is not run for keys that are not set yet; the boolean return indicates
+// if the key was set and if the function was applied.
+func (c *cache[K, V]) Modify(k K, f func(V) V) (V, bool) {
+	k = c.lockKey(k)
+	defer c.unlock()
+	if c.rejectWrite() {
+		return c.zero(), false
+	}
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok {
+		return c.zero(), false
+	}
+	if item.Expiration > 0 && c.nanotime() > item.Expiration {
+		return c.zero(), false
+	}
+
+	item.Object = f(item.Object)
+	c.items[k] = item
+	c.written(k)
+	if c.ttlStats != nil {
+		c.ttlStats.get(k)
+	}
+	if c.lfu != nil {
+		c.lfu.hit(k)
+	}
+	if c.lru != nil {
+		c.lru.get(k)
+	}
+	c.weigh(k, item.Object)
+	return c.read(item.Object), true
+}
+
+// ModifyErr is like Modify(), but the function can return an error to abort the
+// modification, in which case the stored value is left unchanged and the error
+// is returned.
+//
+// It returns an error if the key isn't set or expired; the function isn't
+// called in that case.
+func (c *cache[K, V]) ModifyErr(k K, f func(V) (V, error)) (V, error) {
+	k = c.lockKey(k)
+	defer c.unlock()
+	if c.rejectWrite() {
+		return c.zero(), ErrFrozen
+	}
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok || (item.Expiration > 0 && c.nanotime() > item.Expiration) {
+		return c.zero(), fmt.Errorf("zcache.ModifyErr: item %s doesn't exist", c.formatKey(k))
+	}
+
+	v, err := f(item.Object)
+	if err != nil {
+		return c.zero(), err
+	}
+	item.Object = v
+	c.items[k] = item
+	c.written(k)
+	if c.ttlStats != nil {
+		c.ttlStats.get(k)
+	}
+	if c.lfu != nil {
+		c.lfu.hit(k)
+	}
+	if c.lru != nil {
+		c.lru.get(k)
+	}
+	c.weigh(k, item.Object)
+	return c.read(item.Object), nil
+}
+
+// ModifyResult is the result for a single key from ModifyMany().
+type ModifyResult[V any] struct {
+	Value V    // New value, or the zero value if OK is false.
+	OK    bool // Key was set and the function was applied.
+}
+
+// ModifyMany modifies the values of many keys, with a single lock.
+//
+// This is like calling Modify() for every key. The results are in the same
+// order as the keys; keys that are not set or expired are reported with OK set
+// to false, and the function isn't called for them.
+func (c *cache[K, V]) ModifyMany(keys []K, f func(K, V) V) []ModifyResult[V] {
+	norm := c.lockKeys(keys)
+	defer c.unlock()
+	if c.rejectWrite() {
+		return make([]ModifyResult[V], len(keys))
+	}
+
+	res := make([]ModifyResult[V], len(keys))
+	now := c.nanotime()
+	for i, k := range norm {
+		// "Inlining" of get and Expired
+		item, ok := c.items[k]
+		if !ok || (item.Expiration > 0 && now > item.Expiration) {
+			continue
+		}
+
+		item.Object = f(k, item.Object)
+		c.items[k] = item
+		c.written(k)
+		if c.ttlStats != nil {
+			c.ttlStats.get(k)
+		}
+		if c.lfu != nil {
+			c.lfu.hit(k)
+		}
+		if c.lru != nil {
+			c.lru.get(k)
+		}
+		c.weigh(k, item.Object)
+		res[i] = ModifyResult[V]{Value: c.read(item.Object), OK: true}
+	}
+	return res
+}
+
+// ModifyOrSet applies f to the value of the key if it's set, or stores def with
+// the default expiration if it's not set or expired.
+//
+// This is like Modify(), but without the race of a Get() + Set() fallback for
+// keys that don't exist yet. The function isn't called for def. Returns the
+// new value.
+func (c *cache[K, V]) ModifyOrSet(k K, f func(V) V, def V) V {
+	k = c.lockKey(k)
+	defer c.unlock()
+	if c.rejectWrite() {
+		if v, ok := c.get(k); ok {
+			return c.read(v)
+		}
+		return c.read(def)
+	}
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok || (item.Expiration > 0 && c.nanotime() > item.Expiration) {
+		if c.checkKey("zcache.ModifyOrSet", k) == nil {
+			c.set(k, def, DefaultExpiration)
+		}
+		return c.read(def)
+	}
+
+	item.Object = f(item.Object)
+	c.items[k] = item
+	c.written(k)
+	if c.ttlStats != nil {
+		c.ttlStats.get(k)
+	}
+	if c.lfu != nil {
+		c.lfu.hit(k)
+	}
+	if c.lru != nil {
+		c.lru.get(k)
+	}
+	c.weigh(k, item.Object)
+	return c.read(item.Object)
+}
+
+// Delete an item from the cache. Does nothing if the key is not in the cache.
+func (c *cache[K, V]) Delete(k K) {
+	c.mu.Lock()
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return
+	}
+	k = c.key(k)
+	v, onEvict := c.delete(k)
+	c.mu.Unlock()
+	if onEvict != nil {
+		onEvict(k, v)
+	}
+}
+
+// BindContext deletes the keys when the context is cancelled.
+//
+// This is useful for request-scoped items stored in a long-lived cache, which
+// shouldn't outlive the request. The keys are deleted even if they were set
+// again after calling BindContext(). Nothing is done if the context can never
+// be cancelled.
+func (c *cache[K, V]) BindContext(ctx context.Context, keys ...K) {
+	done := ctx.Done()
+	if done == nil || len(keys) == 0 {
+		return
+	}
+	go func() {
+		<-done
+		for _, k := range keys {
+			c.Delete(k)
+		}
+	}()
+}
+
+// Rename a key; the value and expiry will be left untouched; onEvicted will not
+// be called.
+//
+// Existing keys will be overwritten; returns false is the src key doesn't
+// exist.
+func (c *cache[K, V]) Rename(src, dst K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejectWrite() {
+		return false
+	}
+	src, dst = c.key(src), c.key(dst)
+	if c.checkKey("zcache.Rename", dst) != nil {
+		return false
+	}
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[src]
+	if !ok {
+		return false
+	}
+	if item.Expiration > 0 && c.nanotime() > item.Expiration {
+		return false
+	}
+
+	c.put(dst, c.take(src))
+	return true
+}
+
+// RenameFunc renames all keys for which the function returns true, in a
+// single locked pass.
+//
+// This is useful to migrate the format of keys. As with Rename(), the values
+// and expiry are left untouched, onEvicted will not be called, and existing
+// keys will be overwritten. If more than one key is renamed to the same key
+// it's undefined which item is kept. Expired items are not renamed.
+//
+// It returns the number of renamed keys.
+func (c *cache[K, V]) RenameFunc(f func(K) (K, bool)) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rejectWrite() {
+		return 0
+	}
+
+	type rename struct {
+		src, dst K
+		m        movedItem[K, V]
+	}
+	var (
+		renames []rename
+		now     = c.nanotime()
+	)
+	for k, item := range c.items {
+		if item.Expiration > 0 && now > item.Expiration {
+			continue
+		}
+		if dst, ok := f(k); ok && dst != k {
+			if dst = c.key(dst); c.checkKey("zcache.Rename", dst) == nil {
+				renames = append(renames, rename{src: k, dst: dst})
+			}
+		}
+	}
+
+	// Take all items first, so that renaming e.g. a→b and b→c works.
+	for i := range renames {
+		renames[i].m = c.take(renames[i].src)
+	}
+	for _, r := range renames {
+		c.put(r.dst, r.m)
+	}
+	return len(renames)
+}
+
+// movedItem is an item and its associated data, for renaming keys.
+type movedItem[K comparable, V any] struct {
+	item    Item[V]
+	evict   func(K, V)
+	uses    int
+	hasUses bool
+	err     error
+	lazy    *lazyValue[V]
+	pin     *pin[K]
+	freq    uint32
+	cost    int64
+}
+
+// take removes the item and associated data for src; the lock must be held.
+func (c *cache[K, V]) take(src K) movedItem[K, V] {
+	m := movedItem[K, V]{item: c.items[src]}
+	c.trackExpiring(m.item.Expiration, 0)
+	delete(c.items, src)
+	c.written(src)
+	if f, ok := c.evictFuncs[src]; ok {
+		m.evict = f
+		delete(c.evictFuncs, src)
+	}
+	if n, ok := c.uses[src]; ok {
+		m.uses, m.hasUses = n, true
+		delete(c.uses, src)
+	}
+	if err, ok := c.errs[src]; ok {
+		m.err = err
+		delete(c.errs, src)
+	}
+	if l, ok := c.lazy[src]; ok {
+		m.lazy = l
+		delete(c.lazy, src)
+	}
+	if p, ok := c.pins[src]; ok {
+		m.pin = p
+		delete(c.pins, src)
+	}
+	if c.ttlStats != nil {
+		c.ttlStats.remove(src)
+	}
+	if c.lfu != nil {
+		m.freq = c.lfu.take(src)
+	}
+	if c.lru != nil {
+		c.lru.remove(src)
+	}
+	if len(c.costs) > 0 {
+		m.cost = c.costs[src]
+		c.cost -= m.cost
+		delete(c.costs, src)
+	}
+	return m
+}
+
+// put stores an item taken with take() as dst, replacing any existing item;
+// the lock must be held.
+func (c *cache[K, V]) put(dst K, m movedItem[K, V]) {
+	c.trackExpiring(c.items[dst].Expiration, m.item.Expiration)
+	c.items[dst] = m.item
+	c.written(dst)
+	if c.filter != nil {
+		c.filterAdd(dst)
+	}
+	if len(c.evictFuncs) > 0 || m.evict != nil {
+		delete(c.evictFuncs, dst)
+		if m.evict != nil {
+			if c.evictFuncs == nil {
+				c.evictFuncs = make(map[K]func(K, V))
+			}
+			c.evictFuncs[dst] = m.evict
+		}
+	}
+	if len(c.uses) > 0 || m.hasUses {
+		delete(c.uses, dst)
+		if m.hasUses {
+			if c.uses == nil {
+				c.uses = make(map[K]int)
+			}
+			c.uses[dst] = m.uses
+		}
+	}
+	if len(c.errs) > 0 || m.err != nil {
+		delete(c.errs, dst)
+		if m.err != nil {
+			if c.errs == nil {
+				c.errs = make(map[K]error)
+			}
+			c.errs[dst] = m.err
+		}
+	}
+	if len(c.lazy) > 0 || m.lazy != nil {
+		delete(c.lazy, dst)
+		if m.lazy != nil {
+			if c.lazy == nil {
+				c.lazy = make(map[K]*lazyValue[V])
+			}
+			c.lazy[dst] = m.lazy
+		}
+	}
+	if len(c.pins) > 0 {
+		c.unpin(dst)
+	}
+	if m.pin != nil {
+		if c.pins == nil {
+			c.pins = make(map[K]*pin[K])
+		}
+		m.pin.k = dst
+		c.pins[dst] = m.pin
+	}
+	if c.lfu != nil {
+		c.lfu.put(dst, m.freq)
+	}
+	if c.lru != nil {
+		c.lru.set(dst)
+	}
+	if c.costs != nil {
+		c.cost += m.cost - c.costs[dst]
+		c.costs[dst] = m.cost
+	}
+}
+
+// Pop gets an item from the cache and deletes it.
+//
+// The bool return indicates if the item was set.
+func (c *cache[K, V]) Pop(k K) (V, bool) {
+	k = c.lockKey(k)
+	if c.rejectWrite() {
+		dv := c.defaultValue
+		c.unlock()
+		return dv, false
+	}
+
+	// "Inlining" of get and Expired
+	item, ok := c.items[k]
+	if !ok {
+		dv := c.defaultValue
+		c.unlock()
+		return dv, false
+	}
+	if item.Expiration > 0 && c.nanotime() > item.Expiration {
+		dv := c.defaultValue
+		c.unlock()
+		return dv, false
+	}
+
+	if c.ttlStats != nil {
+		c.ttlStats.get(k)
+	}
+	if c.lfu != nil {
+		c.lfu.hit(k)
+	}
+	if c.lru != nil {
+		c.lru.get(k)
+	}
+	v, onEvict := c.delete(k)
+	rv := c.read(item.Object)
+	c.unlock()
+	if onEvict != nil {
+		onEvict(k, v)
+	}
+
+	return rv, true
+}
+
+// DeleteExpired deletes all expired items from the cache.
+//
+// This also removes expired locks acquired with TryLockKey().
+func (c *cache[K, V]) DeleteExpired() { c.deleteExpired(false) }
+
+// deleteExpired deletes all expired items, returning the number of deleted
+// items and how long the write lock was held. If capped is true it deletes at
+// most maxEvictions items.
+func (c *cache[K, V]) deleteExpired(capped bool) (int, time.Duration) {
+	var (
+		evictedItems []keyAndValue[K, V]
+		expired      []K
+		deleted      int
+		batch        map[K]V
+	)
+	now := c.nanotime()
+	c.mu.Lock()
+	start := time.Now()
+
+	onBatch := c.onEvictedBatch
+	max := 0
+	if capped {
+		max = c.maxEvictions
+	}
+	if c.expiring > 0 { // Don't scan the items if nothing can expire.
+		for k, v := range c.items {
+			if max > 0 && deleted >= max {
+				break
+			}
+			// "Inlining" of expired
+			if v.Expiration > 0 && now > v.Expiration {
+				ov, onEvict := c.delete(k)
+				if onEvict != nil {
+					evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov, onEvict})
+				}
+				if onBatch != nil {
+					if batch == nil {
+						batch = make(map[K]V)
+					}
+					batch[k] = v.Object
+				}
+				if c.notifier != nil {
+					expired = append(expired, k)
+				}
+				deleted++
+			}
+		}
+	}
+	for k, l := range c.locks {
+		if l.expired(now) {
+			delete(c.locks, k)
+		}
+	}
+	c.sortEvicted(evictedItems)
+	took := time.Since(start)
+	notifier := c.notifier
+	c.mu.Unlock()
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
+	}
+	if len(batch) > 0 {
+		onBatch(batch)
+	}
+	if len(expired) > 0 {
+		notifier.notify(expired...)
+	}
+	return deleted, took
+}
+
+// JanitorStatus gets the status of the janitor.
+//
+// This returns if the janitor is running, when it last ran, and how many items
+// it deleted in the last run. This can be used in health checks to detect a
+// janitor that is stuck (e.g. because an OnEvicted callback deadlocked).
+//
+// The janitor isn't running if the cache was created without a cleanup
+// interval.
+func (c *cache[K, V]) JanitorStatus() (running bool, lastRun time.Time, lastDeleted int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.janitor == nil {
+		return false, time.Time{}, 0
+	}
+	return c.janitor.running, c.janitor.lastRun, c.janitor.lastDeleted
+}
+
+// JanitorLockTime gets how long the last janitor run held the write lock,
+// during which all other operations on the cache are blocked, and the current
+// janitor interval.
+//
+// The interval is the cleanup interval given to New(), unless it was changed
+// with JanitorBackoff().
+func (c *cache[K, V]) JanitorLockTime() (took, interval time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.janitor == nil {
+		return 0, 0
+	}
+	return c.janitor.lockTime, c.janitor.interval
+}
+
+// JanitorBackoff makes the janitor run less often if it holds the write lock
+// for too long.
+//
+// If a janitor run holds the lock for longer than threshold the interval is
+// doubled, up to maxInterval. If it takes less than half the threshold the
+// interval is halved again, down to the cleanup interval given to New(). The
+// notify function is called every time the interval changes; it can be nil.
+//
+// Use a threshold of 0 to disable this (the default), which resets the
+// interval. This does nothing if the cache was created without a cleanup
+// interval.
+func (c *cache[K, V]) JanitorBackoff(threshold, maxInterval time.Duration, notify func(interval, took time.Duration)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.janitor == nil {
+		return
+	}
+	c.janitor.threshold, c.janitor.maxInterval, c.janitor.notify = threshold, maxInterval, notify
+}
+
+// JanitorSchedule changes when the janitor runs.
+//
+// The next run is delayed by delay instead of the cleanup interval; after that
+// it runs on the cleanup interval again. If align is more than 0 all runs are
+// aligned to multiples of it on the wall clock (e.g. time.Minute to run at the
+// start of every minute), and the janitor will never run more than once every
+// align.
+//
+// This is useful to prevent many caches that are created at the same time
+// (e.g. on process start) from all running their janitor at the same time. This
+// does nothing if the cache was created without a cleanup interval.
+func (c *cache[K, V]) JanitorSchedule(delay, align time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.janitor == nil {
+		return
+	}
+	c.janitor.delay, c.janitor.align = delay, align
+	select {
+	case c.janitor.reschedule <- struct{}{}:
+	default:
+	}
+}
+
+// OnExpiring sets a function to call for items that will expire within the
+// lead time.
+//
+// This is run by the janitor, so it's never called if the cache was created
+// without a cleanup interval. The function is called once for every item, but
+// will be called again if the expiry is changed (e.g. with Touch() or Set()).
+// It may be called later than the lead time if the cleanup interval is longer
+// than the lead time.
+//
+// This can be used to refresh items before they expire, or to gracefully close
+// connections before they're removed.
+//
+// Can be set to nil to disable it (the default).
+func (c *cache[K, V]) OnExpiring(lead time.Duration, f func(K, V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onExpiring, c.expiringLead, c.expiringSeen = f, lead, nil
+}
+
+// runExpiring runs the OnExpiring callback for items about to expire.
+func (c *cache[K, V]) runExpiring() {
+	var expiring []keyAndValue[K, V]
+	now := c.nanotime()
+	c.mu.Lock()
+	if c.onExpiring == nil {
+		c.mu.Unlock()
+		return
+	}
+	if c.expiringSeen == nil {
+		c.expiringSeen = make(map[K]int64)
+	}
+	for k, e := range c.expiringSeen {
+		if v, ok := c.items[k]; !ok || v.Expiration != e {
+			delete(c.expiringSeen, k)
+		}
+	}
+	lead := c.expiringLead.Nanoseconds()
+	for k, v := range c.items {
+		if v.Expiration <= 0 || now > v.Expiration || v.Expiration-now > lead {
+			continue
+		}
+		if _, ok := c.expiringSeen[k]; ok {
+			continue
+		}
+		c.expiringSeen[k] = v.Expiration
+		expiring = append(expiring, keyAndValue[K, V]{k, v.Object, c.onExpiring})
+	}
+	c.mu.Unlock()
+	for _, v := range expiring {
+		v.onEvict(v.key, v.value)
+	}
+}
+
+// ReadPipeline sets functions that are applied to values before they're
+// returned, for example to decompress, decrypt, or redact values:
+//
+//	cache.ReadPipeline(decompress, redactPasswords)
+//
+// The functions are applied in order on every read by Get(), GetWithExpire(),
+// GetStale(), Pop(), Modify(), Items(), ItemsWhere(), and MapItems(), and to
+// the values returned by GetOrSet(), GetOrSetErr(), GetOrSetContext(),
+// GetFresh(), and Promise(), including values that were just loaded. The filter
+// function for ItemsWhere() and the function for Modify() operate on the stored
+// values. ItemsAll() and DeleteAll() also return the values as stored, which
+// is useful for serializing the cache.
+//
+// The functions are run while the cache is locked, so should be fast. Call
+// without arguments to clear the pipeline.
+func (c *cache[K, V]) ReadPipeline(fs ...func(V) V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readPipeline = fs
+}
+
+// DefaultValue sets the value that's returned if a key isn't set or has
+// expired, instead of the zero value.
+//
+// This applies to Get(), GetWithExpire(), GetStale(), Pop(), and the methods
+// built on them; the boolean return still indicates if the key was set. This
+// can be useful if the zero value is a legitimate value, and a missing item
+// should be treated differently (e.g. -1 for a number).
+func (c *cache[K, V]) DefaultValue(v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.storeFilter()
+	c.defaultValue = v
+}
+
+// KeyNormalizer sets a function to normalize keys, which is applied to the key
+// on every operation; for example to make string keys case-insensitive:
+//
+//	cache.KeyNormalizer(strings.ToLower)
+//
+// The function must be idempotent, as it may be applied more than once to the
+// same key. Callbacks such as OnEvicted receive the normalized key. Keys that
+// are already in the cache are not modified. Set to nil to disable it.
+func (c *cache[K, V]) KeyNormalizer(f func(K) K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.storeFilter()
+	c.normalize = f
+}
+
+// SetName sets the name of this cache, for diagnostics.
+//
+// If a name is set the loader functions for GetOrSet(), GetFresh(), and
+// Refresh() are run with the pprof labels "cache-name" and "key-prefix" (the
+// first 16 bytes of the key, as formatted by the KeyFormatter()), so that CPU
+// profiles can attribute time spent in loaders to specific caches.
+func (c *cache[K, V]) SetName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+// Name gets the name set with SetName().
+func (c *cache[K, V]) Name() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.name
+}
+
+// KeyFormatter sets the function to format keys as a string, for diagnostics
+// such as error messages and pprof labels.
+//
+// The default is to format the key with fmt.Sprint(), which may not be very
+// meaningful for some keys (e.g. structs). Set to nil to use the default.
+func (c *cache[K, V]) KeyFormatter(f func(K) string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keyFormatter = f
+}
+
+// formatKey formats k with the function set with KeyFormatter(); the lock
+// must be held.
+func (c *cache[K, V]) formatKey(k K) string {
+	if c.keyFormatter == nil {
+		return fmt.Sprint(k)
+	}
+	return c.keyFormatter(k)
+}
+
+// withLabels runs f with pprof labels if the cache has a name.
+func (c *cache[K, V]) withLabels(k K, f func()) {
+	c.mu.RLock()
+	name := c.name
+	if name == "" {
+		c.mu.RUnlock()
+		f()
+		return
+	}
+	prefix := c.formatKey(k)
+	c.mu.RUnlock()
+	if len(prefix) > 16 {
+		prefix = prefix[:16]
+	}
+	pprof.Do(context.Background(), pprof.Labels("cache-name", name, "key-prefix", prefix),
+		func(context.Context) { f() })
+}
+
+// Strict sets strict mode, in which passing a negative duration other than
+// NoExpiration to any of the methods will panic.
+//
+// Outside of strict mode such durations are treated as NoExpiration, except
+// for methods that return an error (AddWithExpire() and ReplaceWithExpire()),
+// which will return an error.
+func (c *cache[K, V]) Strict(enable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strict = enable
+}
+
+// ExpireOnAccess sets if expired items should be deleted when they're found by
+// Get(), GetWithExpire(), Items(), or Keys().
+//
+// Normally expired items are only deleted by the janitor or DeleteExpired(),
+// which means OnEvicted is never called for expired items if there is no
+// janitor. With this enabled the OnEvicted callback is reliably called for
+// expired items, at the cost of taking a write lock when an expired item is
+// found.
+//
+// This is disabled by default.
+func (c *cache[K, V]) ExpireOnAccess(enable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireOnAccess = enable
+}
+
+// expire deletes the items for the given keys if they're expired.
+func (c *cache[K, V]) expire(keys ...K) {
+	var (
+		evictedItems []keyAndValue[K, V]
+		expired      []K
+	)
+	now := c.nanotime()
+	c.mu.Lock()
+	for _, k := range keys {
+		// Check again, as it may have been set since the read lock was released.
+		if v, ok := c.items[k]; ok && v.Expiration > 0 && now > v.Expiration {
+			ov, onEvict := c.delete(k)
+			if onEvict != nil {
+				evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov, onEvict})
+			}
+			if c.notifier != nil {
+				expired = append(expired, k)
+			}
+		}
+	}
+	c.sortEvicted(evictedItems)
+	notifier := c.notifier
+	c.mu.Unlock()
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
+	}
+	if len(expired) > 0 {
+		notifier.notify(expired...)
+	}
+}
+
+// OnEvicted sets an function to call when an item is evicted from the cache.
+//
+// The function is run with the key and value. This is also run when a cache
+// item is is deleted manually, but *not* when it is overwritten.
+//
+// Items set with SetWithEvict() use their own callback instead.
+//
+// Can be set to nil to disable it (the default). This replaces the previous
+// function; use AddEvictionHandler() to add more than one function.
+func (c *cache[K, V]) OnEvicted(f func(K, V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvictedFunc = f
+	c.composeEvicted()
+}
+
+// OnEvictedBatch sets a function to call with all items that were evicted at
+// once by DeleteExpired() (which is also run by the janitor) and DeleteAll().
+//
+// This is called once per run with a map of all evicted keys and values, which
+// is a lot cheaper than calling OnEvicted for every item if many items expire
+// at the same time. It's not called if no items were evicted, and it's not
+// called for items that are deleted in other ways, such as Delete().
+//
+// This is run in addition to OnEvicted, after it, and is also run for items set
+// with SetWithEvict(). The map can be modified freely. Can be set to nil to
+// disable it (the default).
+func (c *cache[K, V]) OnEvictedBatch(f func(map[K]V)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onEvictedBatch = f
+}
+
+type evictHandler[K comparable, V any] struct{ f func(K, V) }
+
+// AddEvictionHandler adds a function to call when an item is evicted from the
+// cache, in addition to the function set with OnEvicted() and any other
+// handlers.
+//
+// The function set with OnEvicted() is run first, followed by the handlers in
+// the order they were added. Like OnEvicted(), the handlers are not run for
+// items set with SetWithEvict().
+//
+// The returned function removes the handler; it's safe to call more than once.
+func (c *cache[K, V]) AddEvictionHandler(f func(K, V)) (remove func()) {
+	h := &evictHandler[K, V]{f}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictHandlers = append(c.evictHandlers, h)
+	c.composeEvicted()
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i := range c.evictHandlers {
+			if c.evictHandlers[i] == h {
+				// Copy, as composeEvicted() may still be using the old slice.
+				c.evictHandlers = append(c.evictHandlers[:i:i], c.evictHandlers[i+1:]...)
+				c.composeEvicted()
+				break
+			}
+		}
+	}
+}
+
+// composeEvicted sets onEvicted to a function that runs the OnEvicted()
+// function and all eviction handlers; the lock must be held.
+func (c *cache[K, V]) composeEvicted() {
+	if len(c.evictHandlers) == 0 {
+		c.onEvicted = c.onEvictedFunc
+		return
+	}
+	first, handlers := c.onEvictedFunc, c.evictHandlers
+	c.onEvicted = func(k K, v V) {
+		if first != nil {
+			first(k, v)
+		}
+		for _, h := range handlers {
+			h.f(k, v)
+		}
+	}
+}
+
+// EvictionOrder sets the order in which the eviction callbacks are run when
+// more than one item is evicted at once, such as with DeleteExpired(),
+// DeleteAll(), and DeleteFunc().
+//
+// By default the order is undefined, as the cache is a map. This is mostly
+// useful in tests, so that assertions about the order of the callbacks are
+// deterministic. For example:
+//
+//	cache.EvictionOrder(func(a, b string) bool { return a < b })
+//
+// The callbacks are always run synchronously after the cache is unlocked. Set
+// to nil to disable it (the default).
+func (c *cache[K, V]) EvictionOrder(less func(a, b K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictOrder = less
+}
+
+// Items returns a copy of all unexpired items in the cache.
+//
+// Use ItemsAll() to include expired items that haven't been deleted yet, or
+// ItemsFrozen() for a read-only view that doesn't copy the items.
+//
+// The returned map is never used by the cache, so it can be modified without
+// affecting the cache. Note the values themselves aren't copied: modifying the
+// contents of a pointer, map, or slice value will affect the cached value.
+func (c *cache[K, V]) Items() map[K]Item[V] {
+	c.mu.RLock()
+
+	var expired []K
+	m := make(map[K]Item[V], len(c.items))
+	now := c.now()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			if c.expireOnAccess {
+				expired = append(expired, k)
+			}
+			continue
+		}
+		v.Object = c.read(v.Object)
+		m[k] = v
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.expire(expired...)
+	}
+	return m
+}
+
+// ItemsFrozen returns a read-only view of all items in the cache, without
+// copying them.
+//
+// The cache is read-locked until the view is closed; see ItemsView. Use Items()
+// to get a copy that doesn't block writes to the cache.
+func (c *cache[K, V]) ItemsFrozen() *ItemsView[K, V] {
+	return newItemsView[K, V](nil, c)
+}
+
+// ItemsWhere returns a copy of all unexpired items for which the filter
+// function returns true.
+func (c *cache[K, V]) ItemsWhere(filter func(K, Item[V]) bool) map[K]Item[V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m := make(map[K]Item[V])
+	now := c.now()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		if filter(k, v) {
+			v.Object = c.read(v.Object)
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// MapItems returns all unexpired items in the cache, transformed by the
+// function.
+//
+// For example to get the lengths of all values:
+//
+//	lengths := zcache.MapItems(c, func(k string, v []byte) int { return len(v) })
+func MapItems[K comparable, V, T any](c *Cache[K, V], f func(K, V) T) map[K]T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m := make(map[K]T, len(c.items))
+	now := c.nanotime()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			continue
+		}
+		m[k] = f(k, c.read(v.Object))
+	}
+	return m
+}
+
+// ItemsAll returns a copy of all items in the cache, including expired items
+// that haven't been deleted yet.
+//
+// Use Item.Expired() to check if an item has expired. This is useful to see
+// what's actually in the cache; ItemCount() is always the same as the length
+// of this map.
+func (c *cache[K, V]) ItemsAll() map[K]Item[V] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	m := make(map[K]Item[V], len(c.items))
+	for k, v := range c.items {
+		m[k] = v
+	}
+	return m
+}
+
+// KeysAll gets a list of all keys, including keys for expired items that
+// haven't been deleted yet, in no particular order.
+func (c *cache[K, V]) KeysAll() []K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]K, 0, len(c.items))
+	for k := range c.items {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Keys gets a list of all keys, in no particular order.
+func (c *cache[K, V]) Keys() []K {
+	c.mu.RLock()
+
+	var expired []K
+	keys := make([]K, 0, len(c.items))
+	now := c.now()
+	for k, v := range c.items {
+		// "Inlining" of Expired
+		if v.Expiration > 0 && now > v.Expiration {
+			if c.expireOnAccess {
+				expired = append(expired, k)
+			}
+			continue
+		}
+		keys = append(keys, k)
+	}
+	c.mu.RUnlock()
+
+	if len(expired) > 0 {
+		c.expire(expired...)
+	}
+	return keys
+}
+
+// ExpiringSoon gets up to n keys that are closest to expiring, ordered by
+// expiration time.
+//
+// Items that never expire and items that have already expired are not
+// included. This sorts all items on every call, so it's not very fast for
+// large caches.
+func (c *cache[K, V]) ExpiringSoon(n int) []K {
+	if n < 1 {
+		return nil
+	}
+
+	c.mu.RLock()
+	type exp struct {
+		k K
+		e int64
+	}
+	all := make([]exp, 0, len(c.items))
+	now := c.nanotime()
+	for k, v := range c.items {
+		if v.Expiration > 0 && now <= v.Expiration {
+			all = append(all, exp{k, v.Expiration})
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].e < all[j].e })
+	if n > len(all) {
+		n = len(all)
+	}
+	keys := make([]K, 0, n)
+	for _, e := range all[:n] {
+		keys = append(keys, e.k)
+	}
+	return keys
+}
+
+// ItemCount returns the number of items in the cache.
+//
+// This may include items that have expired but have not yet been cleaned up;
+// see ItemsAll().
+func (c *cache[K, V]) ItemCount() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return len(c.items)
 }
 
 // Reset deletes all items from the cache without calling OnEvicted.
+//
+// The memory allocated for the map is retained, so that refilling the cache
+// doesn't need to grow the map again.
 func (c *cache[K, V]) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.items = map[K]Item[V]{}
+	if c.rejectWrite() {
+		return
+	}
+	for k := range c.items { // Optimized to a map clear by the compiler.
+		delete(c.items, k)
+	}
+	c.writtenAll()
+	if c.filter != nil {
+		c.rebuildFilter()
+	}
+	c.expiring = 0
+	c.unpinAll()
+	c.evictFuncs, c.uses, c.errs, c.lazy = nil, nil, nil, nil
+	if c.ttlStats != nil {
+		c.ttlStats.removeAll()
+	}
+	if c.lfu != nil {
+		c.lfu.removeAll()
+	}
+	if c.lru != nil {
+		c.lru.removeAll()
+	}
+	if c.costs != nil {
+		c.costs, c.cost = make(map[K]int64), 0
+	}
 }
 
 // DeleteAll deletes all items from the cache and returns them.
 //
-// This calls OnEvicted for returned items.
+// This calls OnEvicted for returned items. The returned map is no longer used
+// by the cache and can be modified freely.
 func (c *cache[K, V]) DeleteAll() map[K]Item[V] {
 	c.mu.Lock()
-	items := c.items
-	c.items = map[K]Item[V]{}
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return map[K]Item[V]{}
+	}
+	items, onEvicted, evictFuncs := c.items, c.onEvicted, c.evictFuncs
+	order, onBatch := c.evictOrder, c.onEvictedBatch
+	c.unpinAll()
+	c.items, c.evictFuncs, c.uses, c.errs, c.lazy = map[K]Item[V]{}, nil, nil, nil, nil
+	c.expiring = 0
+	c.writtenAll()
+	if c.filter != nil {
+		c.rebuildFilter()
+	}
+	if c.ttlStats != nil {
+		c.ttlStats.removeAll()
+	}
+	if c.lfu != nil {
+		c.lfu.removeAll()
+	}
+	if c.lru != nil {
+		c.lru.removeAll()
+	}
+	if c.costs != nil {
+		c.costs, c.cost = make(map[K]int64), 0
+	}
 	c.mu.Unlock()
 
-	if c.onEvicted != nil {
+	if onEvicted != nil || len(evictFuncs) > 0 {
+		evict := func(k K, v Item[V]) {
+			if f, ok := evictFuncs[k]; ok {
+				f(k, v.Object)
+			} else if onEvicted != nil {
+				onEvicted(k, v.Object)
+			}
+		}
+		if order == nil {
+			for k, v := range items {
+				evict(k, v)
+			}
+		} else {
+			keys := make([]K, 0, len(items))
+			for k := range items {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool { return order(keys[i], keys[j]) })
+			for _, k := range keys {
+				evict(k, items[k])
+			}
+		}
+	}
+	if onBatch != nil && len(items) > 0 {
+		batch := make(map[K]V, len(items))
 		for k, v := range items {
-			c.onEvicted(k, v.Object)
+			batch[k] = v.Object
 		}
+		onBatch(batch)
 	}
 
 	return items
@@ -478,7 +2372,12 @@ func (c *cache[K, V]) DeleteAll() map[K]Item[V] {
 //
 // OnEvicted is called for deleted items.
 func (c *cache[K, V]) DeleteFunc(filter func(key K, item Item[V]) (del, stop bool)) map[K]Item[V] {
+	var evictedItems []keyAndValue[K, V]
 	c.mu.Lock()
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return map[K]Item[V]{}
+	}
 	m := map[K]Item[V]{}
 	for k, v := range c.items {
 		del, stop := filter(k, v)
@@ -487,35 +2386,138 @@ func (c *cache[K, V]) DeleteFunc(filter func(key K, item Item[V]) (del, stop boo
 				Object:     v.Object,
 				Expiration: v.Expiration,
 			}
-			c.delete(k)
+			ov, onEvict := c.delete(k)
+			if onEvict != nil {
+				evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov, onEvict})
+			}
 		}
 		if stop {
 			break
 		}
 	}
+	c.sortEvicted(evictedItems)
 	c.mu.Unlock()
 
-	if c.onEvicted != nil {
-		for k, v := range m {
-			c.onEvicted(k, v.Object)
-		}
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
 	}
 
 	return m
 }
 
+// Cursor is used to continue DeleteFuncWithin().
+type Cursor[K comparable] struct {
+	keys []K
+}
+
+// DeleteFuncWithin is like DeleteFunc(), but stops after the time budget is
+// used, so that deleting from a large cache doesn't block all other
+// operations for too long.
+//
+// It returns the deleted items and a cursor to continue where it stopped, or
+// nil if all items were processed. Pass a nil cursor to start:
+//
+//	var cur *zcache.Cursor[string]
+//	for {
+//		_, cur = c.DeleteFuncWithin(time.Millisecond, cur, filter)
+//		if cur == nil {
+//			break
+//		}
+//		time.Sleep(10 * time.Millisecond)
+//	}
+//
+// The keys are snapshotted when the cursor is created; items that are added
+// after that are not processed. The budget is checked every 64 items, so it
+// may be exceeded slightly.
+func (c *cache[K, V]) DeleteFuncWithin(budget time.Duration, cur *Cursor[K], filter func(key K, item Item[V]) bool) (map[K]Item[V], *Cursor[K]) {
+	var evictedItems []keyAndValue[K, V]
+	c.mu.Lock()
+	if c.rejectWrite() {
+		c.mu.Unlock()
+		return map[K]Item[V]{}, cur
+	}
+	start := time.Now()
+	if cur == nil {
+		cur = &Cursor[K]{keys: make([]K, 0, len(c.items))}
+		for k := range c.items {
+			cur.keys = append(cur.keys, k)
+		}
+	}
+
+	m := map[K]Item[V]{}
+	i := 0
+	for ; i < len(cur.keys); i++ {
+		if i > 0 && i%64 == 0 && time.Since(start) > budget {
+			break
+		}
+		k := cur.keys[i]
+		v, ok := c.items[k]
+		if !ok || !filter(k, v) {
+			continue
+		}
+		m[k] = v
+		ov, onEvict := c.delete(k)
+		if onEvict != nil {
+			evictedItems = append(evictedItems, keyAndValue[K, V]{k, ov, onEvict})
+		}
+	}
+	c.sortEvicted(evictedItems)
+	c.mu.Unlock()
+
+	for _, v := range evictedItems {
+		v.onEvict(v.key, v.value)
+	}
+	if i == len(cur.keys) {
+		return m, nil
+	}
+	return m, &Cursor[K]{keys: cur.keys[i:]}
+}
+
 func (c *cache[K, V]) set(k K, v V, d time.Duration) {
+	c.checkDuration("zcache.Set", d)
+	c.rearmWatermark()
 	var e int64
 	if d == DefaultExpiration {
-		d = c.defaultExpiration
+		d = c.defaultTTL()
 	}
 	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
+		e = c.nanotime() + int64(d)
 	}
+	c.trackExpiring(c.items[k].Expiration, e)
 	c.items[k] = Item[V]{
 		Object:     v,
 		Expiration: e,
 	}
+	c.written(k)
+	if c.filter != nil {
+		c.filterAdd(k)
+	}
+	if len(c.evictFuncs) > 0 {
+		delete(c.evictFuncs, k)
+	}
+	if len(c.uses) > 0 {
+		delete(c.uses, k)
+	}
+	if len(c.errs) > 0 {
+		delete(c.errs, k)
+	}
+	if len(c.lazy) > 0 {
+		delete(c.lazy, k)
+	}
+	if len(c.pins) > 0 {
+		c.unpin(k)
+	}
+	if c.ttlStats != nil {
+		c.ttlStats.set(k, d)
+	}
+	if c.lru != nil {
+		c.lru.set(k)
+		c.weigh(k, v)
+		if c.overLimit() {
+			c.evictOverflow()
+		}
+		c.checkWatermark()
+	}
 }
 
 func (c *cache[K, V]) get(k K) (V, bool) {
@@ -524,22 +2526,133 @@ func (c *cache[K, V]) get(k K) (V, bool) {
 		return c.zero(), false
 	}
 	// "Inlining" of Expired
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
+	if item.Expiration > 0 && c.nanotime() > item.Expiration {
 		return c.zero(), false
 	}
 	return item.Object, true
 }
 
-func (c *cache[K, V]) delete(k K) (V, bool) {
-	if c.onEvicted != nil {
+// delete an item, returning the value and the OnEvicted callback to run (if
+// any).
+func (c *cache[K, V]) delete(k K) (V, func(K, V)) {
+	c.written(k)
+	if c.ttlStats != nil {
+		c.ttlStats.remove(k)
+	}
+	if c.lfu != nil {
+		c.lfu.take(k)
+	}
+	if c.lru != nil {
+		c.lru.remove(k)
+	}
+	if len(c.costs) > 0 {
+		c.cost -= c.costs[k]
+		delete(c.costs, k)
+	}
+	if len(c.uses) > 0 {
+		delete(c.uses, k)
+	}
+	if len(c.errs) > 0 {
+		delete(c.errs, k)
+	}
+	if len(c.lazy) > 0 {
+		delete(c.lazy, k)
+	}
+	if len(c.pins) > 0 {
+		c.unpin(k)
+	}
+	onEvict := c.onEvicted
+	if f, ok := c.evictFuncs[k]; ok {
+		onEvict = f
+		delete(c.evictFuncs, k)
+	}
+	if onEvict != nil {
 		if v, ok := c.items[k]; ok {
+			c.trackExpiring(v.Expiration, 0)
 			delete(c.items, k)
-			return v.Object, true
+			return v.Object, onEvict
 		}
 	}
+	c.trackExpiring(c.items[k].Expiration, 0)
 	delete(c.items, k)
 
-	return c.zero(), false
+	return c.zero(), nil
+}
+
+// trackExpiring updates the number of items with an expiration after an item's
+// expiration changed from old to new; use 0 for items that don't exist. The
+// lock must be held.
+func (c *cache[K, V]) trackExpiring(old, new int64) {
+	if old > 0 {
+		c.expiring--
+	}
+	if new > 0 {
+		c.expiring++
+	}
+}
+
+// now gets the current time for expiration checks, or 0 if there are no items
+// that can expire; the lock must be held.
+func (c *cache[K, V]) now() int64 {
+	if c.expiring == 0 {
+		return 0
+	}
+	return c.nanotime()
+}
+
+// read applies the read pipeline to v; the lock must be held.
+func (c *cache[K, V]) read(v V) V {
+	for _, f := range c.readPipeline {
+		v = f(v)
+	}
+	return v
+}
+
+// readValue is like read(), but acquires the lock.
+func (c *cache[K, V]) readValue(v V) V {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.read(v)
+}
+
+// key normalizes the key with the function set with KeyNormalizer(); the lock
+// must be held.
+func (c *cache[K, V]) key(k K) K {
+	if c.normalize == nil {
+		return k
+	}
+	return c.normalize(k)
+}
+
+// normalizeKey is like key(), but acquires the lock.
+func (c *cache[K, V]) normalizeKey(k K) K {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.key(k)
+}
+
+// checkDuration returns an error for durations less than NoExpiration, or panics
+// in strict mode.
+//
+// Durations like this are almost always a mistake, such as passing seconds
+// where nanoseconds are expected.
+func (c *cache[K, V]) checkDuration(op string, d time.Duration) error {
+	if d >= NoExpiration {
+		return nil
+	}
+	err := fmt.Errorf("%s: invalid duration %s; use NoExpiration for items that never expire", op, d)
+	if c.strict {
+		panic(err)
+	}
+	return err
+}
+
+// sortEvicted sorts the evicted items with the function set with
+// EvictionOrder(), if any; the lock must be held.
+func (c *cache[K, V]) sortEvicted(items []keyAndValue[K, V]) {
+	if c.evictOrder != nil && len(items) > 1 {
+		sort.Slice(items, func(i, j int) bool { return c.evictOrder(items[i].key, items[j].key) })
+	}
 }
 
 func (c *cache[K, V]) zero() V {
@@ -548,36 +2661,125 @@ func (c *cache[K, V]) zero() V {
 }
 
 type keyAndValue[K comparable, V any] struct {
-	key   K
-	value V
+	key     K
+	value   V
+	onEvict func(K, V)
 }
 
 type janitor[K comparable, V any] struct {
-	Interval time.Duration
-	stop     chan bool
+	Interval   time.Duration
+	stop       chan bool
+	reschedule chan struct{}
+
+	// Protected by cache.mu
+	running     bool
+	lastRun     time.Time
+	lastDeleted int
+	lockTime    time.Duration
+	interval    time.Duration
+	threshold   time.Duration
+	maxInterval time.Duration
+	notify      func(interval, took time.Duration)
+	delay       time.Duration
+	align       time.Duration
 }
 
 func (j *janitor[K, V]) run(c *cache[K, V]) {
-	ticker := time.NewTicker(j.Interval)
+	timer := time.NewTimer(j.next(c))
 	for {
 		select {
-		case <-ticker.C:
-			c.DeleteExpired()
+		case <-timer.C:
+			n, took := c.deleteExpired(true)
+			c.runExpiring()
+			c.mu.Lock()
+			j.lastRun, j.lastDeleted, j.lockTime = time.Now(), n, took
+			j.delay = 0
+			interval, changed := j.adapt(took)
+			notify := j.notify
+			c.mu.Unlock()
+			if changed && notify != nil {
+				notify(interval, took)
+			}
+			timer.Reset(j.next(c))
+		case <-j.reschedule:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(j.next(c))
 		case <-j.stop:
-			ticker.Stop()
+			timer.Stop()
+			c.mu.Lock()
+			j.running = false
+			c.mu.Unlock()
 			return
 		}
 	}
 }
 
+// next gets the time until the next run.
+func (j *janitor[K, V]) next(c *cache[K, V]) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := j.interval
+	if j.delay > 0 {
+		d = j.delay
+	}
+	return nextRun(time.Now(), d, j.align)
+}
+
+// nextRun gets the time until the next run if it should run after d, aligned to
+// multiples of align.
+func nextRun(now time.Time, d, align time.Duration) time.Duration {
+	if align <= 0 {
+		return d
+	}
+	t := now.Add(d).Truncate(align)
+	if !t.After(now) {
+		t = t.Add(align)
+	}
+	return t.Sub(now)
+}
+
+// adapt the interval based on how long the last run took; the cache lock must
+// be held.
+func (j *janitor[K, V]) adapt(took time.Duration) (time.Duration, bool) {
+	cur := j.interval
+	switch {
+	case j.threshold <= 0:
+		j.interval = j.Interval
+	case took > j.threshold && cur < j.maxInterval:
+		j.interval = cur * 2
+		if j.interval > j.maxInterval {
+			j.interval = j.maxInterval
+		}
+	case took <= j.threshold/2 && cur > j.Interval:
+		j.interval = cur / 2
+		if j.interval < j.Interval {
+			j.interval = j.Interval
+		}
+	}
+	return j.interval, j.interval != cur
+}
+
 func stopJanitor[K comparable, V any](c *Cache[K, V]) {
-	c.janitor.stop <- true
+	if c.janitor != nil {
+		c.janitor.stop <- true
+	}
+	c.ExpiryNotifications(0)
+	c.CoarseTime(0)
+	c.KeepHistory(0, 0)
 }
 
 func runJanitor[K comparable, V any](c *cache[K, V], ci time.Duration) {
 	j := &janitor[K, V]{
-		Interval: ci,
-		stop:     make(chan bool),
+		Interval:   ci,
+		stop:       make(chan bool),
+		reschedule: make(chan struct{}, 1),
+		running:    true,
+		interval:   ci,
 	}
 	c.janitor = j
 	go j.run(c)
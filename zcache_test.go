@@ -2,11 +2,16 @@ package zcache
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -217,6 +222,32 @@ func TestTouch(t *testing.T) {
 	}
 }
 
+func TestTouchNoExpiration(t *testing.T) {
+	tc := New[string, string](DefaultExpiration, 0)
+
+	for _, d := range []time.Duration{NoExpiration, -5 * time.Second} {
+		tc.SetWithExpire("a", "b", time.Minute)
+		if _, ok := tc.TouchWithExpire("a", d); !ok {
+			t.Fatalf("%s: !ok", d)
+		}
+		v, exp, ok := tc.GetWithExpire("a")
+		if !ok || v != "b" || !exp.IsZero() {
+			t.Errorf("%s: %q %s %t", d, v, exp, ok)
+		}
+		if n := tc.ItemCount(); n != 1 {
+			t.Errorf("%s: ItemCount %d", d, n)
+		}
+	}
+
+	tc.Strict(true)
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic in strict mode")
+		}
+	}()
+	tc.TouchWithExpire("a", -5*time.Second)
+}
+
 func TestGetWithExpire(t *testing.T) {
 	tc := New[string, any](DefaultExpiration, 0)
 
@@ -459,6 +490,54 @@ func TestModify(t *testing.T) {
 	}
 }
 
+func TestModifyErr(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+	tc.Set("one", 1)
+
+	v, err := tc.ModifyErr("one", func(v int) (int, error) { return v + 1, nil })
+	if v != 2 || err != nil {
+		t.Error(v, err)
+	}
+
+	errNeg := errors.New("negative")
+	v, err = tc.ModifyErr("one", func(v int) (int, error) { return -1, errNeg })
+	if v != 0 || err != errNeg {
+		t.Error(v, err)
+	}
+	if v, _ := tc.Get("one"); v != 2 {
+		t.Error(v)
+	}
+
+	_, err = tc.ModifyErr("doesntexist", func(v int) (int, error) {
+		t.Error("should not be called")
+		return 0, nil
+	})
+	if have, want := fmt.Sprint(err), "zcache.ModifyErr: item doesntexist doesn't exist"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestModifyOrSet(t *testing.T) {
+	tc := New[string, int](DefaultExpiration, 0)
+
+	inc := func(v int) int { return v + 1 }
+	if v := tc.ModifyOrSet("one", inc, 1); v != 1 {
+		t.Error(v)
+	}
+	if v := tc.ModifyOrSet("one", inc, 1); v != 2 {
+		t.Error(v)
+	}
+	if v, _ := tc.Get("one"); v != 2 {
+		t.Error(v)
+	}
+
+	tc.SetWithExpire("expired", 5, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if v := tc.ModifyOrSet("expired", inc, 1); v != 1 {
+		t.Error(v)
+	}
+}
+
 func TestModifyIncrement(t *testing.T) {
 	tc := New[string, int](DefaultExpiration, 0)
 	tc.Set("one", 1)
@@ -599,6 +678,43 @@ func TestFinal(t *testing.T) {
 	}
 }
 
+func TestFinalNoJanitor(t *testing.T) {
+	running := func() int {
+		s := make([]byte, 1<<16)
+		s = s[:runtime.Stack(s, true)]
+		n := 0
+		for _, f := range []string{"CoarseTime.func", "KeepHistory.func", "(*expiryNotifier[...]).run"} {
+			if bytes.Contains(s, []byte(f)) {
+				n++
+			}
+		}
+		return n
+	}
+
+	func() {
+		tc := New[string, any](NoExpiration, 0)
+		tc.CoarseTime(time.Millisecond)
+		tc.KeepHistory(1, time.Millisecond)
+		tc.ExpiryNotifications(1)
+		for i := 0; i < 100 && running() < 3; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		if n := running(); n != 3 {
+			t.Fatalf("%d goroutines before GC", n)
+		}
+		runtime.KeepAlive(tc)
+	}()
+
+	// The finalizer runs in its own goroutine after the GC.
+	for i := 0; i < 100 && running() > 0; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if n := running(); n != 0 {
+		t.Fatalf("%d goroutines after GC", n)
+	}
+}
+
 func TestRename(t *testing.T) {
 	tc := New[string, int](NoExpiration, 0)
 	tc.Set("foo", 3)
@@ -626,3 +742,1480 @@ func TestRename(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestSetWithEvict(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var global, local []string
+	tc.OnEvicted(func(k string, v int) { global = append(global, fmt.Sprintf("%s=%d", k, v)) })
+	f := func(k string, v int) { local = append(local, fmt.Sprintf("%s=%d", k, v)) }
+
+	tc.SetWithEvict("a", 1, DefaultExpiration, f)
+	tc.SetWithEvict("b", 2, DefaultExpiration, f)
+	tc.SetWithEvict("c", 3, DefaultExpiration, f)
+	tc.SetWithEvict("exp", 4, 1, f)
+	tc.Set("d", 4)
+
+	tc.Delete("a")
+	tc.Pop("b")
+	tc.Set("c", 5) // Replaced, so callback is discarded.
+	tc.Delete("c")
+	tc.Delete("d")
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+
+	if h := fmt.Sprintf("%v", local); h != "[a=1 b=2 exp=4]" {
+		t.Errorf("local: %s", h)
+	}
+	if h := fmt.Sprintf("%v", global); h != "[c=5 d=4]" {
+		t.Errorf("global: %s", h)
+	}
+
+	local, global = nil, nil
+	tc.SetWithEvict("a", 1, DefaultExpiration, f)
+	tc.Rename("a", "b")
+	tc.SetWithEvict("c", 2, DefaultExpiration, f)
+	tc.Set("d", 3)
+	tc.DeleteFunc(func(k string, _ Item[int]) (bool, bool) { return k == "b", false })
+	tc.DeleteAll()
+
+	if h := fmt.Sprintf("%v", local); h != "[b=1 c=2]" {
+		t.Errorf("local: %s", h)
+	}
+	if h := fmt.Sprintf("%v", global); h != "[d=3]" {
+		t.Errorf("global: %s", h)
+	}
+}
+
+func TestExpireOnAccess(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	var evicted []string
+	tc.OnEvicted(func(k string, v int) { evicted = append(evicted, k) })
+
+	tc.SetWithExpire("a", 1, 1)
+	time.Sleep(time.Millisecond)
+	tc.Get("a")
+	if len(evicted) != 0 || tc.ItemCount() != 1 {
+		t.Fatalf("evicted: %v; count: %d", evicted, tc.ItemCount())
+	}
+
+	tc.ExpireOnAccess(true)
+	for _, f := range []func(){
+		func() { tc.Get("a") },
+		func() { tc.GetWithExpire("a") },
+		func() { tc.Items() },
+		func() { tc.Keys() },
+	} {
+		evicted = nil
+		tc.SetWithExpire("a", 1, 1)
+		tc.Set("b", 2)
+		time.Sleep(time.Millisecond)
+		f()
+		if fmt.Sprintf("%v", evicted) != "[a]" || tc.ItemCount() != 1 {
+			t.Fatalf("evicted: %v; count: %d", evicted, tc.ItemCount())
+		}
+	}
+}
+
+func TestGetFresh(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+
+	v, err := tc.GetFresh("a", func() (int, error) { return 2, nil })
+	if err != nil || v != 2 {
+		t.Errorf("GetFresh: %d, %v", v, err)
+	}
+	if v, _ := tc.Get("a"); v != 2 {
+		t.Errorf("Get: %d", v)
+	}
+
+	v, err = tc.GetFresh("a", func() (int, error) { return 3, fmt.Errorf("oh noes") })
+	if err == nil || v != 0 {
+		t.Errorf("GetFresh: %d, %v", v, err)
+	}
+	if v, _ := tc.Get("a"); v != 2 {
+		t.Errorf("Get: %d", v)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := tc.Refresh("a", func() (int, error) {
+				<-start
+				atomic.AddInt32(&calls, 1)
+				return 2, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// Wait until all goroutines are waiting for the same call.
+	for i := 0; ; i++ {
+		tc.flights.mu.Lock()
+		c := tc.flights.m["a"]
+		joined := c != nil && c.dups == 9
+		tc.flights.mu.Unlock()
+		if joined {
+			break
+		}
+		if i > 1000 {
+			t.Fatal("goroutines didn't join the same call")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The old value is still there while refreshing.
+	if v, ok := tc.Get("a"); !ok || v != 1 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+	close(start)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("calls: %d", calls)
+	}
+	if v, ok := tc.Get("a"); !ok || v != 2 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+}
+
+func TestItemsWhere(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.Set("c", 3)
+	tc.SetWithExpire("exp", 4, 1)
+	time.Sleep(time.Millisecond)
+
+	have := tc.ItemsWhere(func(k string, v Item[int]) bool { return v.Object > 1 })
+	want := map[string]Item[int]{"b": {Object: 2}, "c": {Object: 3}}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+
+	have2 := MapItems(tc, func(k string, v int) string { return k + strconv.Itoa(v) })
+	want2 := map[string]string{"a": "a1", "b": "b2", "c": "c3"}
+	if !reflect.DeepEqual(have2, want2) {
+		t.Errorf("\nhave: %v\nwant: %v", have2, want2)
+	}
+}
+
+func TestOnExpiring(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var expiring []string
+	tc.OnExpiring(20*time.Millisecond, func(k string, v int) {
+		expiring = append(expiring, fmt.Sprintf("%s=%d", k, v))
+	})
+
+	tc.SetWithExpire("a", 1, 10*time.Millisecond)
+	tc.SetWithExpire("b", 2, time.Hour)
+	tc.Set("c", 3)
+	tc.runExpiring()
+	tc.runExpiring()
+	if h := fmt.Sprintf("%v", expiring); h != "[a=1]" {
+		t.Errorf("expiring: %s", h)
+	}
+
+	// Called again when the expiry changes.
+	tc.SetWithExpire("a", 4, 15*time.Millisecond)
+	tc.runExpiring()
+	if h := fmt.Sprintf("%v", expiring); h != "[a=1 a=4]" {
+		t.Errorf("expiring: %s", h)
+	}
+
+	tc.OnExpiring(0, nil)
+	tc.SetWithExpire("a", 5, 10*time.Millisecond)
+	tc.runExpiring()
+	if h := fmt.Sprintf("%v", expiring); h != "[a=1 a=4]" {
+		t.Errorf("expiring: %s", h)
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	t.Run("concurrent", func(t *testing.T) {
+		var (
+			calls int32
+			wg    sync.WaitGroup
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				v := tc.GetOrSet("a", func() (int, time.Duration) {
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&calls, 1)
+					return 1, DefaultExpiration
+				})
+				if v != 1 {
+					t.Errorf("value: %d", v)
+				}
+			}()
+		}
+		wg.Wait()
+		if calls != 1 {
+			t.Errorf("calls: %d", calls)
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		v := tc.GetOrSet("b", func() (int, time.Duration) { return 2, time.Hour })
+		_, exp, ok := tc.GetWithExpire("b")
+		if v != 2 || !ok || time.Until(exp) < 59*time.Minute {
+			t.Errorf("%d %t %s", v, ok, exp)
+		}
+	})
+
+	t.Run("dont cache", func(t *testing.T) {
+		v := tc.GetOrSet("c", func() (int, time.Duration) { return 3, DontCache })
+		if v != 3 {
+			t.Errorf("value: %d", v)
+		}
+		if _, ok := tc.Get("c"); ok {
+			t.Error("stored in cache")
+		}
+	})
+}
+
+func TestGetOrSetErr(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	errFail := errors.New("fail")
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := tc.GetOrSetErr("a", func() (int, time.Duration, error) {
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&calls, 1)
+				return 1, DefaultExpiration, errFail
+			})
+			if v != 0 || err != errFail {
+				t.Errorf("%d %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("calls: %d", calls)
+	}
+	if _, ok := tc.Get("a"); ok {
+		t.Error("stored after error")
+	}
+
+	v, err := tc.GetOrSetErr("a", func() (int, time.Duration, error) { return 2, DefaultExpiration, nil })
+	if v != 2 || err != nil {
+		t.Errorf("%d %v", v, err)
+	}
+	v, err = tc.GetOrSetErr("a", func() (int, time.Duration, error) { return 3, DefaultExpiration, errFail })
+	if v != 2 || err != nil {
+		t.Errorf("%d %v", v, err)
+	}
+}
+
+func TestGetOrSetContext(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var (
+		calls   int32
+		release = make(chan struct{})
+		started = make(chan struct{})
+	)
+	load := func(ctx context.Context) (int, time.Duration, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return 1, DefaultExpiration, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		_, err := tc.GetOrSetContext(ctx, "a", load)
+		errCh <- err
+	}()
+	<-started
+
+	valCh := make(chan int)
+	go func() {
+		v, err := tc.GetOrSetContext(context.Background(), "a", load)
+		if err != nil {
+			t.Error(err)
+		}
+		valCh <- v
+	}()
+
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error: %v", err)
+	}
+	close(release)
+	if v := <-valCh; v != 1 {
+		t.Errorf("value: %d", v)
+	}
+	if v, ok := tc.Get("a"); v != 1 || !ok {
+		t.Errorf("not stored: %d %t", v, ok)
+	}
+	if calls != 1 {
+		t.Errorf("calls: %d", calls)
+	}
+}
+
+func TestSetWithMaxUses(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	var evicted []string
+	tc.OnEvicted(func(k string, v int) { evicted = append(evicted, k) })
+
+	tc.SetWithMaxUses("a", 1, DefaultExpiration, 2)
+	if v, ok := tc.Get("a"); !ok || v != 1 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+	if v, _, ok := tc.GetWithExpire("a"); !ok || v != 1 {
+		t.Errorf("GetWithExpire: %d, %t", v, ok)
+	}
+	if v, ok := tc.Get("a"); ok || v != 0 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+	if fmt.Sprintf("%v", evicted) != "[a]" {
+		t.Errorf("evicted: %v", evicted)
+	}
+
+	// Replacing the item removes the limit.
+	tc.SetWithMaxUses("b", 1, DefaultExpiration, 1)
+	tc.Set("b", 2)
+	tc.Get("b")
+	if _, ok := tc.Get("b"); !ok {
+		t.Error("b was deleted")
+	}
+
+	// Concurrent access
+	var (
+		wg   sync.WaitGroup
+		hits int32
+	)
+	tc.SetWithMaxUses("c", 1, DefaultExpiration, 5)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := tc.Get("c"); ok {
+				atomic.AddInt32(&hits, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if hits != 5 {
+		t.Errorf("hits: %d", hits)
+	}
+}
+
+func TestItemsAll(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.SetWithExpire("exp", 2, 1)
+	time.Sleep(time.Millisecond)
+
+	keys := tc.KeysAll()
+	sort.Strings(keys)
+	if fmt.Sprintf("%v", keys) != "[a exp]" {
+		t.Errorf("%v", keys)
+	}
+
+	items := tc.ItemsAll()
+	if len(items) != 2 || len(items) != tc.ItemCount() {
+		t.Fatalf("%v", items)
+	}
+	if items["a"].Expired() || !items["exp"].Expired() {
+		t.Errorf("%v", items)
+	}
+}
+
+func TestJanitorStatus(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	if running, _, _ := tc.JanitorStatus(); running {
+		t.Fatal("running")
+	}
+
+	tc = New[string, int](NoExpiration, 20*time.Millisecond)
+	tc.SetWithExpire("a", 1, 1)
+	tc.SetWithExpire("b", 1, 1)
+	tc.Set("c", 1)
+	running, lastRun, lastDeleted := tc.JanitorStatus()
+	if !running || !lastRun.IsZero() || lastDeleted != 0 {
+		t.Fatalf("%t %s %d", running, lastRun, lastDeleted)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	running, lastRun, lastDeleted = tc.JanitorStatus()
+	if !running || time.Since(lastRun) > 20*time.Millisecond || lastDeleted != 2 {
+		t.Fatalf("%t %s %d", running, lastRun, lastDeleted)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	if err := tc.AddWithExpire("a", 1, -5); err == nil {
+		t.Error("no error from Add")
+	}
+	tc.Set("a", 1)
+	if err := tc.ReplaceWithExpire("a", 1, -5); err == nil {
+		t.Error("no error from Replace")
+	}
+	if err := tc.AddWithExpire("b", 1, NoExpiration); err != nil {
+		t.Error(err)
+	}
+
+	// Treated as NoExpiration.
+	tc.SetWithExpire("c", 1, -5)
+	if _, exp, ok := tc.GetWithExpire("c"); !ok || !exp.IsZero() {
+		t.Errorf("%t %s", ok, exp)
+	}
+
+	tc.Strict(true)
+	for _, f := range []func(){
+		func() { tc.SetWithExpire("c", 1, -5) },
+		func() { tc.AddWithExpire("d", 1, -5) },
+		func() { tc.TouchWithExpire("c", -5) },
+		func() { tc.SetUntil("e", 1, nil, -5) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("no panic")
+				}
+			}()
+			f()
+		}()
+	}
+
+	// Make sure the lock was released after the panics.
+	done := make(chan struct{})
+	go func() {
+		tc.Get("c")
+		tc.Set("c", 2)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock not released")
+	}
+}
+
+func TestKeyNormalizer(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("Before", 1)
+
+	var evicted []string
+	tc.OnEvicted(func(k string, _ int) { evicted = append(evicted, k) })
+	tc.KeyNormalizer(func(k string) string { return strings.ToLower(strings.TrimSpace(k)) })
+
+	tc.Set(" Foo", 1)
+	if v, ok := tc.Get("FOO "); !ok || v != 1 {
+		t.Fatalf("%v %v", v, ok)
+	}
+	if err := tc.Add("foo", 2); err == nil {
+		t.Error("no error from Add")
+	}
+	if v, _ := tc.Modify("fOO", func(v int) int { return v + 1 }); v != 2 {
+		t.Error(v)
+	}
+	if v := tc.GetOrSet("FOO", func() (int, time.Duration) { return 3, 0 }); v != 2 {
+		t.Error(v)
+	}
+	if !tc.Rename("Foo", "BAR") {
+		t.Fatal("rename failed")
+	}
+	tc.Delete("Bar")
+	if want := []string{"bar"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("\nhave: %v\nwant: %v", evicted, want)
+	}
+
+	// Existing keys are left alone.
+	if _, ok := tc.Get("Before"); ok {
+		t.Error("Before found")
+	}
+	if have := tc.Keys(); !reflect.DeepEqual(have, []string{"Before"}) {
+		t.Error(have)
+	}
+}
+
+func TestReadPipeline(t *testing.T) {
+	tc := New[string, string](NoExpiration, 0)
+	tc.Set("a", " secret ")
+	tc.SetWithMaxUses("b", "once", NoExpiration, 1)
+	tc.ReadPipeline(strings.TrimSpace, strings.ToUpper)
+
+	if v, _ := tc.Get("a"); v != "SECRET" {
+		t.Errorf("Get: %q", v)
+	}
+	if v, _, _ := tc.GetWithExpire("a"); v != "SECRET" {
+		t.Errorf("GetWithExpire: %q", v)
+	}
+	if v, _ := tc.Get("b"); v != "ONCE" {
+		t.Errorf("Get with max uses: %q", v)
+	}
+	if v := tc.Items()["a"].Object; v != "SECRET" {
+		t.Errorf("Items: %q", v)
+	}
+	if v := tc.ItemsAll()["a"].Object; v != " secret " {
+		t.Errorf("ItemsAll: %q", v)
+	}
+	if v, _ := tc.Modify("a", func(v string) string { return v + "!" }); v != "SECRET !" {
+		t.Errorf("Modify: %q", v)
+	}
+
+	tc.ReadPipeline()
+	if v, _ := tc.Pop("a"); v != " secret !" {
+		t.Errorf("Pop: %q", v)
+	}
+}
+
+func TestReadPipelineLoaders(t *testing.T) {
+	tc := New[string, string](NoExpiration, 0)
+	tc.ReadPipeline(strings.ToUpper)
+
+	if v := tc.GetOrSet("a", func() (string, time.Duration) { return "secret", 0 }); v != "SECRET" {
+		t.Errorf("GetOrSet: %q", v)
+	}
+	if v, _ := tc.GetOrSetErr("b", func() (string, time.Duration, error) { return "secret", 0, nil }); v != "SECRET" {
+		t.Errorf("GetOrSetErr: %q", v)
+	}
+	v, _ := tc.GetOrSetContext(context.Background(), "c", func(context.Context) (string, time.Duration, error) {
+		return "secret", 0, nil
+	})
+	if v != "SECRET" {
+		t.Errorf("GetOrSetContext: %q", v)
+	}
+	if v, _ := tc.GetFresh("d", func() (string, error) { return "secret", nil }); v != "SECRET" {
+		t.Errorf("GetFresh: %q", v)
+	}
+	if v := tc.GetOrSet("e", func() (string, time.Duration) { return "secret", DontCache }); v != "SECRET" {
+		t.Errorf("GetOrSet with DontCache: %q", v)
+	}
+
+	p := tc.Promise("f")
+	p.Resolve("secret", DefaultExpiration)
+	if v, _ := p.Wait(context.Background()); v != "SECRET" {
+		t.Errorf("Promise: %q", v)
+	}
+
+	for _, k := range []string{"a", "b", "c", "d", "f"} {
+		if v := tc.ItemsAll()[k].Object; v != "secret" {
+			t.Errorf("%s stored as %q", k, v)
+		}
+	}
+}
+
+func TestBindContext(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.Set("c", 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tc.BindContext(ctx, "a", "b")
+	tc.BindContext(context.Background(), "c")
+
+	if n := tc.ItemCount(); n != 3 {
+		t.Fatal(n)
+	}
+	cancel()
+	for i := 0; tc.ItemCount() != 1; i++ {
+		if i > 100 {
+			t.Fatalf("keys not deleted: %v", tc.Keys())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := tc.Get("c"); !ok {
+		t.Error("c deleted")
+	}
+}
+
+func TestRevalidate(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+
+	if tc.Revalidate("a", func(int) (bool, time.Duration) {
+		t.Error("called for unset key")
+		return true, 0
+	}) {
+		t.Error("returned true for unset key")
+	}
+
+	tc.SetWithExpire("a", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if !tc.Revalidate("a", func(v int) (bool, time.Duration) { return v == 1, time.Hour }) {
+		t.Error("returned false")
+	}
+	if v, exp, ok := tc.GetWithExpire("a"); v != 1 || !ok || time.Until(exp) < 59*time.Minute {
+		t.Error(v, exp, ok)
+	}
+
+	if !tc.Revalidate("a", func(v int) (bool, time.Duration) { return true, NoExpiration }) {
+		t.Error("returned false")
+	}
+	if _, exp, _ := tc.GetWithExpire("a"); !exp.IsZero() {
+		t.Error(exp)
+	}
+
+	// Set while running check.
+	tc.Revalidate("a", func(v int) (bool, time.Duration) {
+		tc.Set("a", 2)
+		return false, 0
+	})
+	if v, ok := tc.Get("a"); v != 2 || !ok {
+		t.Error(v, ok)
+	}
+
+	var evicted []string
+	tc.OnEvicted(func(k string, _ int) { evicted = append(evicted, k) })
+	if tc.Revalidate("a", func(v int) (bool, time.Duration) { return false, 0 }) {
+		t.Error("returned true")
+	}
+	if _, ok := tc.Get("a"); ok || len(evicted) != 1 {
+		t.Error(evicted)
+	}
+}
+
+func TestRevalidateWritten(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.CoarseTime(time.Hour)
+
+	// Set with the same expiration while running check.
+	tc.SetWithExpire("a", 1, time.Minute)
+	tc.Revalidate("a", func(v int) (bool, time.Duration) {
+		tc.SetWithExpire("a", 2, time.Minute)
+		return false, 0
+	})
+	if v, ok := tc.Get("a"); v != 2 || !ok {
+		t.Error(v, ok)
+	}
+
+	tc.Set("b", 1)
+	tc.Revalidate("b", func(v int) (bool, time.Duration) {
+		tc.Set("b", 2)
+		return false, 0
+	})
+	if v, ok := tc.Get("b"); v != 2 || !ok {
+		t.Error(v, ok)
+	}
+
+	tc.Set("c", 1)
+	tc.Revalidate("c", func(v int) (bool, time.Duration) {
+		tc.Modify("c", func(v int) int { return v + 1 })
+		return false, 0
+	})
+	if v, ok := tc.Get("c"); v != 2 || !ok {
+		t.Error(v, ok)
+	}
+
+	tc.SetLazy("lazy", func() int { return 10 }, DefaultExpiration)
+	if !tc.Revalidate("lazy", func(v int) (bool, time.Duration) { return v == 10, NoExpiration }) {
+		t.Error("lazy value not resolved")
+	}
+}
+
+func TestRevalidateConcurrent(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+
+	var (
+		wg      sync.WaitGroup
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tc.Revalidate("a", func(int) (bool, time.Duration) {
+			atomic.AddInt32(&calls, 1)
+			close(started)
+			<-release
+			return true, NoExpiration
+		})
+	}()
+	<-started
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !tc.Revalidate("a", func(int) (bool, time.Duration) {
+				atomic.AddInt32(&calls, 1)
+				return false, 0
+			}) {
+				t.Error("returned false")
+			}
+		}()
+	}
+	for {
+		tc.revalidations.mu.Lock()
+		n := tc.revalidations.m["a"].dups
+		tc.revalidations.mu.Unlock()
+		if n == 5 {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("check called %d times", calls)
+	}
+}
+
+func TestExpireMany(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.Set("c", 3)
+
+	if n := tc.ExpireMany([]string{"a", "b", "x"}, time.Hour); n != 2 {
+		t.Fatal(n)
+	}
+	for _, k := range []string{"a", "b"} {
+		if _, exp, _ := tc.GetWithExpire(k); time.Until(exp) < 59*time.Minute {
+			t.Errorf("%s: %s", k, exp)
+		}
+	}
+	if _, exp, _ := tc.GetWithExpire("c"); time.Until(exp) > time.Minute {
+		t.Errorf("c: %s", exp)
+	}
+
+	if n := tc.ExpireMany([]string{"c"}, NoExpiration); n != 1 {
+		t.Fatal(n)
+	}
+	if _, exp, ok := tc.GetWithExpire("c"); !ok || !exp.IsZero() {
+		t.Errorf("c: %s %t", exp, ok)
+	}
+}
+
+func TestEvictionOrder(t *testing.T) {
+	tc := New[int, int](NoExpiration, 0)
+	var evicted []int
+	tc.OnEvicted(func(k, _ int) { evicted = append(evicted, k) })
+	tc.EvictionOrder(func(a, b int) bool { return a > b })
+
+	for i := 0; i < 20; i++ {
+		tc.SetWithExpire(i, i, time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	for i := range evicted {
+		if evicted[i] != 19-i {
+			t.Fatalf("wrong order: %v", evicted)
+		}
+	}
+	if len(evicted) != 20 {
+		t.Fatal(len(evicted))
+	}
+
+	evicted = evicted[:0]
+	for i := 0; i < 20; i++ {
+		tc.Set(i, i)
+	}
+	tc.DeleteAll()
+	for i := range evicted {
+		if evicted[i] != 19-i {
+			t.Fatalf("wrong order: %v", evicted)
+		}
+	}
+	if len(evicted) != 20 {
+		t.Fatal(len(evicted))
+	}
+}
+
+func TestOnEvictedBatch(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var batches []map[string]int
+	tc.OnEvictedBatch(func(m map[string]int) { batches = append(batches, m) })
+	var evicted int
+	tc.OnEvicted(func(string, int) { evicted++ })
+
+	tc.DeleteExpired()
+	if len(batches) != 0 {
+		t.Fatal("called without evicted items")
+	}
+
+	tc.SetWithExpire("a", 1, time.Nanosecond)
+	tc.SetWithEvict("b", 2, time.Nanosecond, func(string, int) {})
+	tc.Set("c", 3)
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	tc.Delete("c")
+	tc.Set("d", 4)
+	tc.DeleteAll()
+
+	if have, want := fmt.Sprint(batches), "[map[a:1 b:2] map[d:4]]"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	if evicted != 3 {
+		t.Errorf("evicted: %d", evicted)
+	}
+}
+
+func TestAddEvictionHandler(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var calls []string
+	tc.OnEvicted(func(k string, _ int) { calls = append(calls, "OnEvicted "+k) })
+	rm1 := tc.AddEvictionHandler(func(k string, _ int) { calls = append(calls, "h1 "+k) })
+	rm2 := tc.AddEvictionHandler(func(k string, _ int) { calls = append(calls, "h2 "+k) })
+
+	tc.Set("a", 1)
+	tc.Delete("a")
+	rm1()
+	rm1()
+	tc.Set("b", 1)
+	tc.Delete("b")
+	tc.OnEvicted(nil)
+	tc.Set("c", 1)
+	tc.Delete("c")
+	rm2()
+	tc.Set("d", 1)
+	tc.Delete("d")
+
+	want := []string{"OnEvicted a", "h1 a", "h2 a", "OnEvicted b", "h2 b", "h2 c"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("\nhave: %v\nwant: %v", calls, want)
+	}
+	if tc.onEvicted != nil {
+		t.Error("onEvicted not nil")
+	}
+}
+
+func TestExpiringSoon(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("forever", 1)
+	tc.SetWithExpire("expired", 1, time.Nanosecond)
+	tc.SetWithExpire("hour", 1, time.Hour)
+	tc.SetWithExpire("second", 1, time.Second)
+	tc.SetWithExpire("minute", 1, time.Minute)
+	time.Sleep(time.Millisecond)
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{0, nil},
+		{1, []string{"second"}},
+		{2, []string{"second", "minute"}},
+		{10, []string{"second", "minute", "hour"}},
+	}
+	for _, tt := range tests {
+		have := tc.ExpiringSoon(tt.n)
+		if len(have) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("%d\nhave: %v\nwant: %v", tt.n, have, tt.want)
+		}
+	}
+}
+
+func TestSetErr(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	errNotFound := errors.New("not found")
+
+	tc.SetErr("a", errNotFound, DefaultExpiration)
+	if v, err, ok := tc.GetResult("a"); v != 0 || err != errNotFound || !ok {
+		t.Fatalf("%v %v %v", v, err, ok)
+	}
+	if !tc.Rename("a", "b") {
+		t.Fatal("rename")
+	}
+	if _, err, _ := tc.GetResult("b"); err != errNotFound {
+		t.Fatal(err)
+	}
+
+	tc.Set("b", 2)
+	if v, err, ok := tc.GetResult("b"); v != 2 || err != nil || !ok {
+		t.Fatalf("%v %v %v", v, err, ok)
+	}
+
+	tc.SetErr("c", errNotFound, DefaultExpiration)
+	tc.Delete("c")
+	if v, err, ok := tc.GetResult("c"); v != 0 || err != nil || ok {
+		t.Fatalf("%v %v %v", v, err, ok)
+	}
+	if len(tc.errs) != 0 {
+		t.Error(tc.errs)
+	}
+}
+
+func TestJanitorSchedule(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 10, 0, time.UTC)
+	tests := []struct {
+		d, align, want time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Minute, time.Minute, 50 * time.Second},
+		{10 * time.Second, time.Minute, 50 * time.Second},
+		{90 * time.Second, time.Minute, 50 * time.Second},
+		{110 * time.Second, time.Minute, 110 * time.Second},
+		{0, time.Minute, 50 * time.Second},
+	}
+	for _, tt := range tests {
+		if have := nextRun(now, tt.d, tt.align); have != tt.want {
+			t.Errorf("nextRun(%s, %s): have %s; want %s", tt.d, tt.align, have, tt.want)
+		}
+	}
+
+	tc := New[string, int](NoExpiration, 10*time.Millisecond)
+	tc.JanitorSchedule(100*time.Millisecond, 0)
+	time.Sleep(30 * time.Millisecond)
+	if _, lastRun, _ := tc.JanitorStatus(); !lastRun.IsZero() {
+		t.Error("janitor ran before delay")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, lastRun, _ := tc.JanitorStatus(); lastRun.IsZero() {
+		t.Error("janitor didn't run after delay")
+	}
+}
+
+func TestJanitorBackoff(t *testing.T) {
+	j := &janitor[string, int]{Interval: time.Second, interval: time.Second,
+		threshold: 10 * time.Millisecond, maxInterval: 5 * time.Second}
+
+	tests := []struct {
+		took    time.Duration
+		want    time.Duration
+		changed bool
+	}{
+		{time.Millisecond, time.Second, false},
+		{20 * time.Millisecond, 2 * time.Second, true},
+		{20 * time.Millisecond, 4 * time.Second, true},
+		{20 * time.Millisecond, 5 * time.Second, true},
+		{20 * time.Millisecond, 5 * time.Second, false},
+		{8 * time.Millisecond, 5 * time.Second, false},
+		{time.Millisecond, 2500 * time.Millisecond, true},
+		{time.Millisecond, 1250 * time.Millisecond, true},
+		{time.Millisecond, time.Second, true},
+	}
+	for _, tt := range tests {
+		have, changed := j.adapt(tt.took)
+		if have != tt.want || changed != tt.changed {
+			t.Fatalf("took %s: have %s %t; want %s %t", tt.took, have, changed, tt.want, tt.changed)
+		}
+	}
+
+	j.adapt(20 * time.Millisecond)
+	j.threshold = 0
+	if have, changed := j.adapt(20 * time.Millisecond); have != time.Second || !changed {
+		t.Errorf("%s %t", have, changed)
+	}
+
+	tc := New[string, int](NoExpiration, 10*time.Millisecond)
+	if took, interval := tc.JanitorLockTime(); took != 0 || interval != 10*time.Millisecond {
+		t.Errorf("%s %s", took, interval)
+	}
+	tc.SetWithExpire("a", 1, time.Nanosecond)
+	time.Sleep(30 * time.Millisecond)
+	if took, _ := tc.JanitorLockTime(); took == 0 {
+		t.Error("took is 0")
+	}
+}
+
+func TestModifyMany(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.SetWithExpire("expired", 3, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	have := tc.ModifyMany([]string{"a", "missing", "expired", "b"}, func(k string, v int) int { return v * 10 })
+	want := []ModifyResult[int]{{10, true}, {0, false}, {0, false}, {20, true}}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+	if v, _ := tc.Get("b"); v != 20 {
+		t.Error(v)
+	}
+}
+
+func TestSetName(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	if n := tc.Name(); n != "" {
+		t.Fatal(n)
+	}
+	tc.SetName("test")
+	if n := tc.Name(); n != "test" {
+		t.Fatal(n)
+	}
+
+	// Loaders are run with pprof labels; there's no way to read them back, so
+	// just make sure the loaders still run.
+	if v := tc.GetOrSet("a long key which is truncated", func() (int, time.Duration) { return 1, 0 }); v != 1 {
+		t.Error(v)
+	}
+	if v, err := tc.GetFresh("a", func() (int, error) { return 2, nil }); v != 2 || err != nil {
+		t.Error(v, err)
+	}
+}
+
+func TestSetLazy(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	var calls int32
+	tc.SetLazy("a", func() int {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(5 * time.Millisecond)
+		return 42
+	}, DefaultExpiration)
+	if v := tc.Items()["a"].Object; v != 0 {
+		t.Fatal(v)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := tc.Get("a"); v != 42 || !ok {
+				t.Errorf("%v %v", v, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("called %d times", calls)
+	}
+	if v := tc.Items()["a"].Object; v != 42 {
+		t.Fatal(v)
+	}
+	if len(tc.lazy) != 0 {
+		t.Error(tc.lazy)
+	}
+
+	tc.SetLazy("b", func() int { return 1 }, DefaultExpiration)
+	tc.Set("b", 2)
+	if v, _, _ := tc.GetWithExpire("b"); v != 2 {
+		t.Error(v)
+	}
+}
+
+func TestSetLazyModify(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	lazy := func() int { return 10 }
+
+	tc.SetLazy("a", lazy, DefaultExpiration)
+	if v, ok := tc.Modify("a", func(v int) int { return v + 1 }); !ok || v != 11 {
+		t.Error(v, ok)
+	}
+	tc.SetLazy("b", lazy, DefaultExpiration)
+	if v, err := tc.ModifyErr("b", func(v int) (int, error) { return v + 1, nil }); err != nil || v != 11 {
+		t.Error(v, err)
+	}
+	tc.SetLazy("c", lazy, DefaultExpiration)
+	if r := tc.ModifyMany([]string{"c"}, func(_ string, v int) int { return v + 1 }); r[0].Value != 11 {
+		t.Error(r)
+	}
+	tc.SetLazy("d", lazy, DefaultExpiration)
+	if v := tc.ModifyOrSet("d", func(v int) int { return v + 1 }, 0); v != 11 {
+		t.Error(v)
+	}
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if v, _ := tc.Get(k); v != 11 {
+			t.Errorf("%s: %d", k, v)
+		}
+	}
+
+	// The function can use the cache.
+	tc.Set("base", 5)
+	tc.SetLazy("e", func() int { v, _ := tc.Get("base"); return v * 2 }, DefaultExpiration)
+	if v, ok := tc.Modify("e", func(v int) int { return v + 1 }); !ok || v != 11 {
+		t.Error(v, ok)
+	}
+
+	tc.SetLazy("f", lazy, DefaultExpiration)
+	if v, ok := tc.Pop("f"); !ok || v != 10 {
+		t.Error(v, ok)
+	}
+	tc.SetLazy("g", lazy, DefaultExpiration)
+	if v, _, ok := tc.GetStale("g"); !ok || v != 10 {
+		t.Error(v, ok)
+	}
+	tc.SetLazy("h", lazy, DefaultExpiration)
+	if v, ok := tc.Touch("h"); !ok || v != 10 {
+		t.Error(v, ok)
+	}
+}
+
+func TestDeleteFuncWithin(t *testing.T) {
+	tc := New[int, int](NoExpiration, 0)
+	for i := 0; i < 1000; i++ {
+		tc.Set(i, i)
+	}
+	var evicted int
+	tc.OnEvicted(func(int, int) { evicted++ })
+
+	var (
+		cur     *Cursor[int]
+		deleted int
+		runs    int
+	)
+	for {
+		var m map[int]Item[int]
+		m, cur = tc.DeleteFuncWithin(0, cur, func(k int, _ Item[int]) bool { return k%2 == 0 })
+		deleted += len(m)
+		runs++
+		if cur == nil {
+			break
+		}
+		if runs > 1000 {
+			t.Fatal("too many runs")
+		}
+	}
+	if runs < 2 {
+		t.Errorf("runs: %d", runs)
+	}
+	if deleted != 500 || evicted != 500 {
+		t.Errorf("deleted: %d; evicted: %d", deleted, evicted)
+	}
+	if n := tc.ItemCount(); n != 500 {
+		t.Error(n)
+	}
+
+	m, cur := tc.DeleteFuncWithin(time.Hour, nil, func(int, Item[int]) bool { return true })
+	if len(m) != 500 || cur != nil {
+		t.Errorf("%d %v", len(m), cur)
+	}
+}
+
+func TestKeyFormatter(t *testing.T) {
+	type key struct{ a, b int }
+	tc := New[key, int](NoExpiration, 0)
+	tc.Set(key{1, 2}, 1)
+
+	err := tc.Add(key{1, 2}, 1)
+	if have, want := fmt.Sprint(err), "zcache.Add: item {1 2} already exists"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+
+	tc.KeyFormatter(func(k key) string { return fmt.Sprintf("%d/%d", k.a, k.b) })
+	err = tc.Add(key{1, 2}, 1)
+	if have, want := fmt.Sprint(err), "zcache.Add: item 1/2 already exists"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+	err = tc.Replace(key{3, 4}, 1)
+	if have, want := fmt.Sprint(err), "zcache.Replace: item 3/4 doesn't exist"; have != want {
+		t.Errorf("\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func TestAddGetExisting(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	if item, added, err := tc.AddGetExisting("a", 1, time.Hour); err != nil || !added || item.Object != 0 {
+		t.Fatalf("%v %v %v", item, added, err)
+	}
+	item, added, err := tc.AddGetExisting("a", 2, DefaultExpiration)
+	if err != nil || added || item.Object != 1 || time.Until(time.Unix(0, item.Expiration)) < 59*time.Minute {
+		t.Fatalf("%v %v %v", item, added, err)
+	}
+
+	tc.SetWithExpire("expired", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if item, added, err := tc.AddGetExisting("expired", 2, DefaultExpiration); err != nil || !added {
+		t.Fatalf("%v %v %v", item, added, err)
+	}
+	if v, _ := tc.Get("expired"); v != 2 {
+		t.Error(v)
+	}
+
+	tc.SetLazy("lazy", func() int { return 10 }, DefaultExpiration)
+	if item, added, err := tc.AddGetExisting("lazy", 2, DefaultExpiration); err != nil || added || item.Object != 10 {
+		t.Errorf("lazy: %v %v %v", item, added, err)
+	}
+
+	if _, added, err := tc.AddGetExisting("b", 1, -2); err == nil || added {
+		t.Errorf("invalid duration: %v %v", added, err)
+	}
+	if _, ok := tc.Get("b"); ok {
+		t.Error("b was added")
+	}
+
+	tc.Freeze()
+	if item, added, err := tc.AddGetExisting("a", 2, DefaultExpiration); err != nil || added || item.Object != 1 {
+		t.Errorf("frozen, existing: %v %v %v", item, added, err)
+	}
+	if _, added, err := tc.AddGetExisting("c", 1, DefaultExpiration); err != ErrFrozen || added {
+		t.Errorf("frozen, new: %v %v", added, err)
+	}
+}
+
+func TestGetOrAdd(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	if v := tc.GetOrAdd("a", 1); v != 1 {
+		t.Error(v)
+	}
+	if v := tc.GetOrAdd("a", 2); v != 1 {
+		t.Error(v)
+	}
+
+	tc.SetWithExpire("expired", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if v := tc.GetOrAddWithExpire("expired", 2, time.Hour); v != 2 {
+		t.Error(v)
+	}
+	if _, exp, _ := tc.GetWithExpire("expired"); time.Until(exp) < 59*time.Minute {
+		t.Error(exp)
+	}
+
+	tc.SetLazy("lazy", func() int { return 10 }, DefaultExpiration)
+	if v := tc.GetOrAdd("lazy", 2); v != 10 {
+		t.Error(v)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		seen sync.Map
+	)
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seen.Store(tc.GetOrAdd("b", i), true)
+		}()
+	}
+	wg.Wait()
+	n := 0
+	seen.Range(func(k, v any) bool { n++; return true })
+	if n != 1 {
+		t.Errorf("got %d different values", n)
+	}
+}
+
+func TestSetKeepTTL(t *testing.T) {
+	tc := New[string, int](time.Minute, 0)
+
+	if tc.SetKeepTTL("a", 1) {
+		t.Error("returned true for new item")
+	}
+	if _, exp, _ := tc.GetWithExpire("a"); time.Until(exp) > time.Minute || time.Until(exp) < 59*time.Second {
+		t.Error(exp)
+	}
+
+	tc.SetWithExpire("b", 1, time.Hour)
+	_, want, _ := tc.GetWithExpire("b")
+	if !tc.SetKeepTTL("b", 2) {
+		t.Error("returned false")
+	}
+	if v, exp, _ := tc.GetWithExpire("b"); v != 2 || !exp.Equal(want) {
+		t.Errorf("%v %s; want %s", v, exp, want)
+	}
+
+	tc.SetWithExpire("c", 1, NoExpiration)
+	if !tc.SetKeepTTL("c", 2) {
+		t.Error("returned false")
+	}
+	if v, exp, _ := tc.GetWithExpire("c"); v != 2 || !exp.IsZero() {
+		t.Errorf("%v %s", v, exp)
+	}
+}
+
+func TestItemsMutate(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+
+	for _, m := range []map[string]Item[int]{tc.Items(), tc.ItemsAll(), tc.ItemsWhere(func(string, Item[int]) bool { return true })} {
+		m["a"] = Item[int]{Object: 2}
+		m["b"] = Item[int]{Object: 2}
+		delete(m, "a")
+	}
+	if have := tc.Items(); !reflect.DeepEqual(have, map[string]Item[int]{"a": {Object: 1}}) {
+		t.Error(have)
+	}
+
+	m := tc.DeleteAll()
+	m["c"] = Item[int]{Object: 3}
+	if n := tc.ItemCount(); n != 0 {
+		t.Error(n)
+	}
+}
+
+func TestDefaultValue(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.DefaultValue(-1)
+	tc.Set("zero", 0)
+	tc.SetWithExpire("expired", 1, time.Nanosecond)
+	tc.SetWithMaxUses("once", 1, NoExpiration, 1)
+	time.Sleep(time.Millisecond)
+
+	if v, ok := tc.Get("zero"); v != 0 || !ok {
+		t.Errorf("%v %v", v, ok)
+	}
+	for _, k := range []string{"missing", "expired"} {
+		if v, ok := tc.Get(k); v != -1 || ok {
+			t.Errorf("Get %s: %v %v", k, v, ok)
+		}
+		if v, _, ok := tc.GetWithExpire(k); v != -1 || ok {
+			t.Errorf("GetWithExpire %s: %v %v", k, v, ok)
+		}
+		if v, ok := tc.Pop(k); v != -1 || ok {
+			t.Errorf("Pop %s: %v %v", k, v, ok)
+		}
+	}
+	if v, _, ok := tc.GetStale("missing"); v != -1 || ok {
+		t.Errorf("GetStale: %v %v", v, ok)
+	}
+	tc.Get("once")
+	if v, ok := tc.Get("once"); v != -1 || ok {
+		t.Errorf("Get once: %v %v", v, ok)
+	}
+}
+
+func TestRenameFunc(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+	tc.Set("a", 1)
+	tc.Set("b", 2)
+	tc.SetWithMaxUses("c", 3, NoExpiration, 1)
+	tc.Set("keep", 4)
+	tc.SetWithExpire("expired", 5, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	// a→b and b→c, to make sure it doesn't rename twice.
+	next := map[string]string{"a": "b", "b": "c", "c": "d", "expired": "x"}
+	n := tc.RenameFunc(func(k string) (string, bool) {
+		dst, ok := next[k]
+		return dst, ok
+	})
+	if n != 3 {
+		t.Errorf("renamed %d", n)
+	}
+
+	want := map[string]int{"b": 1, "c": 2, "d": 3, "keep": 4}
+	have := MapItems(tc, func(_ string, v int) int { return v })
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("\nhave: %v\nwant: %v", have, want)
+	}
+
+	// Max uses moved with the item.
+	tc.Get("d")
+	if _, ok := tc.Get("d"); ok {
+		t.Error("d still set")
+	}
+	if _, ok := tc.Get("c"); !ok {
+		t.Error("c not set")
+	}
+}
+
+func TestSetUntil(t *testing.T) {
+	tc := New[string, int](NoExpiration, 0)
+
+	wait := func(k string, want bool) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			if _, ok := tc.Get(k); ok == want {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("%s: want %t", k, want)
+	}
+
+	done := make(chan struct{})
+	tc.SetUntil("a", 1, done, NoExpiration)
+	tc.SetUntil("b", 1, done, NoExpiration)
+	tc.Set("b", 2) // Replaced; shouldn't be deleted.
+	tc.SetUntil("c", 1, done, NoExpiration)
+	tc.Rename("c", "d")
+	close(done)
+	wait("a", false)
+	wait("d", false)
+	if v, ok := tc.Get("b"); !ok || v != 2 {
+		t.Errorf("b: %v %v", v, ok)
+	}
+	if len(tc.pins) != 0 {
+		t.Errorf("pins: %v", tc.pins)
+	}
+
+	done = make(chan struct{})
+	tc.SetUntil("x", 1, done, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := tc.Get("x"); ok {
+		t.Error("x not expired")
+	}
+	tc.Reset()
+	close(done)
+	if len(tc.pins) != 0 {
+		t.Errorf("pins: %v", tc.pins)
+	}
+}
+
+func TestExpiringCount(t *testing.T) {
+	tc := NewFrom(NoExpiration, 0, map[string]Item[int]{
+		"a": {Object: 1},
+		"b": {Object: 2, Expiration: nanotime() + int64(time.Hour)},
+	})
+
+	check := func(want int) {
+		t.Helper()
+		tc.mu.RLock()
+		defer tc.mu.RUnlock()
+		var n int
+		for _, v := range tc.items {
+			if v.Expiration > 0 {
+				n++
+			}
+		}
+		if n != want || tc.expiring != want {
+			t.Fatalf("want %d; counted %d; tracked %d", want, n, tc.expiring)
+		}
+	}
+
+	check(1)
+	tc.Set("a", 1)
+	check(1)
+	tc.SetWithExpire("a", 1, time.Hour)
+	check(2)
+	tc.Set("b", 2)
+	check(1)
+	tc.TouchWithExpire("b", time.Hour)
+	check(2)
+	tc.ExpireMany([]string{"a", "b"}, NoExpiration)
+	check(0)
+	tc.SetWithExpire("c", 3, time.Hour)
+	tc.Rename("c", "a")
+	check(1)
+	tc.Rename("a", "b")
+	check(1)
+	tc.Delete("b")
+	check(0)
+	tc.SetWithExpire("d", 4, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	tc.DeleteExpired()
+	check(0)
+	if _, ok := tc.Get("d"); ok {
+		t.Error("d not deleted")
+	}
+	tc.SetWithExpire("e", 5, time.Hour)
+	tc.Reset()
+	check(0)
+}
@@ -0,0 +1,932 @@
+// Package zcachemock provides a mock zcache.Cacher for tests.
+//
+// The mock records all calls and stores items in a real zcache.Cache, but hits
+// and misses can be programmed per key and items can be evicted at any point,
+// so that code using a cache can be tested without relying on TTLs and sleeps.
+package zcachemock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"zgo.at/zcache/v2"
+)
+
+// Call is a recorded method call.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Cache is a mock cache.
+type Cache[K comparable, V any] struct {
+	cache *zcache.Cache[K, V]
+	mu    sync.Mutex
+	calls []Call
+	hits  map[K]V
+	miss  map[K]struct{}
+	errs  map[string]error
+}
+
+var _ zcache.Cacher[string, any] = &Cache[string, any]{}
+
+// New creates a new mock cache; items never expire unless set with an explicit
+// expiration.
+func New[K comparable, V any]() *Cache[K, V] {
+	return &Cache[K, V]{
+		cache: zcache.New[K, V](zcache.NoExpiration, 0),
+		hits:  make(map[K]V),
+		miss:  make(map[K]struct{}),
+		errs:  make(map[string]error),
+	}
+}
+
+// Calls gets all recorded calls.
+func (c *Cache[K, V]) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := make([]Call, len(c.calls))
+	copy(calls, c.calls)
+	return calls
+}
+
+// ResetCalls clears the recorded calls.
+func (c *Cache[K, V]) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+// Hit makes all lookups for the key return v, regardless of what's in the
+// cache.
+func (c *Cache[K, V]) Hit(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.miss, k)
+	c.hits[k] = v
+}
+
+// Miss makes all lookups for the key fail, regardless of what's in the cache.
+func (c *Cache[K, V]) Miss(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.hits, k)
+	c.miss[k] = struct{}{}
+}
+
+// Clear removes any hit or miss set with Hit() or Miss().
+func (c *Cache[K, V]) Clear(k K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.hits, k)
+	delete(c.miss, k)
+}
+
+// Fail makes the method return err; for example Fail("Add", err). Only methods
+// that return an error can fail. Use a nil error to clear it.
+func (c *Cache[K, V]) Fail(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.errs, method)
+		return
+	}
+	c.errs[method] = err
+}
+
+// Evict expires items and deletes them as the janitor would, calling
+// OnEvicted and OnEvictedBatch and sending expiry notifications. All items are
+// evicted if no keys are given.
+//
+// Any other expired items are deleted as well. This does nothing if the cache
+// is frozen.
+//
+// This is not recorded as a call.
+func (c *Cache[K, V]) Evict(keys ...K) {
+	if len(keys) == 0 {
+		keys = c.cache.KeysAll()
+	}
+	if c.cache.ExpireMany(keys, time.Nanosecond) == 0 {
+		return
+	}
+	// The cache always reads the clock (see CoarseTime()), so the items are
+	// expired once this has passed.
+	time.Sleep(2 * time.Nanosecond)
+	c.cache.DeleteExpired()
+}
+
+func (c *Cache[K, V]) record(method string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+}
+
+// lookup returns the programmed result for k, if any.
+func (c *Cache[K, V]) lookup(k K) (v V, ok bool, programmed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.hits[k]; ok {
+		return v, true, true
+	}
+	if _, ok := c.miss[k]; ok {
+		return v, false, true
+	}
+	return v, false, false
+}
+
+func (c *Cache[K, V]) err(method string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.errs[method]
+}
+
+// Set records the call and forwards it to the cache; see zcache.Cache.Set().
+func (c *Cache[K, V]) Set(k K, v V) {
+	c.record("Set", k, v)
+	c.cache.Set(k, v)
+}
+
+// SetWithExpire records the call and forwards it to the cache; see
+// zcache.Cache.SetWithExpire().
+func (c *Cache[K, V]) SetWithExpire(k K, v V, d time.Duration) {
+	c.record("SetWithExpire", k, v, d)
+	c.cache.SetWithExpire(k, v, d)
+}
+
+// SetKeepTTL records the call and forwards it to the cache; see
+// zcache.Cache.SetKeepTTL().
+func (c *Cache[K, V]) SetKeepTTL(k K, v V) bool {
+	c.record("SetKeepTTL", k, v)
+	return c.cache.SetKeepTTL(k, v)
+}
+
+// TrySet records the call and forwards it to the cache; see
+// zcache.Cache.TrySet().
+func (c *Cache[K, V]) TrySet(k K, v V, d time.Duration) bool {
+	c.record("TrySet", k, v, d)
+	return c.cache.TrySet(k, v, d)
+}
+
+// SetWithEvict records the call and forwards it to the cache; see
+// zcache.Cache.SetWithEvict().
+func (c *Cache[K, V]) SetWithEvict(k K, v V, d time.Duration, onEvict func(K, V)) {
+	c.record("SetWithEvict", k, v, d, onEvict)
+	c.cache.SetWithEvict(k, v, d, onEvict)
+}
+
+// SetWithMaxUses records the call and forwards it to the cache; see
+// zcache.Cache.SetWithMaxUses().
+func (c *Cache[K, V]) SetWithMaxUses(k K, v V, d time.Duration, n int) {
+	c.record("SetWithMaxUses", k, v, d, n)
+	c.cache.SetWithMaxUses(k, v, d, n)
+}
+
+// SetErr records the call and forwards it to the cache; see
+// zcache.Cache.SetErr().
+func (c *Cache[K, V]) SetErr(k K, err error, d time.Duration) {
+	c.record("SetErr", k, err, d)
+	c.cache.SetErr(k, err, d)
+}
+
+// SetLazy records the call and forwards it to the cache; see
+// zcache.Cache.SetLazy().
+func (c *Cache[K, V]) SetLazy(k K, f func() V, d time.Duration) {
+	c.record("SetLazy", k, f, d)
+	c.cache.SetLazy(k, f, d)
+}
+
+// SetUntil records the call and forwards it to the cache; see
+// zcache.Cache.SetUntil().
+func (c *Cache[K, V]) SetUntil(k K, v V, done <-chan struct{}, d time.Duration) {
+	c.record("SetUntil", k, v, done, d)
+	c.cache.SetUntil(k, v, done, d)
+}
+
+// Entry records the call and forwards it to the cache; see
+// zcache.Cache.Entry().
+func (c *Cache[K, V]) Entry(k K) zcache.Entry[K, V] {
+	c.record("Entry", k)
+	return c.cache.Entry(k)
+}
+
+// Touch records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.Touch().
+func (c *Cache[K, V]) Touch(k K) (V, bool) {
+	c.record("Touch", k)
+	if v, ok, p := c.lookup(k); p {
+		return v, ok
+	}
+	return c.cache.Touch(k)
+}
+
+// TouchWithExpire records the call and forwards it to the cache, or uses the
+// value programmed with Hit() or Miss(); see zcache.Cache.TouchWithExpire().
+func (c *Cache[K, V]) TouchWithExpire(k K, d time.Duration) (V, bool) {
+	c.record("TouchWithExpire", k, d)
+	if v, ok, p := c.lookup(k); p {
+		return v, ok
+	}
+	return c.cache.TouchWithExpire(k, d)
+}
+
+// ExpireMany records the call and forwards it to the cache; see
+// zcache.Cache.ExpireMany().
+func (c *Cache[K, V]) ExpireMany(keys []K, d time.Duration) int {
+	c.record("ExpireMany", keys, d)
+	return c.cache.ExpireMany(keys, d)
+}
+
+// Revalidate records the call and forwards it to the cache; see
+// zcache.Cache.Revalidate().
+func (c *Cache[K, V]) Revalidate(k K, check func(V) (bool, time.Duration)) bool {
+	c.record("Revalidate", k, check)
+	return c.cache.Revalidate(k, check)
+}
+
+// Add records the call and forwards it to the cache, or returns the error set
+// with Fail(); see zcache.Cache.Add().
+func (c *Cache[K, V]) Add(k K, v V) error {
+	c.record("Add", k, v)
+	if err := c.err("Add"); err != nil {
+		return err
+	}
+	return c.cache.Add(k, v)
+}
+
+// AddWithExpire records the call and forwards it to the cache, or returns the
+// error set with Fail(); see zcache.Cache.AddWithExpire().
+func (c *Cache[K, V]) AddWithExpire(k K, v V, d time.Duration) error {
+	c.record("AddWithExpire", k, v, d)
+	if err := c.err("AddWithExpire"); err != nil {
+		return err
+	}
+	return c.cache.AddWithExpire(k, v, d)
+}
+
+// AddGetExisting records the call and forwards it to the cache, or returns the
+// error set with Fail(); see zcache.Cache.AddGetExisting().
+func (c *Cache[K, V]) AddGetExisting(k K, v V, d time.Duration) (zcache.Item[V], bool, error) {
+	c.record("AddGetExisting", k, v, d)
+	if err := c.err("AddGetExisting"); err != nil {
+		return zcache.Item[V]{}, false, err
+	}
+	return c.cache.AddGetExisting(k, v, d)
+}
+
+// GetOrAdd records the call and forwards it to the cache; see
+// zcache.Cache.GetOrAdd().
+func (c *Cache[K, V]) GetOrAdd(k K, v V) V {
+	c.record("GetOrAdd", k, v)
+	return c.cache.GetOrAdd(k, v)
+}
+
+// GetOrAddWithExpire records the call and forwards it to the cache; see
+// zcache.Cache.GetOrAddWithExpire().
+func (c *Cache[K, V]) GetOrAddWithExpire(k K, v V, d time.Duration) V {
+	c.record("GetOrAddWithExpire", k, v, d)
+	return c.cache.GetOrAddWithExpire(k, v, d)
+}
+
+// Replace records the call and forwards it to the cache, or returns the error
+// set with Fail(); see zcache.Cache.Replace().
+func (c *Cache[K, V]) Replace(k K, v V) error {
+	c.record("Replace", k, v)
+	if err := c.err("Replace"); err != nil {
+		return err
+	}
+	return c.cache.Replace(k, v)
+}
+
+// ReplaceWithExpire records the call and forwards it to the cache, or returns
+// the error set with Fail(); see zcache.Cache.ReplaceWithExpire().
+func (c *Cache[K, V]) ReplaceWithExpire(k K, v V, d time.Duration) error {
+	c.record("ReplaceWithExpire", k, v, d)
+	if err := c.err("ReplaceWithExpire"); err != nil {
+		return err
+	}
+	return c.cache.ReplaceWithExpire(k, v, d)
+}
+
+// Get records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.Get().
+func (c *Cache[K, V]) Get(k K) (V, bool) {
+	c.record("Get", k)
+	if v, ok, p := c.lookup(k); p {
+		return v, ok
+	}
+	return c.cache.Get(k)
+}
+
+// GetWithin records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.GetWithin().
+func (c *Cache[K, V]) GetWithin(k K, maxWait time.Duration) (V, bool, bool) {
+	c.record("GetWithin", k, maxWait)
+	if v, ok, p := c.lookup(k); p {
+		return v, ok, false
+	}
+	return c.cache.GetWithin(k, maxWait)
+}
+
+// GetResult records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.GetResult().
+func (c *Cache[K, V]) GetResult(k K) (V, error, bool) {
+	c.record("GetResult", k)
+	if v, ok, p := c.lookup(k); p {
+		return v, nil, ok
+	}
+	return c.cache.GetResult(k)
+}
+
+// GetOrSet records the call and forwards it to the cache, or returns the value
+// programmed with Hit(); see zcache.Cache.GetOrSet(). Keys programmed with
+// Miss() always run the function.
+func (c *Cache[K, V]) GetOrSet(k K, f func() (V, time.Duration)) V {
+	c.record("GetOrSet", k, f)
+	if v, ok, p := c.lookup(k); p {
+		if ok {
+			return v
+		}
+		v, d := f()
+		c.cache.SetWithExpire(k, v, d)
+		return v
+	}
+	return c.cache.GetOrSet(k, f)
+}
+
+// GetOrSetErr records the call and forwards it to the cache, or returns the
+// value programmed with Hit() or the error set with Fail(); see
+// zcache.Cache.GetOrSetErr(). Keys programmed with Miss() always run the
+// function.
+func (c *Cache[K, V]) GetOrSetErr(k K, f func() (V, time.Duration, error)) (V, error) {
+	c.record("GetOrSetErr", k, f)
+	if err := c.err("GetOrSetErr"); err != nil {
+		var zero V
+		return zero, err
+	}
+	if v, ok, p := c.lookup(k); p {
+		if ok {
+			return v, nil
+		}
+		v, d, err := f()
+		if err != nil {
+			return v, err
+		}
+		c.cache.SetWithExpire(k, v, d)
+		return v, nil
+	}
+	return c.cache.GetOrSetErr(k, f)
+}
+
+// GetOrSetContext records the call and forwards it to the cache, or returns the
+// value programmed with Hit() or the error set with Fail(); see
+// zcache.Cache.GetOrSetContext(). Keys programmed with Miss() always run the
+// function.
+func (c *Cache[K, V]) GetOrSetContext(ctx context.Context, k K, f func(context.Context) (V, time.Duration, error)) (V, error) {
+	c.record("GetOrSetContext", ctx, k, f)
+	if err := c.err("GetOrSetContext"); err != nil {
+		var zero V
+		return zero, err
+	}
+	if v, ok, p := c.lookup(k); p {
+		if ok {
+			return v, nil
+		}
+		v, d, err := f(ctx)
+		if err != nil {
+			return v, err
+		}
+		c.cache.SetWithExpire(k, v, d)
+		return v, nil
+	}
+	return c.cache.GetOrSetContext(ctx, k, f)
+}
+
+// Promise records the call and forwards it to the cache; see
+// zcache.Cache.Promise().
+func (c *Cache[K, V]) Promise(k K) *zcache.Promise[V] {
+	c.record("Promise", k)
+	return c.cache.Promise(k)
+}
+
+// GetFresh records the call and forwards it to the cache; see
+// zcache.Cache.GetFresh().
+func (c *Cache[K, V]) GetFresh(k K, f func() (V, error)) (V, error) {
+	c.record("GetFresh", k, f)
+	return c.cache.GetFresh(k, f)
+}
+
+// GetStale records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.GetStale().
+func (c *Cache[K, V]) GetStale(k K) (V, bool, bool) {
+	c.record("GetStale", k)
+	if v, ok, p := c.lookup(k); p {
+		return v, false, ok
+	}
+	return c.cache.GetStale(k)
+}
+
+// GetWithExpire records the call and forwards it to the cache, or uses the
+// value programmed with Hit() or Miss(); see zcache.Cache.GetWithExpire().
+func (c *Cache[K, V]) GetWithExpire(k K) (V, time.Time, bool) {
+	c.record("GetWithExpire", k)
+	if v, ok, p := c.lookup(k); p {
+		return v, time.Time{}, ok
+	}
+	return c.cache.GetWithExpire(k)
+}
+
+// Modify records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.Modify().
+func (c *Cache[K, V]) Modify(k K, f func(V) V) (V, bool) {
+	c.record("Modify", k, f)
+	if v, ok, p := c.lookup(k); p {
+		if !ok {
+			return v, false
+		}
+		return f(v), true
+	}
+	return c.cache.Modify(k, f)
+}
+
+// ModifyErr records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss() or returns the error set with Fail(); see
+// zcache.Cache.ModifyErr().
+func (c *Cache[K, V]) ModifyErr(k K, f func(V) (V, error)) (V, error) {
+	c.record("ModifyErr", k, f)
+	if err := c.err("ModifyErr"); err != nil {
+		var zero V
+		return zero, err
+	}
+	if v, ok, p := c.lookup(k); p {
+		if !ok {
+			return v, fmt.Errorf("zcache.ModifyErr: item %v doesn't exist", k)
+		}
+		return f(v)
+	}
+	return c.cache.ModifyErr(k, f)
+}
+
+// ModifyMany records the call and forwards it to the cache; see
+// zcache.Cache.ModifyMany().
+func (c *Cache[K, V]) ModifyMany(keys []K, f func(K, V) V) []zcache.ModifyResult[V] {
+	c.record("ModifyMany", keys, f)
+	return c.cache.ModifyMany(keys, f)
+}
+
+// ModifyOrSet records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.ModifyOrSet().
+func (c *Cache[K, V]) ModifyOrSet(k K, f func(V) V, def V) V {
+	c.record("ModifyOrSet", k, f, def)
+	if v, ok, p := c.lookup(k); p {
+		if !ok {
+			return def
+		}
+		return f(v)
+	}
+	return c.cache.ModifyOrSet(k, f, def)
+}
+
+// Rename records the call and forwards it to the cache; see
+// zcache.Cache.Rename().
+func (c *Cache[K, V]) Rename(src, dst K) bool {
+	c.record("Rename", src, dst)
+	return c.cache.Rename(src, dst)
+}
+
+// RenameFunc records the call and forwards it to the cache; see
+// zcache.Cache.RenameFunc().
+func (c *Cache[K, V]) RenameFunc(f func(K) (K, bool)) int {
+	c.record("RenameFunc", f)
+	return c.cache.RenameFunc(f)
+}
+
+// Pop records the call and forwards it to the cache, or uses the value
+// programmed with Hit() or Miss(); see zcache.Cache.Pop().
+func (c *Cache[K, V]) Pop(k K) (V, bool) {
+	c.record("Pop", k)
+	if v, ok, p := c.lookup(k); p {
+		return v, ok
+	}
+	return c.cache.Pop(k)
+}
+
+// Refresh records the call and forwards it to the cache; see
+// zcache.Cache.Refresh().
+func (c *Cache[K, V]) Refresh(k K, f func() (V, error)) error {
+	c.record("Refresh", k, f)
+	return c.cache.Refresh(k, f)
+}
+
+// Items records the call and forwards it to the cache; see
+// zcache.Cache.Items().
+func (c *Cache[K, V]) Items() map[K]zcache.Item[V] {
+	c.record("Items")
+	return c.cache.Items()
+}
+
+// ItemsFrozen records the call and forwards it to the cache; see
+// zcache.Cache.ItemsFrozen().
+func (c *Cache[K, V]) ItemsFrozen() *zcache.ItemsView[K, V] {
+	c.record("ItemsFrozen")
+	return c.cache.ItemsFrozen()
+}
+
+// ItemsWhere records the call and forwards it to the cache; see
+// zcache.Cache.ItemsWhere().
+func (c *Cache[K, V]) ItemsWhere(filter func(K, zcache.Item[V]) bool) map[K]zcache.Item[V] {
+	c.record("ItemsWhere", filter)
+	return c.cache.ItemsWhere(filter)
+}
+
+// Keys records the call and forwards it to the cache; see zcache.Cache.Keys().
+func (c *Cache[K, V]) Keys() []K {
+	c.record("Keys")
+	return c.cache.Keys()
+}
+
+// ItemsAll records the call and forwards it to the cache; see
+// zcache.Cache.ItemsAll().
+func (c *Cache[K, V]) ItemsAll() map[K]zcache.Item[V] {
+	c.record("ItemsAll")
+	return c.cache.ItemsAll()
+}
+
+// KeysAll records the call and forwards it to the cache; see
+// zcache.Cache.KeysAll().
+func (c *Cache[K, V]) KeysAll() []K {
+	c.record("KeysAll")
+	return c.cache.KeysAll()
+}
+
+// ExpiringSoon records the call and forwards it to the cache; see
+// zcache.Cache.ExpiringSoon().
+func (c *Cache[K, V]) ExpiringSoon(n int) []K {
+	c.record("ExpiringSoon", n)
+	return c.cache.ExpiringSoon(n)
+}
+
+// ItemCount records the call and forwards it to the cache; see
+// zcache.Cache.ItemCount().
+func (c *Cache[K, V]) ItemCount() int {
+	c.record("ItemCount")
+	return c.cache.ItemCount()
+}
+
+// Delete records the call and forwards it to the cache; see
+// zcache.Cache.Delete().
+func (c *Cache[K, V]) Delete(k K) {
+	c.record("Delete", k)
+	c.cache.Delete(k)
+}
+
+// TryDelete records the call and forwards it to the cache; see
+// zcache.Cache.TryDelete().
+func (c *Cache[K, V]) TryDelete(k K) bool {
+	c.record("TryDelete", k)
+	return c.cache.TryDelete(k)
+}
+
+// BindContext records the call and forwards it to the cache; see
+// zcache.Cache.BindContext().
+func (c *Cache[K, V]) BindContext(ctx context.Context, keys ...K) {
+	c.record("BindContext", ctx, keys)
+	c.cache.BindContext(ctx, keys...)
+}
+
+// DeleteExpired records the call and forwards it to the cache; see
+// zcache.Cache.DeleteExpired().
+func (c *Cache[K, V]) DeleteExpired() {
+	c.record("DeleteExpired")
+	c.cache.DeleteExpired()
+}
+
+// WriteMetrics records the call and forwards it to the cache; see
+// zcache.Cache.WriteMetrics().
+func (c *Cache[K, V]) WriteMetrics(w io.Writer) error {
+	c.record("WriteMetrics", w)
+	return c.cache.WriteMetrics(w)
+}
+
+// JanitorStatus records the call and forwards it to the cache; see
+// zcache.Cache.JanitorStatus().
+func (c *Cache[K, V]) JanitorStatus() (bool, time.Time, int) {
+	c.record("JanitorStatus")
+	return c.cache.JanitorStatus()
+}
+
+// JanitorLockTime records the call and forwards it to the cache; see
+// zcache.Cache.JanitorLockTime().
+func (c *Cache[K, V]) JanitorLockTime() (time.Duration, time.Duration) {
+	c.record("JanitorLockTime")
+	return c.cache.JanitorLockTime()
+}
+
+// JanitorBackoff records the call and forwards it to the cache; see
+// zcache.Cache.JanitorBackoff().
+func (c *Cache[K, V]) JanitorBackoff(threshold, maxInterval time.Duration, notify func(interval, took time.Duration)) {
+	c.record("JanitorBackoff", threshold, maxInterval, notify)
+	c.cache.JanitorBackoff(threshold, maxInterval, notify)
+}
+
+// JanitorSchedule records the call and forwards it to the cache; see
+// zcache.Cache.JanitorSchedule().
+func (c *Cache[K, V]) JanitorSchedule(delay, align time.Duration) {
+	c.record("JanitorSchedule", delay, align)
+	c.cache.JanitorSchedule(delay, align)
+}
+
+// Validate records the call and forwards it to the cache; see
+// zcache.Cache.Validate().
+func (c *Cache[K, V]) Validate(maxTTL time.Duration) []zcache.Problem[K] {
+	c.record("Validate", maxTTL)
+	return c.cache.Validate(maxTTL)
+}
+
+// DeleteAll records the call and forwards it to the cache; see
+// zcache.Cache.DeleteAll().
+func (c *Cache[K, V]) DeleteAll() map[K]zcache.Item[V] {
+	c.record("DeleteAll")
+	return c.cache.DeleteAll()
+}
+
+// DeleteFunc records the call and forwards it to the cache; see
+// zcache.Cache.DeleteFunc().
+func (c *Cache[K, V]) DeleteFunc(filter func(key K, item zcache.Item[V]) (del, stop bool)) map[K]zcache.Item[V] {
+	c.record("DeleteFunc", filter)
+	return c.cache.DeleteFunc(filter)
+}
+
+// DeleteFuncWithin records the call and forwards it to the cache; see
+// zcache.Cache.DeleteFuncWithin().
+func (c *Cache[K, V]) DeleteFuncWithin(budget time.Duration, cur *zcache.Cursor[K], filter func(key K, item zcache.Item[V]) bool) (map[K]zcache.Item[V], *zcache.Cursor[K]) {
+	c.record("DeleteFuncWithin", budget, cur, filter)
+	return c.cache.DeleteFuncWithin(budget, cur, filter)
+}
+
+// Reset records the call and forwards it to the cache; see
+// zcache.Cache.Reset().
+func (c *Cache[K, V]) Reset() {
+	c.record("Reset")
+	c.cache.Reset()
+}
+
+// ReleaseMemory records the call and forwards it to the cache; see
+// zcache.Cache.ReleaseMemory().
+func (c *Cache[K, V]) ReleaseMemory(fraction float64) int {
+	c.record("ReleaseMemory", fraction)
+	return c.cache.ReleaseMemory(fraction)
+}
+
+// TrackFrequency records the call and forwards it to the cache; see
+// zcache.Cache.TrackFrequency().
+func (c *Cache[K, V]) TrackFrequency(enable bool, decay time.Duration) {
+	c.record("TrackFrequency", enable, decay)
+	c.cache.TrackFrequency(enable, decay)
+}
+
+// Frequency records the call and forwards it to the cache; see
+// zcache.Cache.Frequency().
+func (c *Cache[K, V]) Frequency(k K) uint32 {
+	c.record("Frequency", k)
+	return c.cache.Frequency(k)
+}
+
+// EvictLFU records the call and forwards it to the cache; see
+// zcache.Cache.EvictLFU().
+func (c *Cache[K, V]) EvictLFU(n int) int {
+	c.record("EvictLFU", n)
+	return c.cache.EvictLFU(n)
+}
+
+// MaxItems records the call and forwards it to the cache; see
+// zcache.Cache.MaxItems().
+func (c *Cache[K, V]) MaxItems(n int, policy zcache.EvictionPolicy) {
+	c.record("MaxItems", n, policy)
+	c.cache.MaxItems(n, policy)
+}
+
+// MaxCost records the call and forwards it to the cache; see
+// zcache.Cache.MaxCost().
+func (c *Cache[K, V]) MaxCost(max int64, policy zcache.EvictionPolicy, weigher func(K, V) int64) {
+	c.record("MaxCost", max, policy, weigher)
+	c.cache.MaxCost(max, policy, weigher)
+}
+
+// Cost records the call and forwards it to the cache; see zcache.Cache.Cost().
+func (c *Cache[K, V]) Cost() (int64, int64) {
+	c.record("Cost")
+	return c.cache.Cost()
+}
+
+// TryLockKey records the call and forwards it to the cache; see
+// zcache.Cache.TryLockKey().
+func (c *Cache[K, V]) TryLockKey(k K, ttl time.Duration) (string, bool) {
+	c.record("TryLockKey", k, ttl)
+	return c.cache.TryLockKey(k, ttl)
+}
+
+// UnlockKey records the call and forwards it to the cache; see
+// zcache.Cache.UnlockKey().
+func (c *Cache[K, V]) UnlockKey(k K, token string) bool {
+	c.record("UnlockKey", k, token)
+	return c.cache.UnlockKey(k, token)
+}
+
+// OnEvicted records the call and forwards it to the cache; see
+// zcache.Cache.OnEvicted().
+func (c *Cache[K, V]) OnEvicted(f func(K, V)) {
+	c.record("OnEvicted", f)
+	c.cache.OnEvicted(f)
+}
+
+// OnEvictedBatch records the call and forwards it to the cache; see
+// zcache.Cache.OnEvictedBatch().
+func (c *Cache[K, V]) OnEvictedBatch(f func(map[K]V)) {
+	c.record("OnEvictedBatch", f)
+	c.cache.OnEvictedBatch(f)
+}
+
+// AddEvictionHandler records the call and forwards it to the cache; see
+// zcache.Cache.AddEvictionHandler().
+func (c *Cache[K, V]) AddEvictionHandler(f func(K, V)) func() {
+	c.record("AddEvictionHandler", f)
+	return c.cache.AddEvictionHandler(f)
+}
+
+// EvictionOrder records the call and forwards it to the cache; see
+// zcache.Cache.EvictionOrder().
+func (c *Cache[K, V]) EvictionOrder(less func(a, b K) bool) {
+	c.record("EvictionOrder", less)
+	c.cache.EvictionOrder(less)
+}
+
+// OnExpiring records the call and forwards it to the cache; see
+// zcache.Cache.OnExpiring().
+func (c *Cache[K, V]) OnExpiring(lead time.Duration, f func(K, V)) {
+	c.record("OnExpiring", lead, f)
+	c.cache.OnExpiring(lead, f)
+}
+
+// ExpiryNotifications records the call and forwards it to the cache; see
+// zcache.Cache.ExpiryNotifications().
+func (c *Cache[K, V]) ExpiryNotifications(buffer int) <-chan K {
+	c.record("ExpiryNotifications", buffer)
+	return c.cache.ExpiryNotifications(buffer)
+}
+
+// DroppedExpiryNotifications records the call and forwards it to the cache; see
+// zcache.Cache.DroppedExpiryNotifications().
+func (c *Cache[K, V]) DroppedExpiryNotifications() uint64 {
+	c.record("DroppedExpiryNotifications")
+	return c.cache.DroppedExpiryNotifications()
+}
+
+// KeepHistory records the call and forwards it to the cache; see
+// zcache.Cache.KeepHistory().
+func (c *Cache[K, V]) KeepHistory(n int, interval time.Duration) {
+	c.record("KeepHistory", n, interval)
+	c.cache.KeepHistory(n, interval)
+}
+
+// History records the call and forwards it to the cache; see
+// zcache.Cache.History().
+func (c *Cache[K, V]) History() []zcache.Snapshot[K] {
+	c.record("History")
+	return c.cache.History()
+}
+
+// ExpireOnAccess records the call and forwards it to the cache; see
+// zcache.Cache.ExpireOnAccess().
+func (c *Cache[K, V]) ExpireOnAccess(enable bool) {
+	c.record("ExpireOnAccess", enable)
+	c.cache.ExpireOnAccess(enable)
+}
+
+// CoarseTime records the call.
+//
+// It's not forwarded to the cache: the mock always reads the clock on every
+// operation, so that Evict() doesn't have to wait for the coarse clock.
+func (c *Cache[K, V]) CoarseTime(resolution time.Duration) {
+	c.record("CoarseTime", resolution)
+}
+
+// Strict records the call and forwards it to the cache; see
+// zcache.Cache.Strict().
+func (c *Cache[K, V]) Strict(enable bool) {
+	c.record("Strict", enable)
+	c.cache.Strict(enable)
+}
+
+// Freeze records the call and forwards it to the cache; see
+// zcache.Cache.Freeze().
+func (c *Cache[K, V]) Freeze() {
+	c.record("Freeze")
+	c.cache.Freeze()
+}
+
+// Unfreeze records the call and forwards it to the cache; see
+// zcache.Cache.Unfreeze().
+func (c *Cache[K, V]) Unfreeze() {
+	c.record("Unfreeze")
+	c.cache.Unfreeze()
+}
+
+// Frozen records the call and forwards it to the cache; see
+// zcache.Cache.Frozen().
+func (c *Cache[K, V]) Frozen() (bool, uint64) {
+	c.record("Frozen")
+	return c.cache.Frozen()
+}
+
+// DefaultValue records the call and forwards it to the cache; see
+// zcache.Cache.DefaultValue().
+func (c *Cache[K, V]) DefaultValue(v V) {
+	c.record("DefaultValue", v)
+	c.cache.DefaultValue(v)
+}
+
+// KeyNormalizer records the call and forwards it to the cache; see
+// zcache.Cache.KeyNormalizer().
+func (c *Cache[K, V]) KeyNormalizer(f func(K) K) {
+	c.record("KeyNormalizer", f)
+	c.cache.KeyNormalizer(f)
+}
+
+// ReadPipeline records the call and forwards it to the cache; see
+// zcache.Cache.ReadPipeline().
+func (c *Cache[K, V]) ReadPipeline(fs ...func(V) V) {
+	c.record("ReadPipeline", fs)
+	c.cache.ReadPipeline(fs...)
+}
+
+// SetName records the call and forwards it to the cache; see
+// zcache.Cache.SetName().
+func (c *Cache[K, V]) SetName(name string) {
+	c.record("SetName", name)
+	c.cache.SetName(name)
+}
+
+// Name records the call and forwards it to the cache; see zcache.Cache.Name().
+func (c *Cache[K, V]) Name() string {
+	c.record("Name")
+	return c.cache.Name()
+}
+
+// KeyFormatter records the call and forwards it to the cache; see
+// zcache.Cache.KeyFormatter().
+func (c *Cache[K, V]) KeyFormatter(f func(K) string) {
+	c.record("KeyFormatter", f)
+	c.cache.KeyFormatter(f)
+}
+
+// KeyValidator records the call and forwards it to the cache; see
+// zcache.Cache.KeyValidator().
+func (c *Cache[K, V]) KeyValidator(f func(K) error) {
+	c.record("KeyValidator", f)
+	c.cache.KeyValidator(f)
+}
+
+// AutoTTL records the call and forwards it to the cache; see
+// zcache.Cache.AutoTTL().
+func (c *Cache[K, V]) AutoTTL(target float64, min, max time.Duration) {
+	c.record("AutoTTL", target, min, max)
+	c.cache.AutoTTL(target, min, max)
+}
+
+// DefaultExpiration records the call and forwards it to the cache; see
+// zcache.Cache.DefaultExpiration().
+func (c *Cache[K, V]) DefaultExpiration() time.Duration {
+	c.record("DefaultExpiration")
+	return c.cache.DefaultExpiration()
+}
+
+// TrackTTL records the call and forwards it to the cache; see
+// zcache.Cache.TrackTTL().
+func (c *Cache[K, V]) TrackTTL(enable bool, group func(K) K) {
+	c.record("TrackTTL", enable, group)
+	c.cache.TrackTTL(enable, group)
+}
+
+// TTLStats records the call and forwards it to the cache; see
+// zcache.Cache.TTLStats().
+func (c *Cache[K, V]) TTLStats() map[K]zcache.TTLStats {
+	c.record("TTLStats")
+	return c.cache.TTLStats()
+}
+
+// TrackKeys records the call and forwards it to the cache; see
+// zcache.Cache.TrackKeys().
+func (c *Cache[K, V]) TrackKeys(keys ...K) {
+	c.record("TrackKeys", keys)
+	c.cache.TrackKeys(keys...)
+}
+
+// KeyStats records the call and forwards it to the cache; see
+// zcache.Cache.KeyStats().
+func (c *Cache[K, V]) KeyStats() map[K]zcache.KeyStats {
+	c.record("KeyStats")
+	return c.cache.KeyStats()
+}
@@ -0,0 +1,126 @@
+package zcachemock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := New[string, int]()
+
+	var evicted []string
+	c.OnEvicted(func(k string, v int) { evicted = append(evicted, k) })
+
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+
+	c.Miss("a")
+	if v, ok := c.Get("a"); ok || v != 0 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+	c.Hit("a", 42)
+	if v, ok := c.Get("a"); !ok || v != 42 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+	c.Clear("a")
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get: %d, %t", v, ok)
+	}
+
+	c.Evict("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("a not evicted")
+	}
+	if fmt.Sprintf("%v", evicted) != "[a]" {
+		t.Errorf("evicted: %v", evicted)
+	}
+
+	errFail := errors.New("fail")
+	c.Fail("Add", errFail)
+	if err := c.Add("b", 2); err != errFail {
+		t.Errorf("Add: %v", err)
+	}
+	c.Fail("Add", nil)
+	if err := c.Add("b", 2); err != nil {
+		t.Errorf("Add: %v", err)
+	}
+
+	want := "[{OnEvicted []} {Set [a 1]} {Get [a]} {Get [a]} {Get [a]} {Get [a]} {Get [a]} {Add [b 2]} {Add [b 2]}]"
+	calls := c.Calls()
+	calls[0].Args = nil // func
+	if h := fmt.Sprintf("%v", calls); h != want {
+		t.Errorf("\nhave: %s\nwant: %s", h, want)
+	}
+
+	c.ResetCalls()
+	if len(c.Calls()) != 0 {
+		t.Error("calls not reset")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	c := New[string, int]()
+	c.CoarseTime(time.Hour)
+
+	var batch map[string]int
+	c.OnEvictedBatch(func(m map[string]int) { batch = m })
+	ch := c.ExpiryNotifications(10)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Evict("a", "b")
+	if fmt.Sprintf("%v", batch) != "map[a:1 b:2]" {
+		t.Errorf("batch: %v", batch)
+	}
+	if k1, k2 := <-ch, <-ch; !(k1 == "a" && k2 == "b" || k1 == "b" && k2 == "a") {
+		t.Errorf("notifications: %s %s", k1, k2)
+	}
+
+	c.Evict()
+	if fmt.Sprintf("%v", batch) != "map[c:3]" {
+		t.Errorf("batch: %v", batch)
+	}
+	if n := c.ItemCount(); n != 0 {
+		t.Errorf("ItemCount: %d", n)
+	}
+}
+
+func TestGetOrSetMiss(t *testing.T) {
+	c := New[string, int]()
+	c.Set("k", 1)
+	c.Miss("k")
+
+	var calls int
+	if v := c.GetOrSet("k", func() (int, time.Duration) { calls++; return 2, 0 }); v != 2 {
+		t.Errorf("GetOrSet: %d", v)
+	}
+	if v, err := c.GetOrSetErr("k", func() (int, time.Duration, error) { calls++; return 3, 0, nil }); err != nil || v != 3 {
+		t.Errorf("GetOrSetErr: %d, %v", v, err)
+	}
+	v, err := c.GetOrSetContext(context.Background(), "k", func(context.Context) (int, time.Duration, error) {
+		calls++
+		return 4, 0, nil
+	})
+	if err != nil || v != 4 {
+		t.Errorf("GetOrSetContext: %d, %v", v, err)
+	}
+	if calls != 3 {
+		t.Errorf("loader called %d times", calls)
+	}
+
+	c.Clear("k")
+	if v, _ := c.Get("k"); v != 4 {
+		t.Errorf("stored value: %d", v)
+	}
+
+	c.Hit("k", 42)
+	if v := c.GetOrSet("k", func() (int, time.Duration) { calls++; return 2, 0 }); v != 42 || calls != 3 {
+		t.Errorf("GetOrSet with Hit(): %d", v)
+	}
+}
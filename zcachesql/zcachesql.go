@@ -0,0 +1,186 @@
+// Package zcachesql caches the results of database queries.
+//
+// Results are keyed on the query name and its arguments, and are tagged with
+// the tables the query reads from so that they can be invalidated when a table
+// is modified:
+//
+//	users := zcachesql.New(zcache.New[string, []User](time.Minute, 5*time.Minute))
+//	byName := users.Query("byName", []string{"users"},
+//		func(ctx context.Context, args ...any) ([]User, error) {
+//			return selectUsers(ctx, "select * from users where name=$1", args...)
+//		})
+//
+//	u, err := byName(ctx, "Martin")  // Runs the query.
+//	u, err = byName(ctx, "Martin")   // Cached.
+//	updateUser(ctx, ...)
+//	users.Invalidate("users")        // Next byName() will run the query.
+package zcachesql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"zgo.at/zcache/v2"
+)
+
+// Cache caches query results of type T.
+type Cache[T any] struct {
+	cache *zcache.Cache[string, T]
+
+	mu     sync.Mutex
+	tables map[string]map[string]struct{} // table → keys
+	keys   map[string][]string            // key → tables
+}
+
+// New creates a new query cache, storing the results in c.
+//
+// The cache should not be used for anything else.
+func New[T any](c *zcache.Cache[string, T]) *Cache[T] {
+	q := &Cache[T]{
+		cache:  c,
+		tables: make(map[string]map[string]struct{}),
+		keys:   make(map[string][]string),
+	}
+	c.AddEvictionHandler(func(k string, _ T) { q.untag(k) })
+	return q
+}
+
+// Query wraps the query function f, returning a function which gets the
+// results from the cache if they're cached, or runs f and stores the results if
+// they're not.
+//
+// The name must be unique for every query in this cache; the tables are the
+// tables the query reads from, and are used for Invalidate().
+//
+// Concurrent calls for the same arguments will run f only once. Errors are
+// never cached.
+func (c *Cache[T]) Query(name string, tables []string,
+	f func(ctx context.Context, args ...any) (T, error),
+) func(ctx context.Context, args ...any) (T, error) {
+	return func(ctx context.Context, args ...any) (T, error) {
+		k, err := key(name, args)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		if v, ok := c.cache.Get(k); ok {
+			return v, nil
+		}
+		return c.cache.GetFresh(k, func() (T, error) {
+			v, err := f(ctx, args...)
+			if err == nil {
+				c.tag(k, tables)
+			}
+			return v, err
+		})
+	}
+}
+
+// Invalidate removes all cached results for queries that read from any of the
+// given tables, returning the number of results that were removed.
+//
+// A query that's running while Invalidate() is called may still store a
+// result from before the table was modified.
+func (c *Cache[T]) Invalidate(tables ...string) int {
+	c.mu.Lock()
+	var keys []string
+	for _, t := range tables {
+		for k := range c.tables[t] {
+			keys = append(keys, k)
+		}
+	}
+	c.mu.Unlock()
+
+	// Deleting will run the eviction handler, which will remove the tags.
+	n := 0
+	for _, k := range keys {
+		if _, ok := c.cache.Get(k); ok {
+			n++
+		}
+		c.cache.Delete(k)
+	}
+	return n
+}
+
+func (c *Cache[T]) tag(k string, tables []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys[k] = tables
+	for _, t := range tables {
+		if c.tables[t] == nil {
+			c.tables[t] = make(map[string]struct{})
+		}
+		c.tables[t][k] = struct{}{}
+	}
+}
+
+func (c *Cache[T]) untag(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.keys[k] {
+		delete(c.tables[t], k)
+		if len(c.tables[t]) == 0 {
+			delete(c.tables, t)
+		}
+	}
+	delete(c.keys, k)
+}
+
+// key creates a cache key from the query name and arguments.
+//
+// Arguments are normalized so that equivalent values get the same key: values
+// implementing driver.Valuer are converted with Value(), pointers are
+// dereferenced, integers are converted to int64 or uint64, and times are
+// converted to UTC.
+//
+// The name and the type and value of every argument are prefixed with their
+// length, so that arguments containing separators can't produce the same key
+// as a different set of arguments.
+func key(name string, args []any) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d:%s", len(name), name)
+	for i, a := range args {
+		a, err := normalize(a)
+		if err != nil {
+			return "", fmt.Errorf("zcachesql: argument %d: %w", i, err)
+		}
+		t, v := fmt.Sprintf("%T", a), fmt.Sprint(a)
+		fmt.Fprintf(&b, ",%d:%s%d:%s", len(t), t, len(v), v)
+	}
+	return b.String(), nil
+}
+
+func normalize(a any) (any, error) {
+	if v, ok := a.(driver.Valuer); ok {
+		var err error
+		a, err = v.Value()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rv := reflect.ValueOf(a)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	}
+	if t, ok := rv.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+	return rv.Interface(), nil
+}
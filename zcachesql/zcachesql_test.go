@@ -0,0 +1,114 @@
+package zcachesql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zgo.at/zcache/v2"
+)
+
+func TestQuery(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		c    = New(zcache.New[string, string](zcache.NoExpiration, 0))
+		runs int32
+	)
+	users := c.Query("users", []string{"users"}, func(ctx context.Context, args ...any) (string, error) {
+		atomic.AddInt32(&runs, 1)
+		return fmt.Sprintf("user %v", args[0]), nil
+	})
+	joined := c.Query("joined", []string{"users", "orders"}, func(ctx context.Context, args ...any) (string, error) {
+		atomic.AddInt32(&runs, 1)
+		return "joined", nil
+	})
+
+	n := 1
+	for _, arg := range []any{1, int64(1), &n} {
+		if v, err := users(ctx, arg); v != "user 1" || err != nil {
+			t.Errorf("%q %v", v, err)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("runs: %d", runs)
+	}
+	users(ctx, 2)
+	joined(ctx)
+	if runs != 3 {
+		t.Errorf("runs: %d", runs)
+	}
+
+	if n := c.Invalidate("orders"); n != 1 {
+		t.Errorf("invalidated %d", n)
+	}
+	joined(ctx)
+	users(ctx, 1)
+	if runs != 4 {
+		t.Errorf("runs: %d", runs)
+	}
+
+	if n := c.Invalidate("users"); n != 3 {
+		t.Errorf("invalidated %d", n)
+	}
+	if n := c.Invalidate("users", "orders"); n != 0 {
+		t.Errorf("invalidated %d", n)
+	}
+	if len(c.tables) != 0 || len(c.keys) != 0 {
+		t.Errorf("tags not removed: %v %v", c.tables, c.keys)
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		c       = New(zcache.New[string, int](zcache.NoExpiration, 0))
+		errFail = errors.New("fail")
+		fail    = true
+	)
+	q := c.Query("q", []string{"t"}, func(ctx context.Context, args ...any) (int, error) {
+		if fail {
+			return 0, errFail
+		}
+		return 1, nil
+	})
+
+	if _, err := q(ctx); err != errFail {
+		t.Error(err)
+	}
+	fail = false
+	if v, err := q(ctx); v != 1 || err != nil {
+		t.Error(v, err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	s := "a"
+	tm := time.Date(2020, 1, 1, 12, 0, 0, 0, time.FixedZone("", 3600))
+	tests := []struct {
+		a, b []any
+		same bool
+	}{
+		{[]any{"a"}, []any{&s}, true},
+		{[]any{1}, []any{int32(1)}, true},
+		{[]any{1}, []any{uint(1)}, false},
+		{[]any{1}, []any{"1"}, false},
+		{[]any{"a", "b"}, []any{"a\x00b"}, false},
+		{[]any{"a", "b"}, []any{"a\x00string\x00b"}, false},
+		{[]any{"a", "b"}, []any{"a,6:string1:b"}, false},
+		{[]any{"a,6:string1:b"}, []any{"a", "b"}, false},
+		{[]any{tm}, []any{tm.UTC()}, true},
+		{[]any{nil}, []any{(*string)(nil)}, true},
+	}
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			a, _ := key("q", tt.a)
+			b, _ := key("q", tt.b)
+			if (a == b) != tt.same {
+				t.Errorf("\n%q\n%q", a, b)
+			}
+		})
+	}
+}